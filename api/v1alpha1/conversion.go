@@ -0,0 +1,122 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"github.com/operatify/operatify/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+)
+
+// ConvertTo converts the ATest to the hub version, v1beta1
+func (src *ATest) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.ATest)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec.Spec = v1beta1.Spec(src.Spec.Spec)
+	dst.Status = src.Status.convertTo()
+	return nil
+}
+
+// ConvertFrom converts the ATest from the hub version, v1beta1
+func (dst *ATest) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.ATest)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec.Spec = Spec(src.Spec.Spec)
+	dst.Status.convertFrom(src.Status)
+	return nil
+}
+
+// ConvertTo converts the BTest to the hub version, v1beta1
+func (src *BTest) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.BTest)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec.Spec = v1beta1.Spec(src.Spec.Spec)
+	dst.Spec.Owner = src.Spec.Owner
+	dst.Spec.Dependencies = src.Spec.Dependencies
+	dst.Status = src.Status.convertTo()
+	return nil
+}
+
+// ConvertFrom converts the BTest from the hub version, v1beta1
+func (dst *BTest) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.BTest)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec.Spec = Spec(src.Spec.Spec)
+	dst.Spec.Owner = src.Spec.Owner
+	dst.Spec.Dependencies = src.Spec.Dependencies
+	dst.Status.convertFrom(src.Status)
+	return nil
+}
+
+// converts the Status to v1beta1, deriving the observed generation and the Ready condition,
+// which v1alpha1 doesn't have, from the state and the transition history
+func (s *Status) convertTo() v1beta1.Status {
+	dst := v1beta1.Status{
+		State:              s.State,
+		Message:            s.Message,
+		LastTransitionTime: s.LastTransitionTime,
+	}
+	if s.Operation != nil {
+		dst.Operation = &v1beta1.Operation{Id: s.Operation.Id, Type: s.Operation.Type}
+	}
+	for _, t := range s.Transitions {
+		dst.Transitions = append(dst.Transitions, v1beta1.Transition(t))
+		dst.ObservedGeneration = t.Generation
+	}
+	if s.State != "" {
+		dst.Conditions = []v1beta1.Condition{{
+			Type:               v1beta1.ConditionReady,
+			Status:             readyConditionStatus(s.State),
+			Reason:             s.State,
+			Message:            s.Message,
+			LastTransitionTime: s.LastTransitionTime,
+		}}
+	}
+	for _, c := range s.Conditions {
+		dst.Conditions = append(dst.Conditions, v1beta1.Condition(c))
+	}
+	return dst
+}
+
+// sets the Status from v1beta1. the observed generation and the Ready condition are dropped, as they are derived from the rest of the Status
+func (s *Status) convertFrom(src v1beta1.Status) {
+	*s = Status{
+		State:              src.State,
+		Message:            src.Message,
+		LastTransitionTime: src.LastTransitionTime,
+	}
+	if src.Operation != nil {
+		s.Operation = &Operation{Id: src.Operation.Id, Type: src.Operation.Type}
+	}
+	for _, t := range src.Transitions {
+		s.Transitions = append(s.Transitions, Transition(t))
+	}
+	for _, c := range src.Conditions {
+		if c.Type != v1beta1.ConditionReady {
+			s.Conditions = append(s.Conditions, Condition(c))
+		}
+	}
+}
+
+func readyConditionStatus(state string) corev1.ConditionStatus {
+	switch state {
+	case "Succeeded":
+		return corev1.ConditionTrue
+	case "Failed":
+		return corev1.ConditionFalse
+	}
+	return corev1.ConditionUnknown
+}
@@ -0,0 +1,82 @@
+package v1alpha1
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/operatify/operatify/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+)
+
+var _ = Describe("Conversion", func() {
+
+	scheme := runtime.NewScheme()
+	Expect(AddToScheme(scheme)).To(Succeed())
+	Expect(v1beta1.AddToScheme(scheme)).To(Succeed())
+
+	// converts the object to the apiVersion with the conversion webhook, as the API server would
+	convert := func(object runtime.Object, apiVersion string, converted runtime.Object) {
+		webhook := &conversion.Webhook{}
+		Expect(webhook.InjectScheme(scheme)).To(Succeed())
+		b, err := json.Marshal(object)
+		Expect(err).NotTo(HaveOccurred())
+		review := map[string]interface{}{
+			"apiVersion": "apiextensions.k8s.io/v1beta1",
+			"kind":       "ConversionReview",
+			"request": map[string]interface{}{
+				"uid":               "review",
+				"desiredAPIVersion": apiVersion,
+				"objects":           []runtime.RawExtension{{Raw: b}},
+			},
+		}
+		body, err := json.Marshal(review)
+		Expect(err).NotTo(HaveOccurred())
+		recorder := httptest.NewRecorder()
+		webhook.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/convert", bytes.NewReader(body)))
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		var response struct {
+			Response struct {
+				ConvertedObjects []json.RawMessage `json:"convertedObjects"`
+				Result           metav1.Status     `json:"result"`
+			} `json:"response"`
+		}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).To(Succeed())
+		Expect(response.Response.Result.Status).To(Equal(metav1.StatusSuccess), response.Response.Result.Message)
+		Expect(response.Response.ConvertedObjects).To(HaveLen(1))
+		Expect(json.Unmarshal(response.Response.ConvertedObjects[0], converted)).To(Succeed())
+	}
+
+	It("converts an ATest to v1beta1 and back", func() {
+		created := &ATest{
+			TypeMeta:   metav1.TypeMeta{APIVersion: GroupVersion.String(), Kind: "ATest"},
+			ObjectMeta: metav1.ObjectMeta{Name: "a-test", Namespace: "default"},
+			Spec:       ASpec{Spec: Spec{Id: "a-1", StringData: "data"}},
+		}
+		created.Status.State = "Succeeded"
+		created.Status.Transitions = []Transition{{From: "Verifying", To: "Succeeded", Generation: 2}}
+
+		converted := &v1beta1.ATest{}
+		convert(created, v1beta1.GroupVersion.String(), converted)
+		Expect(converted.Spec.Id).To(Equal(created.Spec.Id))
+		Expect(converted.Spec.StringData).To(Equal("data"))
+		Expect(converted.Status.State).To(Equal("Succeeded"))
+		Expect(converted.Status.ObservedGeneration).To(Equal(int64(2)))
+		Expect(converted.Status.Conditions).To(HaveLen(1))
+		Expect(converted.Status.Conditions[0].Type).To(Equal(v1beta1.ConditionReady))
+		Expect(converted.Status.Conditions[0].Status).To(Equal(corev1.ConditionTrue))
+
+		roundTripped := &ATest{}
+		convert(converted, GroupVersion.String(), roundTripped)
+		Expect(roundTripped.Spec).To(Equal(created.Spec))
+		Expect(roundTripped.Status.State).To(Equal(created.Status.State))
+		Expect(roundTripped.Status.Transitions).To(HaveLen(1))
+	})
+})
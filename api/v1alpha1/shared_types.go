@@ -1,5 +1,10 @@
 package v1alpha1
 
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
 // Status defines the desired state of resource
 type Spec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
@@ -7,6 +12,13 @@ type Spec struct {
 	Id         string `json:"id,omitempty"`
 	StringData string `json:"stringData,omitempty"`
 	IntData    int    `json:"intData,omitempty"`
+	// The ProviderConfig or ClusterProviderConfig holding the endpoint and credentials of the external resource
+	ProviderConfigRef *corev1.TypedLocalObjectReference `json:"providerConfigRef,omitempty"`
+}
+
+// SharedSpec returns the Spec, which is promoted to the spec of each kind embedding it
+func (s *Spec) SharedSpec() *Spec {
+	return s
 }
 
 // Status defines the observed state of resource
@@ -15,4 +27,37 @@ type Status struct {
 	// Important: Run "make" to regenerate code after modifying this file
 	State   string `json:"state,omitempty"`
 	Message string `json:"message,omitempty"`
+	// The time the state last changed
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+	// The long-running operation on the external resource in progress, if any
+	Operation *Operation `json:"operation,omitempty"`
+	// The most recent state transitions, oldest first
+	Transitions []Transition `json:"transitions,omitempty"`
+	// Observations of the resource that aren't captured by its state, such as BackendUnavailable
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// Condition is an observation of the resource
+type Condition struct {
+	Type               string                 `json:"type"`
+	Status             corev1.ConditionStatus `json:"status"`
+	Reason             string                 `json:"reason,omitempty"`
+	Message            string                 `json:"message,omitempty"`
+	LastTransitionTime *metav1.Time           `json:"lastTransitionTime,omitempty"`
+}
+
+// Transition records a change of state
+type Transition struct {
+	From       string      `json:"from,omitempty"`
+	To         string      `json:"to"`
+	Reason     string      `json:"reason,omitempty"`
+	Message    string      `json:"message,omitempty"`
+	Time       metav1.Time `json:"time"`
+	Generation int64       `json:"generation,omitempty"`
+}
+
+// Operation identifies a long-running operation on the external resource
+type Operation struct {
+	Id   string `json:"id"`
+	Type string `json:"type"`
 }
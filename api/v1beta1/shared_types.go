@@ -0,0 +1,60 @@
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Spec defines the desired state of resource
+type Spec struct {
+	Id         string `json:"id,omitempty"`
+	StringData string `json:"stringData,omitempty"`
+	IntData    int    `json:"intData,omitempty"`
+	// The ProviderConfig or ClusterProviderConfig holding the endpoint and credentials of the external resource
+	ProviderConfigRef *corev1.TypedLocalObjectReference `json:"providerConfigRef,omitempty"`
+}
+
+// Status defines the observed state of resource
+type Status struct {
+	State   string `json:"state,omitempty"`
+	Message string `json:"message,omitempty"`
+	// The time the state last changed
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+	// The long-running operation on the external resource in progress, if any
+	Operation *Operation `json:"operation,omitempty"`
+	// The most recent state transitions, oldest first
+	Transitions []Transition `json:"transitions,omitempty"`
+	// The generation of the resource the state was last changed for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// The latest observations of the resource, such as whether it is Ready
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// ConditionReady is the type of the condition reporting whether the external resource is ready for use
+const ConditionReady = "Ready"
+
+// Condition is an observation of the resource
+type Condition struct {
+	Type   string                 `json:"type"`
+	Status corev1.ConditionStatus `json:"status"`
+	// The state the condition was derived from
+	Reason             string       `json:"reason,omitempty"`
+	Message            string       `json:"message,omitempty"`
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// Transition records a change of state
+type Transition struct {
+	From       string      `json:"from,omitempty"`
+	To         string      `json:"to"`
+	Reason     string      `json:"reason,omitempty"`
+	Message    string      `json:"message,omitempty"`
+	Time       metav1.Time `json:"time"`
+	Generation int64       `json:"generation,omitempty"`
+}
+
+// Operation identifies a long-running operation on the external resource
+type Operation struct {
+	Id   string `json:"id"`
+	Type string `json:"type"`
+}
@@ -0,0 +1,78 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/operatify/operatify/scaffold"
+)
+
+const usage = `usage: operatify <command> [flags]
+
+commands:
+  new-kind   generate the API type, controller package, envtest helpers and main.go registration for a new kind
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "new-kind":
+		newKind(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func newKind(args []string) {
+	flags := flag.NewFlagSet("new-kind", flag.ExitOnError)
+	var options scaffold.KindOptions
+	var dependencies string
+	flags.StringVar(&options.Kind, "kind", "", "The name of the kind, e.g. CTest.")
+	flags.StringVar(&options.Version, "version", "v1alpha1", "The API version of the kind.")
+	flags.StringVar(&dependencies, "depends-on", "", "A comma separated list of the kinds that resources of this kind depend on.")
+	flags.StringVar(&options.Root, "root", ".", "The root directory of the project.")
+	_ = flags.Parse(args)
+
+	if options.Kind == "" {
+		fmt.Fprintln(os.Stderr, "-kind is required")
+		flags.Usage()
+		os.Exit(2)
+	}
+	for _, dep := range strings.Split(dependencies, ",") {
+		if dep = strings.TrimSpace(dep); dep != "" {
+			options.Dependencies = append(options.Dependencies, dep)
+		}
+	}
+
+	written, err := scaffold.NewKind(options)
+	for _, path := range written {
+		fmt.Println("wrote " + path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to generate kind %s: %v\n", options.Kind, err)
+		os.Exit(1)
+	}
+	fmt.Println("Now run 'make generate manifests' to generate the deepcopy functions and CRD for " + options.Kind + ",")
+	fmt.Println("register the controller in controllers/suite_test.go, and implement the ResourceManager.")
+}
@@ -21,22 +21,25 @@ import (
 	"github.com/operatify/operatify/controllers/shared"
 	"github.com/operatify/operatify/reconciler"
 
-	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/go-logr/logr"
 )
 
+// ControllerFactory creates the controller for ATest, with the settings of the reconciler.ControllerFactory.
+// The kind, its DefinitionManager, finalizer, annotations and provider config kinds are set by SetupWithManager,
+// and the ResourceManagerCreator defaults to CreateResourceManager with the Manager
 type ControllerFactory struct {
-	ResourceManagerCreator func(logr.Logger, record.EventRecorder, *manager.Manager) shared.ResourceManager
-	Scheme                 *runtime.Scheme
-	Manager                *manager.Manager
+	reconciler.ControllerFactory
+	// The backend called by the ResourceManager created by default
+	Manager *manager.Manager
 }
 
 // +kubebuilder:rbac:groups=test.stephenzoio.com,resources=as,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=test.stephenzoio.com,resources=as/status,verbs=get;update;patch
+// +kubebuilder:webhook:path=/mutate-test-stephenzoio-com-v1alpha1-atest,mutating=true,failurePolicy=fail,groups=test.stephenzoio.com,resources=atests,verbs=create;update,versions=v1alpha1,name=matest.kb.io
+// +kubebuilder:webhook:path=/validate-test-stephenzoio-com-v1alpha1-atest,mutating=false,failurePolicy=fail,groups=test.stephenzoio.com,resources=atests,verbs=create;update,versions=v1alpha1,name=vatest.kb.io
 
 // +kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
 // +kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`
@@ -44,27 +47,21 @@ type ControllerFactory struct {
 const ResourceKind = "ATest"
 const FinalizerName = "a.finalizers.com"
 
-func (factory *ControllerFactory) SetupWithManager(mgr ctrl.Manager, parameters reconciler.ReconcileParameters, log *logr.Logger) error {
-	if log == nil {
-		l := ctrl.Log.WithName("controllers")
-		log = &l
+func (factory *ControllerFactory) SetupWithManager(mgr ctrl.Manager, parameters reconciler.ReconcileParameters, log *logr.Logger) (*reconciler.GenericController, error) {
+	generic := factory.ControllerFactory
+	generic.Prototype = &api.ATest{}
+	generic.ResourceKind = ResourceKind
+	generic.DefinitionManager = CreateDefinitionManager()
+	generic.FinalizerName = FinalizerName
+	generic.AnnotationBaseName = shared.AnnotationBaseName
+	generic.ProviderConfigKinds = shared.ProviderConfigKinds()
+	if generic.ResourceManagerCreator == nil {
+		generic.ResourceManagerCreator = func(logger logr.Logger, recorder record.EventRecorder) reconciler.ResourceManager {
+			resourceManagerClient := CreateResourceManager(logger, recorder, factory.Manager)
+			return &resourceManagerClient
+		}
 	}
-	gc, err := factory.createGenericController(mgr.GetClient(),
-		(*log).WithName(ResourceKind),
-		mgr.GetEventRecorderFor(ResourceKind+"-controller"), parameters)
-	if err != nil {
-		return err
-	}
-
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&api.ATest{}).
-		Complete(gc)
-}
-
-func (factory *ControllerFactory) createGenericController(kubeClient client.Client, logger logr.Logger, recorder record.EventRecorder, parameters reconciler.ReconcileParameters) (*reconciler.GenericController, error) {
-	resourceManagerClient := factory.ResourceManagerCreator(logger, recorder, factory.Manager)
-
-	return reconciler.CreateGenericController(parameters, ResourceKind, kubeClient, logger, recorder, factory.Scheme, &resourceManagerClient, &definitionManager{}, FinalizerName, shared.AnnotationBaseName, nil)
+	return generic.SetupWithManager(mgr, parameters, log)
 }
 
 func CreateResourceManager(logger logr.Logger, recorder record.EventRecorder, manager *manager.Manager) shared.ResourceManager {
@@ -72,6 +69,6 @@ func CreateResourceManager(logger logr.Logger, recorder record.EventRecorder, ma
 		Logger:     logger,
 		Recorder:   recorder,
 		Manager:    manager,
-		SpecGetter: getSpec,
+		SpecGetter: shared.AsSpecGetter(Definition.GetSpec),
 	}
 }
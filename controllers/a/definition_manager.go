@@ -19,18 +19,26 @@ import (
 	"context"
 
 	"github.com/operatify/operatify/api/v1alpha1"
+	"github.com/operatify/operatify/controllers/shared"
 	"github.com/operatify/operatify/reconciler"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 )
 
-type definitionManager struct{}
+// Definition builds the ResourceDefinition for ATest
+var Definition = reconciler.MustCreateDefinitionBuilder(&v1alpha1.ATest{})
 
-func (dm *definitionManager) GetDefinition(ctx context.Context, namespacedName types.NamespacedName) *reconciler.ResourceDefinition {
-	return &reconciler.ResourceDefinition{
-		InitialInstance: &v1alpha1.ATest{},
-		StatusAccessor:  GetStatus,
-		StatusUpdater:   updateStatus,
+type definitionManager struct {
+	*reconciler.DefinitionBuilder
+	shared.Validator
+	shared.Defaulter
+}
+
+// CreateDefinitionManager returns the DefinitionManager of the kind, which is also its Validator and Defaulter
+func CreateDefinitionManager() reconciler.DefinitionManager {
+	return &definitionManager{
+		DefinitionBuilder: Definition,
+		Validator:         shared.Validator{SpecGetter: shared.AsSpecGetter(Definition.GetSpec)},
+		Defaulter:         shared.Defaulter{SpecGetter: shared.AsSpecGetter(Definition.GetSpec)},
 	}
 }
 
@@ -17,41 +17,47 @@ package b
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/operatify/operatify/controllers/a"
 
 	"github.com/operatify/operatify/api/v1alpha1"
+	"github.com/operatify/operatify/controllers/shared"
 	"github.com/operatify/operatify/reconciler"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 )
 
-type definitionManager struct{}
+// Definition builds the ResourceDefinition for BTest
+var Definition = reconciler.MustCreateDefinitionBuilder(&v1alpha1.BTest{})
 
-func (dm *definitionManager) GetDefinition(ctx context.Context, namespacedName types.NamespacedName) *reconciler.ResourceDefinition {
-	return &reconciler.ResourceDefinition{
-		InitialInstance: &v1alpha1.BTest{},
-		StatusAccessor:  GetStatus,
-		StatusUpdater:   updateStatus,
+type definitionManager struct {
+	*reconciler.DefinitionBuilder
+	shared.Validator
+	shared.Defaulter
+}
+
+// CreateDefinitionManager returns the DefinitionManager of the kind, which is also its Validator and Defaulter
+func CreateDefinitionManager() reconciler.DefinitionManager {
+	return &definitionManager{
+		DefinitionBuilder: Definition,
+		Validator:         shared.Validator{SpecGetter: shared.AsSpecGetter(Definition.GetSpec)},
+		Defaulter:         shared.Defaulter{SpecGetter: shared.AsSpecGetter(Definition.GetSpec)},
 	}
 }
 
 func (dm *definitionManager) GetDependencies(ctx context.Context, thisInstance runtime.Object) (*reconciler.DependencyDefinitions, error) {
-	x, err := convertInstance(thisInstance)
-	if err != nil {
-		return nil, err
+	x, ok := thisInstance.(*v1alpha1.BTest)
+	if !ok {
+		return nil, fmt.Errorf("failed type assertion on kind: %s", ResourceKind)
 	}
 	spec := x.Spec
 
 	getDependency := func(dep string) *reconciler.Dependency {
-		return &reconciler.Dependency{
-			InitialInstance: &v1alpha1.ATest{},
-			NamespacedName: types.NamespacedName{
-				Namespace: x.Namespace,
-				Name:      dep,
-			},
-			SucceededAccessor: a.GetSuccess,
-		}
+		return a.Definition.GetDependency(types.NamespacedName{
+			Namespace: x.Namespace,
+			Name:      dep,
+		})
 	}
 
 	deps := make([]*reconciler.Dependency, len(spec.Dependencies))
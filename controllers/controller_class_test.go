@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/operatify/operatify/controllers/manager"
+	"github.com/operatify/operatify/reconciler"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Controller class", func() {
+
+	setControllerClassA := func(key types.NamespacedName, class string) {
+		Eventually(func() error {
+			object, err := getObjectA(key)
+			if err != nil {
+				return err
+			}
+			object.Annotations[controllerClassAnnotation] = class
+			return k8sClient.Update(context.Background(), object)
+		}, timeout, interval).Should(Succeed())
+	}
+
+	managingControllerClassA := func(key types.NamespacedName) func() []string {
+		return func() []string {
+			object, _ := getObjectA(key)
+			if class, ok := object.Annotations[managingControllerClassAnnotation]; ok {
+				return []string{class}
+			}
+			return nil
+		}
+	}
+
+	It("should ignore resources assigned to another class until they are assigned to its class", func() {
+		aId := "a-" + RandomString(10)
+		key, created := nameAndSpecWithAnnotationsA(aId, map[string]string{controllerClassAnnotation: "other"})
+		Expect(k8sClient.Create(context.Background(), created)).Should(Succeed())
+
+		Consistently(func() bool {
+			f, _ := getObjectA(key)
+			return f.Status.State == "" && len(f.Finalizers) == 0
+		}, time.Second*2, interval).Should(BeTrue())
+		Expect(resourceManager.CountEvents(aId, manager.EventGet)).To(Equal(0))
+
+		// assign it to the default class of the controller
+		setControllerClassA(key, "")
+		waitUntilReconcileStateA(key, reconciler.Succeeded)
+		Expect(managingControllerClassA(key)()).To(Equal([]string{""}))
+
+		Expect(deleteObjectA(key)).To(Succeed())
+		waitUntilObjectMissingA(key)
+	})
+
+	It("should hand over resources assigned to another class", func() {
+		aId := "a-" + RandomString(10)
+		key, created := nameAndSpecA(aId)
+		Expect(k8sClient.Create(context.Background(), created)).Should(Succeed())
+		waitUntilReconcileStateA(key, reconciler.Succeeded)
+		Expect(managingControllerClassA(key)()).To(Equal([]string{""}))
+
+		// the controller releases the resource, and leaves it to the controller of the other class
+		setControllerClassA(key, "other")
+		Eventually(managingControllerClassA(key), timeout, interval).Should(BeNil())
+		gets := resourceManager.CountEvents(aId, manager.EventGet)
+		Consistently(func() int {
+			return resourceManager.CountEvents(aId, manager.EventGet)
+		}, time.Second*2, interval).Should(Equal(gets))
+
+		// the resource is handed back, so the controller can finalize it
+		setControllerClassA(key, "")
+		Eventually(managingControllerClassA(key), timeout, interval).Should(Equal([]string{""}))
+		Expect(deleteObjectA(key)).To(Succeed())
+		waitUntilObjectMissingA(key)
+	})
+})
@@ -113,5 +113,85 @@ var _ = Describe("Test Create and Delete", func() {
 				reconciler.VerifyResultMissing,
 			}))
 		})
+
+		It("should keep a history of state transitions", func() {
+			aId := "a-" + RandomString(10)
+			key, created := nameAndSpecA(aId)
+
+			// Create
+			Expect(k8sClient.Create(context.Background(), created)).Should(Succeed())
+			waitUntilReconcileStateA(key, reconciler.Succeeded)
+
+			f, err := getObjectA(key)
+			Expect(err).ToNot(HaveOccurred())
+			var states []string
+			for _, t := range f.Status.Transitions {
+				states = append(states, t.From+"->"+t.To)
+			}
+			Expect(states).Should(Equal([]string{
+				"->Pending",
+				"Pending->Creating",
+				"Creating->Verifying",
+				"Verifying->Succeeded",
+			}))
+
+			// Delete
+			By("Expecting to delete successfully")
+			Expect(deleteObjectA(key)).To(Succeed())
+
+			By("Expecting to delete finish")
+			waitUntilObjectMissingA(key)
+		})
+
+		It("should reuse a persisted operation token when retrying a create", func() {
+			aId := "a-" + RandomString(10)
+			key, created := nameAndSpecWithAnnotationsA(aId, map[string]string{
+				operationTokenAnnotation: "token-" + aId,
+			})
+
+			// Create
+			Expect(k8sClient.Create(context.Background(), created)).Should(Succeed())
+			waitUntilReconcileStateA(key, reconciler.Succeeded)
+
+			By("Expecting the persisted token to be passed to Create")
+			record := resourceManager.GetRecord(aId)
+			Expect(record.OperationTokens).Should(Equal([]string{"token-" + aId}))
+
+			By("Expecting the token to be cleared once the create has been applied")
+			f, err := getObjectA(key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f.Annotations[operationTokenAnnotation]).Should(BeEmpty())
+
+			// Delete
+			By("Expecting to delete successfully")
+			Expect(deleteObjectA(key)).To(Succeed())
+
+			By("Expecting to delete finish")
+			waitUntilObjectMissingA(key)
+		})
+
+		It("should apply the defaults of the kind when webhooks are disabled", func() {
+			name := "a-" + RandomString(10)
+			key, created := nameAndSpecA(name)
+			created.Spec.Id = ""
+
+			// Create
+			Expect(k8sClient.Create(context.Background(), created)).Should(Succeed())
+			waitUntilReconcileStateA(key, reconciler.Succeeded)
+
+			By("Expecting the id to default to the namespace and name")
+			aId := key.Namespace + "/" + key.Name
+			f, err := getObjectA(key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f.Spec.Id).Should(Equal(aId))
+			Expect(resourceManager.GetRecord(aId).Events).To(ContainElement(manager.EventCreate))
+
+			// Delete
+			By("Expecting to delete successfully")
+			Expect(deleteObjectA(key)).To(Succeed())
+
+			By("Expecting to delete finish")
+			waitUntilObjectMissingA(key)
+		})
 	})
 })
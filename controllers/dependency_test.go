@@ -123,5 +123,27 @@ var _ = Describe("Test Dependencies", func() {
 			// now B should eventually succeed
 			waitUntilReconcileStateB(keyB, reconciler.Succeeded)
 		})
+
+		It("should ignore resources outside the label selector", func() {
+			bId := "b-" + RandomString(10)
+			ownerId := "a-" + RandomString(10)
+			keyA, createdA := nameAndSpecA(ownerId)
+			keyB, createdB := nameAndSpecB(bId, ownerId, []string{})
+			createdB.Labels = map[string]string{ignoredLabel: "true"}
+
+			Expect(k8sClient.Create(context.Background(), createdA)).Should(Succeed())
+			Expect(k8sClient.Create(context.Background(), createdB)).Should(Succeed())
+			waitUntilReconcileStateA(keyA, reconciler.Succeeded)
+
+			// B is never reconciled, so it has no state or finalizer
+			Consistently(func() bool {
+				f, _ := getObjectB(keyB)
+				return f.Status.State == "" && len(f.Finalizers) == 0
+			}, time.Second*2, interval).Should(BeTrue())
+			Expect(resourceManager.CountEvents(bId, manager.EventGet)).To(Equal(0))
+
+			Expect(deleteObjectB(keyB)).To(Succeed())
+			waitUntilObjectMissingB(keyB)
+		})
 	})
 })
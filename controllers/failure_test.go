@@ -126,6 +126,68 @@ var _ = Describe("Test Failure Scenarios", func() {
 			Expect(record.States).Should(Equal(expectedStates))
 		})
 
+		It("should fail if verification does not complete before the deadline", func() {
+			aId := "a-" + RandomString(10)
+			key, created := nameAndSpecWithAnnotationsA(aId, map[string]string{
+				verifyingTimeoutAnnotation: "1s",
+			})
+
+			// tell it to never finish creating
+			resourceManager.AddBehaviour(aId, manager.Behaviour{
+				Event:     manager.EventGet,
+				Operation: manager.VerifyStuckInProgress.AsOperation(),
+				From:      1,
+			})
+
+			// Create
+			Expect(k8sClient.Create(context.Background(), created)).Should(Succeed())
+			waitUntilReconcileStateA(key, reconciler.Verifying)
+			waitUntilReconcileStateA(key, reconciler.Failed)
+
+			f, err := getObjectA(key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f.Status.Message).Should(ContainSubstring("timed out"))
+
+			// Remove the behaviours
+			resourceManager.ClearBehaviours(aId)
+
+			// Delete
+			By("Expecting to delete successfully")
+			Expect(deleteObjectA(key)).Should(Succeed())
+
+			By("Expecting to delete finish")
+			waitUntilObjectMissingA(key)
+		})
+
+		It("should remove the finalizer if deletion does not complete before the deadline", func() {
+			aId := "a-" + RandomString(10)
+			key, created := nameAndSpecWithAnnotationsA(aId, map[string]string{
+				terminatingTimeoutAnnotation:    "1s",
+				terminationEscalationAnnotation: string(reconciler.TerminationEscalationRemoveFinalizer),
+			})
+
+			// tell it to never finish deleting
+			resourceManager.AddBehaviour(aId, manager.Behaviour{
+				Event:     manager.EventDelete,
+				Operation: manager.DeleteStuck.AsOperation(),
+			})
+
+			// Create
+			Expect(k8sClient.Create(context.Background(), created)).Should(Succeed())
+			waitUntilReconcileStateA(key, reconciler.Succeeded)
+
+			// Delete
+			By("Expecting to delete successfully")
+			Expect(deleteObjectA(key)).Should(Succeed())
+			waitUntilReconcileStateA(key, reconciler.Terminating)
+
+			By("Expecting the finalizer to be removed after the deadline")
+			waitUntilObjectMissingA(key)
+
+			record := resourceManager.GetRecord(aId)
+			Expect(record.States[len(record.States)-1]).Should(Equal(reconciler.VerifyResultDeleting))
+		})
+
 		It("should fail if fails to update", func() {
 			// TODO:
 		})
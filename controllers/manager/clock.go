@@ -0,0 +1,101 @@
+package manager
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock schedules the asynchronous transitions of the Manager
+type Clock interface {
+	Now() time.Time
+	// Calls f once d has elapsed
+	AfterFunc(d time.Duration, f func())
+}
+
+// RealClock schedules transitions in real time
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (RealClock) AfterFunc(d time.Duration, f func()) {
+	time.AfterFunc(d, f)
+}
+
+// FakeClock only moves when it is stepped, so the asynchronous transitions of the Manager can be stepped through deterministically
+type FakeClock struct {
+	lock    sync.Mutex
+	now     time.Time
+	pending []*scheduled
+	// orders functions scheduled for the same time by when they were scheduled
+	sequence int
+}
+
+type scheduled struct {
+	at       time.Time
+	sequence int
+	f        func()
+}
+
+func CreateFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.sequence++
+	c.pending = append(c.pending, &scheduled{at: c.now.Add(d), sequence: c.sequence, f: f})
+}
+
+// Step moves the clock forward, calling the functions that become due in the order they are due.
+// The functions are called on the calling goroutine, so their effects are visible once Step returns
+func (c *FakeClock) Step(d time.Duration) {
+	c.lock.Lock()
+	until := c.now.Add(d)
+	c.lock.Unlock()
+	for {
+		next := c.popDue(until)
+		if next == nil {
+			break
+		}
+		next.f()
+	}
+	c.lock.Lock()
+	c.now = until
+	c.lock.Unlock()
+}
+
+// Pending returns the number of functions that are not yet due
+func (c *FakeClock) Pending() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.pending)
+}
+
+// removes and returns the earliest function due by the time, moving the clock to when it is due
+func (c *FakeClock) popDue(until time.Time) *scheduled {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	sort.Slice(c.pending, func(i, j int) bool {
+		if c.pending[i].at.Equal(c.pending[j].at) {
+			return c.pending[i].sequence < c.pending[j].sequence
+		}
+		return c.pending[i].at.Before(c.pending[j].at)
+	})
+	if len(c.pending) == 0 || c.pending[0].at.After(until) {
+		return nil
+	}
+	next := c.pending[0]
+	c.pending = c.pending[1:]
+	c.now = next.at
+	return next
+}
@@ -3,6 +3,7 @@ package manager
 import (
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/operatify/operatify/reconciler"
@@ -18,29 +19,92 @@ const (
 )
 
 type Data struct {
-	States     []reconciler.VerifyResult
-	Events     []Event
-	Behaviours []Behaviour
+	States          []reconciler.VerifyResult
+	Events          []Event
+	Behaviours      []Behaviour
+	OperationTokens []string
+	// The spec of the resource last passed to SetSpec
+	Spec map[string]interface{}
+	// The status payload passed back for the resource, if one has been set
+	Status interface{}
 }
 
 func (sd *Data) Set(r reconciler.VerifyResult) {
 	sd.States = append(sd.States, r)
 }
 
+// Manager is a fake backend of external resources, keyed by id. It is safe for concurrent use,
+// and its asynchronous transitions are scheduled with its Clock
 type Manager struct {
+	lock      sync.Mutex
 	dataStore map[string]*Data
+	clock     Clock
+	random    *rand.Rand
+	// the behaviours of every id, such as those of scenarios, which apply if none of the behaviours of the id do
+	behaviours []Behaviour
 }
 
 func (m *Manager) Set(id string, r reconciler.VerifyResult) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	x := m.getOrCreate(id)
 	x.States = append(x.States, r)
 }
 
-func (m *Manager) addEvent(id string, event Event) {
+// SetAfter sets the state of the id once the delay has elapsed on the Clock of the manager,
+// unless the id is cleared in the meantime
+func (m *Manager) SetAfter(id string, r reconciler.VerifyResult, delay time.Duration) {
+	m.lock.Lock()
 	x := m.getOrCreate(id)
-	x.Events = append(x.Events, event)
+	m.lock.Unlock()
+	m.clock.AfterFunc(delay, func() {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		if m.dataStore[id] == x {
+			x.States = append(x.States, r)
+		}
+	})
+}
+
+// SetSpec records the spec of the resource, which the conditions of scenarios are evaluated against
+func (m *Manager) SetSpec(id string, spec map[string]interface{}) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.getOrCreate(id).Spec = spec
+}
+
+// SetStatus sets the status payload passed back for the resource
+func (m *Manager) SetStatus(id string, status interface{}) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.getOrCreate(id).Status = status
+}
+
+// GetStatus returns the status payload set for the resource, or nil if none has been set
+func (m *Manager) GetStatus(id string) interface{} {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if x := m.dataStore[id]; x != nil {
+		return x.Status
+	}
+	return nil
+}
+
+// Seed seeds the random numbers of the manager, so the delays and probabilistic behaviours are repeatable
+func (m *Manager) Seed(seed int64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.random = rand.New(rand.NewSource(seed))
 }
 
+func (m *Manager) addOperationToken(id string, operationToken string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	x := m.getOrCreate(id)
+	x.OperationTokens = append(x.OperationTokens, operationToken)
+}
+
+// must be called with the lock held
 func (m *Manager) getOrCreate(id string) *Data {
 	x := m.dataStore[id]
 	if x == nil {
@@ -54,15 +118,26 @@ func (m *Manager) getOrCreate(id string) *Data {
 }
 
 func CreateManager() *Manager {
-	return &Manager{dataStore: map[string]*Data{}}
+	return CreateManagerWithClock(RealClock{})
+}
+
+// CreateManagerWithClock creates a Manager whose asynchronous transitions are scheduled with the clock, such as a FakeClock
+func CreateManagerWithClock(clock Clock) *Manager {
+	return &Manager{
+		dataStore: map[string]*Data{},
+		clock:     clock,
+		random:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
 }
 
-func (m *Manager) Create(id string) (reconciler.ApplyResult, error) {
+func (m *Manager) Create(id string, operationToken string) (reconciler.ApplyResult, error) {
+	m.addOperationToken(id, operationToken)
 	result, err := m.apply(id, EventCreate)
 	return reconciler.ApplyResult(result), err
 }
 
-func (m *Manager) Update(id string) (reconciler.ApplyResult, error) {
+func (m *Manager) Update(id string, operationToken string) (reconciler.ApplyResult, error) {
+	m.addOperationToken(id, operationToken)
 	result, err := m.apply(id, EventUpdate)
 	return reconciler.ApplyResult(result), err
 }
@@ -77,41 +152,63 @@ func (m *Manager) Get(id string) (reconciler.VerifyResult, error) {
 	return reconciler.VerifyResult(result), err
 }
 
+// Clear removes the record of the id, and cancels its pending transitions
 func (m *Manager) Clear(id string) {
-	for k := range m.dataStore {
-		delete(m.dataStore, k)
-	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.dataStore, id)
 }
 
 func (m *Manager) AddBehaviour(id string, b Behaviour) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	x := m.getOrCreate(id)
 	x.Behaviours = append(x.Behaviours, b)
 }
 
+// AddBehaviourForAll adds a behaviour of every id, which applies if none of the behaviours of the id do
+func (m *Manager) AddBehaviourForAll(b Behaviour) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.behaviours = append(m.behaviours, b)
+}
+
 func (m *Manager) ClearBehaviours(id string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	x := m.getOrCreate(id)
 	x.Behaviours = []Behaviour{}
 }
 
+// GetRecord returns a copy of the record of the id, which isn't changed by later calls
 func (m *Manager) GetRecord(id string) *Data {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	r := m.dataStore[id]
 	if r == nil {
-		r = &Data{}
+		return &Data{}
+	}
+	return &Data{
+		States:          append([]reconciler.VerifyResult{}, r.States...),
+		Events:          append([]Event{}, r.Events...),
+		Behaviours:      append([]Behaviour{}, r.Behaviours...),
+		OperationTokens: append([]string{}, r.OperationTokens...),
+		Spec:            r.Spec,
+		Status:          r.Status,
 	}
-	return r
-}
-
-func (m *Manager) asyncUpdate(id string, newState reconciler.VerifyResult, d time.Duration) {
-	time.Sleep(d)
-	m.Set(id, newState)
 }
 
+// the operation is called without the lock held, as operations call the methods of the manager
 func (m *Manager) apply(id string, event Event) (string, error) {
+	m.lock.Lock()
 	operation := m.getOperation(id, event)
-	m.addEvent(id, event)
+	x := m.getOrCreate(id)
+	x.Events = append(x.Events, event)
+	m.lock.Unlock()
 	return operation(m, id)
 }
 
+// must be called with the lock held
 func (m *Manager) getOperation(id string, event Event) Operation {
 	x := m.getOrCreate(id)
 	// count the number of events of type Event
@@ -119,7 +216,7 @@ func (m *Manager) getOperation(id string, event Event) Operation {
 	var behaviour *Behaviour = nil
 	deleteIndex := -1
 	for i, b := range x.Behaviours {
-		if b.Event == event && b.From <= eventCount && (b.Count == 0 || b.From+b.Count > eventCount) {
+		if b.matches(id, x, event, eventCount) {
 			behaviour = &b
 			if b.OneTime {
 				deleteIndex = i
@@ -133,6 +230,11 @@ func (m *Manager) getOperation(id string, event Event) Operation {
 	if behaviour != nil {
 		return behaviour.Operation
 	}
+	for _, b := range m.behaviours {
+		if b.matches(id, x, event, eventCount) {
+			return b.Operation
+		}
+	}
 	switch event {
 	case EventCreate:
 		return CreateAsync.AsOperation()
@@ -147,6 +249,8 @@ func (m *Manager) getOperation(id string, event Event) Operation {
 }
 
 func (m *Manager) CountEvents(id string, event Event) int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
 	return m.countEvents(m.getOrCreate(id), event)
 }
 
@@ -166,6 +270,13 @@ type Behaviour struct {
 	From      int
 	Count     int
 	OneTime   bool
+	// If set, the behaviour only applies if this returns true. It is called with the record of the id
+	// while the manager is locked, so it must not call the manager
+	When func(id string, record *Data) bool
+}
+
+func (b *Behaviour) matches(id string, x *Data, event Event, eventCount int) bool {
+	return b.Event == event && b.From <= eventCount && (b.Count == 0 || b.From+b.Count > eventCount) && (b.When == nil || b.When(id, x))
 }
 
 func (x ApplyOperation) AsOperation() Operation {
@@ -194,8 +305,10 @@ const (
 	endMillis   = 400
 )
 
-func randomDelay(startMillis int, endMillis int) time.Duration {
-	delay := time.Duration(rand.Intn(startMillis)+(endMillis-startMillis)) * time.Millisecond
+func (m *Manager) randomDelay(startMillis int, endMillis int) time.Duration {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delay := time.Duration(m.random.Intn(startMillis)+(endMillis-startMillis)) * time.Millisecond
 	return delay
 }
 
@@ -207,7 +320,7 @@ type DeleteOperation func(m *Manager, id string) (reconciler.DeleteResult, error
 // Include operations below
 var CreateAsync ApplyOperation = func(m *Manager, id string) (reconciler.ApplyResult, error) {
 	m.Set(id, reconciler.VerifyResultInProgress)
-	go m.asyncUpdate(id, reconciler.VerifyResultReady, randomDelay(startMillis, endMillis))
+	m.SetAfter(id, reconciler.VerifyResultReady, m.randomDelay(startMillis, endMillis))
 	return reconciler.ApplyResultAwaitingVerification, nil
 }
 
@@ -231,7 +344,7 @@ var UpdateAsync = CreateAsync
 
 var DeleteAsync DeleteOperation = func(m *Manager, id string) (reconciler.DeleteResult, error) {
 	m.Set(id, reconciler.VerifyResultDeleting)
-	go m.asyncUpdate(id, reconciler.VerifyResultMissing, randomDelay(startMillis, endMillis))
+	m.SetAfter(id, reconciler.VerifyResultMissing, m.randomDelay(startMillis, endMillis))
 	return reconciler.DeleteAwaitingVerification, nil
 }
 
@@ -258,8 +371,17 @@ var VerifyNeedsUpdate GetOperation = func(m *Manager, id string) (reconciler.Ver
 	return reconciler.VerifyResultUpdateRequired, nil
 }
 
+var VerifyStuckInProgress GetOperation = func(m *Manager, id string) (reconciler.VerifyResult, error) {
+	return reconciler.VerifyResultInProgress, nil
+}
+
+var DeleteStuck DeleteOperation = func(m *Manager, id string) (reconciler.DeleteResult, error) {
+	m.Set(id, reconciler.VerifyResultDeleting)
+	return reconciler.DeleteAwaitingVerification, nil
+}
+
 var CreateCompleteFail ApplyOperation = func(m *Manager, id string) (reconciler.ApplyResult, error) {
 	m.Set(id, reconciler.VerifyResultInProgress)
-	go m.asyncUpdate(id, reconciler.VerifyResultError, randomDelay(startMillis, endMillis))
+	m.SetAfter(id, reconciler.VerifyResultError, m.randomDelay(startMillis, endMillis))
 	return reconciler.ApplyResultAwaitingVerification, nil
 }
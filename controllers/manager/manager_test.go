@@ -0,0 +1,78 @@
+package manager
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/operatify/operatify/reconciler"
+)
+
+var _ = Describe("Fake Manager", func() {
+
+	var clock *FakeClock
+	var m *Manager
+
+	BeforeEach(func() {
+		clock = CreateFakeClock(time.Unix(0, 0))
+		m = CreateManagerWithClock(clock)
+	})
+
+	It("steps asynchronous transitions with the clock", func() {
+		result, err := m.Create("a", "token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(reconciler.ApplyResultAwaitingVerification))
+		Expect(m.Get("a")).To(Equal(reconciler.VerifyResultInProgress))
+
+		clock.Step(startMillis * time.Millisecond)
+		Expect(m.Get("a")).To(Equal(reconciler.VerifyResultInProgress))
+		clock.Step(endMillis * time.Millisecond)
+		Expect(m.Get("a")).To(Equal(reconciler.VerifyResultReady))
+
+		Expect(m.Delete("a")).To(Equal(reconciler.DeleteAwaitingVerification))
+		Expect(m.Get("a")).To(Equal(reconciler.VerifyResultDeleting))
+		clock.Step(endMillis * time.Millisecond)
+		Expect(m.Get("a")).To(Equal(reconciler.VerifyResultMissing))
+		Expect(clock.Pending()).To(Equal(0))
+	})
+
+	It("clears only the record of the id, with its pending transitions", func() {
+		_, _ = m.Create("a", "token-a")
+		_, _ = m.Create("b", "token-b")
+
+		m.Clear("a")
+		clock.Step(endMillis * time.Millisecond)
+		Expect(m.GetRecord("a").States).To(BeEmpty())
+		Expect(m.GetRecord("b").States).To(Equal([]reconciler.VerifyResult{reconciler.VerifyResultInProgress, reconciler.VerifyResultReady}))
+		Expect(m.GetRecord("b").OperationTokens).To(Equal([]string{"token-b"}))
+	})
+
+	It("returns records that aren't changed by later calls", func() {
+		_, _ = m.Create("a", "token")
+		record := m.GetRecord("a")
+		clock.Step(endMillis * time.Millisecond)
+		Expect(record.States).To(HaveLen(1))
+		Expect(m.GetRecord("a").States).To(HaveLen(2))
+	})
+
+	It("is safe for concurrent use", func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				defer GinkgoRecover()
+				_, _ = m.Create(id, "token")
+				for j := 0; j < 10; j++ {
+					_, _ = m.Get(id)
+					clock.Step(50 * time.Millisecond)
+				}
+				Expect(m.Get(id)).To(Equal(reconciler.VerifyResultReady))
+				Expect(m.CountEvents(id, EventGet)).To(Equal(11))
+			}(fmt.Sprintf("id-%d", i))
+		}
+		wg.Wait()
+	})
+})
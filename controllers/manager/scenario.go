@@ -0,0 +1,349 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/operatify/operatify/reconciler"
+	"sigs.k8s.io/yaml"
+)
+
+// ScenarioFile is the YAML file scenarios are loaded from, such as
+//
+//	scenarios:
+//	- name: flaky-creates
+//	  ids: ["a-*"]
+//	  rules:
+//	  - event: Create
+//	    probability: 0.3
+//	    latency: {distribution: uniform, min: 100, max: 2000}
+//	    results:
+//	    - {result: Error, state: Error, error: "quota exceeded"}
+type ScenarioFile struct {
+	Scenarios []Scenario `json:"scenarios"`
+}
+
+// Scenario is a set of rules injecting faults into the resources of the Manager
+type Scenario struct {
+	Name string `json:"name"`
+	// Patterns, such as "a-*", matched against the ids of resources with path.Match. The scenario applies to every id if this is empty
+	Ids   []string       `json:"ids,omitempty"`
+	Rules []ScenarioRule `json:"rules"`
+}
+
+// ScenarioRule replaces the default operation of an event when its conditions hold. The first matching rule of a scenario applies
+type ScenarioRule struct {
+	Event Event `json:"event"`
+	// As for a Behaviour, the rule applies from the From'th event of the id for Count events (forever if Count is 0)
+	From  int `json:"from,omitempty"`
+	Count int `json:"count,omitempty"`
+	// Spec fields, such as "stringData" or "providerConfigRef.name", and the values they must have for the rule to apply
+	When map[string]string `json:"when,omitempty"`
+	// The probability the rule applies to an event (always if not set)
+	Probability *float64 `json:"probability,omitempty"`
+	// How long the call takes
+	Latency *Latency `json:"latency,omitempty"`
+	// The results of the events the rule applies to, in turn. The last result is repeated unless Cycle is set
+	Results []ScenarioResult `json:"results"`
+	Cycle   bool             `json:"cycle,omitempty"`
+}
+
+// Latency is a distribution of delays in milliseconds
+type Latency struct {
+	// fixed (the default), uniform, normal or exponential
+	Distribution string `json:"distribution,omitempty"`
+	// The delay of fixed latencies, and the mean of normal and exponential latencies
+	Millis int `json:"millis,omitempty"`
+	// The bounds of uniform latencies, which also bound normal and exponential latencies if they are set
+	Min int `json:"min,omitempty"`
+	Max int `json:"max,omitempty"`
+	// The standard deviation of normal latencies
+	StdDev int `json:"stdDev,omitempty"`
+}
+
+// ScenarioResult is the outcome of an event
+type ScenarioResult struct {
+	// The ApplyResult, VerifyResult or DeleteResult returned, depending on the event.
+	// For Get events the last state of the resource is returned if this is empty
+	Result string `json:"result,omitempty"`
+	// If set, the call fails with this message
+	Error string `json:"error,omitempty"`
+	// If set, the state of the resource is set to this
+	State reconciler.VerifyResult `json:"state,omitempty"`
+	// If set, the state of the resource is set once the latency has elapsed, such as to complete an asynchronous create
+	Then *Transition `json:"then,omitempty"`
+	// If set, the status payload passed back for the resource is set to this
+	Status interface{} `json:"status,omitempty"`
+}
+
+// Transition is a change of state after a delay
+type Transition struct {
+	State reconciler.VerifyResult `json:"state"`
+	After Latency                 `json:"after,omitempty"`
+}
+
+var (
+	applyResults  = []string{string(reconciler.ApplyResultAwaitingVerification), string(reconciler.ApplyResultSucceeded), string(reconciler.ApplyResultError)}
+	verifyResults = []string{string(reconciler.VerifyResultMissing), string(reconciler.VerifyResultRecreateRequired), string(reconciler.VerifyResultUpdateRequired),
+		string(reconciler.VerifyResultInProgress), string(reconciler.VerifyResultDeleting), string(reconciler.VerifyResultReady), string(reconciler.VerifyResultError)}
+	deleteResults = []string{string(reconciler.DeleteAlreadyDeleted), string(reconciler.DeleteSucceeded), string(reconciler.DeleteAwaitingVerification), string(reconciler.DeleteError)}
+)
+
+// LoadScenarios loads the scenarios of a ScenarioFile
+func LoadScenarios(path string) ([]Scenario, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	scenarios, err := ParseScenarios(b)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load %s: %v", path, err)
+	}
+	return scenarios, nil
+}
+
+// ParseScenarios parses the scenarios of a ScenarioFile
+func ParseScenarios(b []byte) ([]Scenario, error) {
+	file := &ScenarioFile{}
+	if err := yaml.UnmarshalStrict(b, file); err != nil {
+		return nil, err
+	}
+	for _, s := range file.Scenarios {
+		if err := s.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return file.Scenarios, nil
+}
+
+func (s *Scenario) validate() error {
+	for _, pattern := range s.Ids {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid id pattern %q in scenario %s: %v", pattern, s.Name, err)
+		}
+	}
+	for i, rule := range s.Rules {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("invalid rule %d of scenario %s: %v", i, s.Name, err)
+		}
+	}
+	return nil
+}
+
+func (r *ScenarioRule) validate() error {
+	var results []string
+	switch r.Event {
+	case EventCreate, EventUpdate:
+		results = applyResults
+	case EventGet:
+		results = verifyResults
+	case EventDelete:
+		results = deleteResults
+	default:
+		return fmt.Errorf("unknown event %q", r.Event)
+	}
+	if r.Probability != nil && (*r.Probability < 0 || *r.Probability > 1) {
+		return fmt.Errorf("probability %v is not between 0 and 1", *r.Probability)
+	}
+	if len(r.Results) == 0 {
+		return fmt.Errorf("no results")
+	}
+	if r.Latency != nil {
+		if err := r.Latency.validate(); err != nil {
+			return err
+		}
+	}
+	for _, result := range r.Results {
+		if result.Result == "" && r.Event != EventGet {
+			return fmt.Errorf("no result for %s event", r.Event)
+		}
+		if result.Result != "" && !contains(results, result.Result) {
+			return fmt.Errorf("%q is not a result of a %s event, expected one of %s", result.Result, r.Event, strings.Join(results, ", "))
+		}
+		for _, state := range []reconciler.VerifyResult{result.State, result.thenState()} {
+			if state != "" && !contains(verifyResults, string(state)) {
+				return fmt.Errorf("unknown state %q, expected one of %s", state, strings.Join(verifyResults, ", "))
+			}
+		}
+		if result.Then != nil {
+			if err := result.Then.After.validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (l *Latency) validate() error {
+	switch l.Distribution {
+	case "", "fixed", "normal", "exponential":
+	case "uniform":
+		if l.Max < l.Min {
+			return fmt.Errorf("the maximum %d of a uniform latency is less than its minimum %d", l.Max, l.Min)
+		}
+	default:
+		return fmt.Errorf("unknown latency distribution %q, expected fixed, uniform, normal or exponential", l.Distribution)
+	}
+	return nil
+}
+
+func (r *ScenarioResult) thenState() reconciler.VerifyResult {
+	if r.Then == nil {
+		return ""
+	}
+	return r.Then.State
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// AddScenario adds the rules of the scenario as behaviours of every id it applies to.
+// These apply if none of the behaviours added to an id with AddBehaviour do
+func (m *Manager) AddScenario(s Scenario) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+	for _, rule := range s.Rules {
+		m.AddBehaviourForAll(m.compile(s.Ids, rule))
+	}
+	return nil
+}
+
+func (m *Manager) compile(ids []string, rule ScenarioRule) Behaviour {
+	sequence := &resultSequence{rule: rule, counts: map[string]int{}}
+	return Behaviour{
+		Event: rule.Event,
+		From:  rule.From,
+		Count: rule.Count,
+		// called with the lock of the manager held, so the random numbers of the manager can be used
+		When: func(id string, record *Data) bool {
+			if !matchesId(ids, id) || !matchesSpec(rule.When, record.Spec) {
+				return false
+			}
+			return rule.Probability == nil || m.random.Float64() < *rule.Probability
+		},
+		Operation: func(m *Manager, id string) (string, error) {
+			if rule.Latency != nil {
+				m.wait(m.sample(*rule.Latency))
+			}
+			return sequence.next(id).apply(m, id, rule.Event)
+		},
+	}
+}
+
+func matchesId(patterns []string, id string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, id); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesSpec(conditions map[string]string, spec map[string]interface{}) bool {
+	for field, value := range conditions {
+		var current interface{} = spec
+		for _, name := range strings.Split(field, ".") {
+			fields, ok := current.(map[string]interface{})
+			if !ok {
+				current = nil
+				break
+			}
+			current = fields[name]
+		}
+		if current == nil || fmt.Sprint(current) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// resultSequence hands out the results of a rule in turn, separately for each id
+type resultSequence struct {
+	lock   sync.Mutex
+	rule   ScenarioRule
+	counts map[string]int
+}
+
+func (s *resultSequence) next(id string) ScenarioResult {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	i := s.counts[id]
+	s.counts[id] = i + 1
+	if i >= len(s.rule.Results) {
+		if s.rule.Cycle {
+			i = i % len(s.rule.Results)
+		} else {
+			i = len(s.rule.Results) - 1
+		}
+	}
+	return s.rule.Results[i]
+}
+
+func (r ScenarioResult) apply(m *Manager, id string, event Event) (string, error) {
+	if r.State != "" {
+		m.Set(id, r.State)
+	}
+	if r.Then != nil {
+		m.SetAfter(id, r.Then.State, m.sample(r.Then.After))
+	}
+	if r.Status != nil {
+		m.SetStatus(id, r.Status)
+	}
+	result := r.Result
+	if result == "" && event == EventGet {
+		verified, _ := GetStandard(m, id)
+		result = string(verified)
+	}
+	if r.Error != "" {
+		return result, errors.New(r.Error)
+	}
+	return result, nil
+}
+
+// wait blocks until the delay has elapsed on the Clock of the manager
+func (m *Manager) wait(delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	done := make(chan struct{})
+	m.clock.AfterFunc(delay, func() { close(done) })
+	<-done
+}
+
+// sample returns a delay from the latency distribution
+func (m *Manager) sample(l Latency) time.Duration {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var millis float64
+	switch l.Distribution {
+	case "uniform":
+		millis = float64(l.Min) + m.random.Float64()*float64(l.Max-l.Min)
+	case "normal":
+		millis = float64(l.Millis) + m.random.NormFloat64()*float64(l.StdDev)
+	case "exponential":
+		millis = m.random.ExpFloat64() * float64(l.Millis)
+	default:
+		millis = float64(l.Millis)
+	}
+	if l.Max > 0 {
+		millis = math.Min(millis, float64(l.Max))
+	}
+	millis = math.Max(millis, float64(l.Min))
+	return time.Duration(millis * float64(time.Millisecond))
+}
@@ -0,0 +1,186 @@
+package manager
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/operatify/operatify/reconciler"
+)
+
+var _ = Describe("Fault-injection scenarios", func() {
+
+	var clock *FakeClock
+	var m *Manager
+
+	BeforeEach(func() {
+		clock = CreateFakeClock(time.Unix(0, 0))
+		m = CreateManagerWithClock(clock)
+		m.Seed(1)
+	})
+
+	addScenarios := func(yaml string) {
+		scenarios, err := ParseScenarios([]byte(yaml))
+		Expect(err).NotTo(HaveOccurred())
+		for _, s := range scenarios {
+			Expect(m.AddScenario(s)).To(Succeed())
+		}
+	}
+
+	It("returns a sequence of results for each id", func() {
+		addScenarios(`
+scenarios:
+- name: retries
+  ids: ["a-*"]
+  rules:
+  - event: Create
+    results:
+    - {result: Error, state: Error, error: quota exceeded}
+    - {result: Succeeded, state: Ready}
+`)
+		result, err := m.Create("a-1", "token")
+		Expect(err).To(MatchError("quota exceeded"))
+		Expect(result).To(Equal(reconciler.ApplyResultError))
+		Expect(m.Get("a-1")).To(Equal(reconciler.VerifyResultError))
+
+		Expect(m.Create("a-1", "token")).To(Equal(reconciler.ApplyResultSucceeded))
+		Expect(m.Create("a-1", "token")).To(Equal(reconciler.ApplyResultSucceeded))
+		Expect(m.Get("a-1")).To(Equal(reconciler.VerifyResultReady))
+
+		// each id has its own sequence
+		_, err = m.Create("a-2", "token")
+		Expect(err).To(HaveOccurred())
+
+		// ids not matching the scenario have the default behaviour
+		Expect(m.Create("b-1", "token")).To(Equal(reconciler.ApplyResultAwaitingVerification))
+	})
+
+	It("cycles through the results", func() {
+		addScenarios(`
+scenarios:
+- name: flapping
+  rules:
+  - event: Get
+    cycle: true
+    results:
+    - {result: Ready}
+    - {result: InProgress}
+`)
+		Expect(m.Get("a")).To(Equal(reconciler.VerifyResultReady))
+		Expect(m.Get("a")).To(Equal(reconciler.VerifyResultInProgress))
+		Expect(m.Get("a")).To(Equal(reconciler.VerifyResultReady))
+	})
+
+	It("only applies rules whose spec conditions hold", func() {
+		addScenarios(`
+scenarios:
+- name: bad-data
+  rules:
+  - event: Update
+    when: {stringData: broken, intData: "3"}
+    results:
+    - {result: Error, error: invalid data}
+`)
+		m.SetSpec("a", map[string]interface{}{"stringData": "broken", "intData": int64(3)})
+		_, err := m.Update("a", "token")
+		Expect(err).To(MatchError("invalid data"))
+
+		m.SetSpec("a", map[string]interface{}{"stringData": "broken", "intData": int64(4)})
+		Expect(m.Update("a", "token")).To(Equal(reconciler.ApplyResultSucceeded))
+	})
+
+	It("applies rules with their probability", func() {
+		addScenarios(`
+scenarios:
+- name: flaky
+  rules:
+  - event: Delete
+    probability: 0.5
+    results:
+    - {result: Error, error: throttled}
+`)
+		failures := 0
+		for i := 0; i < 200; i++ {
+			if _, err := m.Delete("a"); err != nil {
+				failures++
+			}
+		}
+		Expect(failures).To(BeNumerically("~", 100, 30))
+	})
+
+	It("delays calls and transitions with the clock", func() {
+		addScenarios(`
+scenarios:
+- name: slow
+  rules:
+  - event: Create
+    latency: {millis: 500}
+    results:
+    - result: AwaitingVerification
+      state: InProgress
+      then: {state: Ready, after: {distribution: uniform, min: 1000, max: 2000}}
+      status: {endpoint: https://example.com}
+`)
+		done := make(chan reconciler.ApplyResult)
+		go func() {
+			result, _ := m.Create("a", "token")
+			done <- result
+		}()
+		Eventually(clock.Pending).Should(Equal(1))
+		Consistently(done).ShouldNot(Receive())
+
+		clock.Step(500 * time.Millisecond)
+		Eventually(done).Should(Receive(Equal(reconciler.ApplyResultAwaitingVerification)))
+		Expect(m.Get("a")).To(Equal(reconciler.VerifyResultInProgress))
+		Expect(m.GetStatus("a")).To(Equal(map[string]interface{}{"endpoint": "https://example.com"}))
+
+		clock.Step(2000 * time.Millisecond)
+		Expect(m.Get("a")).To(Equal(reconciler.VerifyResultReady))
+	})
+
+	It("prefers the behaviours of an id", func() {
+		addScenarios(`
+scenarios:
+- name: failing
+  rules:
+  - event: Create
+    results:
+    - {result: Error, error: failed}
+`)
+		m.AddBehaviour("a", Behaviour{Event: EventCreate, Operation: CreateSync.AsOperation()})
+		Expect(m.Create("a", "token")).To(Equal(reconciler.ApplyResultSucceeded))
+	})
+
+	It("rejects invalid scenarios", func() {
+		_, err := ParseScenarios([]byte(`
+scenarios:
+- name: invalid
+  rules:
+  - event: Delete
+    results:
+    - {result: Ready}
+`))
+		Expect(err).To(MatchError(ContainSubstring(`"Ready" is not a result of a Delete event`)))
+
+		_, err = ParseScenarios([]byte(`
+scenarios:
+- name: invalid
+  rules:
+  - event: Get
+    latency: {distribution: pareto}
+    results:
+    - {}
+`))
+		Expect(err).To(MatchError(ContainSubstring("unknown latency distribution")))
+
+		_, err = ParseScenarios([]byte(`
+scenarios:
+- name: invalid
+  rules:
+  - event: Get
+    results:
+    - {reslt: Ready}
+`))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -97,6 +97,24 @@ var _ = Describe("Test permissions", func() {
 			Expect(updated.Status.Message).To(HavePrefix("permission to update external resource is not set"))
 		})
 
+		It("should read and migrate a legacy permissions annotation", func() {
+			aId := "a-" + RandomString(10)
+			legacyAnnotation := legacyAnnotationBaseName + reconciler.AccessPermissionAnnotation
+			key, created := nameAndSpecWithAnnotationsA(aId, map[string]string{legacyAnnotation: "none"})
+
+			// Create
+			Expect(k8sClient.Create(context.Background(), created)).Should(Succeed())
+			waitUntilReconcileStateA(key, reconciler.Failed)
+
+			record := resourceManager.GetRecord(aId)
+			Expect(record.Events).To(Not(ContainElement(manager.EventCreate)))
+
+			// the annotation is moved to the current base name when the state is updated
+			object, _ := getObjectA(key)
+			Expect(object.Annotations).To(HaveKeyWithValue(accessPermissionAnnotation, "none"))
+			Expect(object.Annotations).To(Not(HaveKey(legacyAnnotation)))
+		})
+
 		It("should delete if delete permission present", func() {
 			aId := "a-" + RandomString(10)
 			key, created := nameAndSpecWithAnnotationsA(aId, map[string]string{accessPermissionAnnotation: "CD"})
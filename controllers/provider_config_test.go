@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apiv1 "github.com/operatify/operatify/api/v1alpha1"
+	"github.com/operatify/operatify/controllers/manager"
+	"github.com/operatify/operatify/reconciler"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Provider config", func() {
+
+	createProviderConfig := func(name string, endpoint string, token string) {
+		secret := &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "default"},
+			Data:       map[string][]byte{"token": []byte(token)},
+		}
+		Expect(k8sClient.Create(context.Background(), secret)).Should(Succeed())
+		providerConfig := &apiv1.ClusterProviderConfig{
+			ObjectMeta: v1.ObjectMeta{Name: name},
+			Spec: apiv1.ProviderConfigSpec{
+				Endpoint:             endpoint,
+				CredentialsSecretRef: &corev1.SecretReference{Name: name, Namespace: "default"},
+			},
+		}
+		Expect(k8sClient.Create(context.Background(), providerConfig)).Should(Succeed())
+	}
+
+	createWithProviderConfigA := func(aId string, providerConfigName string) types.NamespacedName {
+		key, created := nameAndSpecA(aId)
+		created.Spec.ProviderConfigRef = &corev1.TypedLocalObjectReference{Kind: "ClusterProviderConfig", Name: providerConfigName}
+		Expect(k8sClient.Create(context.Background(), created)).Should(Succeed())
+		return key
+	}
+
+	It("should manage the resource with the ResourceManager of its provider config", func() {
+		aId := "a-" + RandomString(10)
+		providerConfigName := "p-" + RandomString(10)
+		endpoint := "https://" + providerConfigName + ".example.com"
+		createProviderConfig(providerConfigName, endpoint, "secret")
+
+		key := createWithProviderConfigA(aId, providerConfigName)
+		waitUntilReconcileStateA(key, reconciler.Succeeded)
+
+		Expect(providerBackend(endpoint).GetRecord(aId).Events).To(ContainElement(manager.EventCreate))
+		Expect(resourceManager.CountEvents(aId, manager.EventCreate)).To(Equal(0))
+
+		Expect(deleteObjectA(key)).To(Succeed())
+		waitUntilObjectMissingA(key)
+		Expect(providerBackend(endpoint).GetRecord(aId).Events).To(ContainElement(manager.EventDelete))
+	})
+
+	It("should be pending until its provider config can be resolved", func() {
+		aId := "a-" + RandomString(10)
+		providerConfigName := "p-" + RandomString(10)
+		endpoint := "https://" + providerConfigName + ".example.com"
+
+		key := createWithProviderConfigA(aId, providerConfigName)
+		waitUntilReconcileStateA(key, reconciler.Pending)
+		object, _ := getObjectA(key)
+		Expect(object.Status.Message).To(ContainSubstring("not found"))
+
+		createProviderConfig(providerConfigName, endpoint, "secret")
+		waitUntilReconcileStateA(key, reconciler.Succeeded)
+
+		Expect(deleteObjectA(key)).To(Succeed())
+		waitUntilObjectMissingA(key)
+	})
+})
@@ -0,0 +1,29 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shared
+
+import (
+	"github.com/operatify/operatify/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// +kubebuilder:rbac:groups=test.stephenzoio.com,resources=providerconfigs;clusterproviderconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+// ProviderConfigKinds returns empty instances of the kinds the resources can reference with spec.providerConfigRef
+func ProviderConfigKinds() []runtime.Object {
+	return []runtime.Object{&v1alpha1.ProviderConfig{}, &v1alpha1.ClusterProviderConfig{}}
+}
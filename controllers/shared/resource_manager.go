@@ -17,8 +17,10 @@ package shared
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/operatify/operatify/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 
 	"github.com/go-logr/logr"
@@ -29,6 +31,39 @@ import (
 
 type SpecGetter func(object runtime.Object) (*v1alpha1.Spec, error)
 
+// AsSpecGetter converts the spec getter of a reconciler.DefinitionBuilder to a SpecGetter,
+// for kinds whose Spec embeds the shared Spec
+func AsSpecGetter(getSpec reconciler.SpecGetter) SpecGetter {
+	return func(object runtime.Object) (*v1alpha1.Spec, error) {
+		spec, err := getSpec(object)
+		if err != nil {
+			return nil, err
+		}
+		holder, ok := spec.(interface{ SharedSpec() *v1alpha1.Spec })
+		if !ok {
+			return nil, fmt.Errorf("spec of %T does not embed the shared Spec", object)
+		}
+		return holder.SharedSpec(), nil
+	}
+}
+
+// UnstructuredSpecGetter is a SpecGetter for unstructured resources whose spec has the fields of the shared Spec
+func UnstructuredSpecGetter(object runtime.Object) (*v1alpha1.Spec, error) {
+	u, ok := object.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("%T is not unstructured", object)
+	}
+	spec := &v1alpha1.Spec{}
+	specMap, _, err := unstructured.NestedMap(u.Object, "spec")
+	if err != nil {
+		return nil, err
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(specMap, spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
 type ResourceManager struct {
 	Logger     logr.Logger
 	Recorder   record.EventRecorder
@@ -51,10 +86,11 @@ func (r *ResourceManager) Create(ctx context.Context, s reconciler.ResourceSpec)
 		return reconciler.ApplyError, err
 	}
 
-	result, err := r.Manager.Create(spec.Id)
+	r.setSpec(spec)
+	result, err := r.Manager.Create(spec.Id, s.OperationToken)
 	return reconciler.ApplyResponse{
 		Result: result,
-		Status: &spec,
+		Status: r.status(spec),
 	}, err
 }
 
@@ -64,10 +100,11 @@ func (r *ResourceManager) Update(ctx context.Context, s reconciler.ResourceSpec)
 		return reconciler.ApplyError, err
 	}
 
-	result, err := r.Manager.Update(spec.Id)
+	r.setSpec(spec)
+	result, err := r.Manager.Update(spec.Id, s.OperationToken)
 	return reconciler.ApplyResponse{
 		Result: result,
-		Status: &spec,
+		Status: r.status(spec),
 	}, err
 }
 
@@ -77,10 +114,11 @@ func (r *ResourceManager) Verify(ctx context.Context, s reconciler.ResourceSpec)
 		return reconciler.VerifyError, err
 	}
 
+	r.setSpec(spec)
 	result, err := r.Manager.Get(spec.Id)
 	return reconciler.VerifyResponse{
 		Result: result,
-		Status: &spec,
+		Status: r.status(spec),
 	}, err
 }
 
@@ -90,5 +128,24 @@ func (r *ResourceManager) Delete(ctx context.Context, s reconciler.ResourceSpec)
 		return reconciler.DeleteError, err
 	}
 
+	r.setSpec(spec)
 	return r.Manager.Delete(spec.Id)
 }
+
+// setSpec records the spec with the manager, which the conditions of its scenarios are evaluated against
+func (r *ResourceManager) setSpec(spec *v1alpha1.Spec) {
+	fields, err := runtime.DefaultUnstructuredConverter.ToUnstructured(spec)
+	if err != nil {
+		r.Logger.Info(fmt.Sprintf("Unable to record spec of %s: %v", spec.Id, err))
+		return
+	}
+	r.Manager.SetSpec(spec.Id, fields)
+}
+
+// status returns the status payload a scenario has set for the resource, or the spec if none has been set
+func (r *ResourceManager) status(spec *v1alpha1.Spec) interface{} {
+	if status := r.Manager.GetStatus(spec.Id); status != nil {
+		return status
+	}
+	return &spec
+}
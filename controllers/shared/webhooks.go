@@ -0,0 +1,68 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package shared
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/operatify/operatify/reconciler"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Validator validates the shared Spec of a kind: the Id is required, and cannot be changed
+type Validator struct {
+	SpecGetter SpecGetter
+}
+
+func (v *Validator) ValidateCreate(ctx context.Context, instance runtime.Object) error {
+	spec, err := v.SpecGetter(instance)
+	if err != nil {
+		return err
+	}
+	if spec.Id == "" {
+		return fmt.Errorf("spec.id is required")
+	}
+	return nil
+}
+
+func (v *Validator) ValidateUpdate(ctx context.Context, oldInstance runtime.Object, instance runtime.Object) error {
+	if err := v.ValidateCreate(ctx, instance); err != nil {
+		return err
+	}
+	return reconciler.CheckImmutableFields(oldInstance, instance, "spec.id")
+}
+
+// Defaulter sets the defaults of the shared Spec of a kind: the Id defaults to <namespace>/<name>
+type Defaulter struct {
+	SpecGetter SpecGetter
+}
+
+func (d *Defaulter) Default(ctx context.Context, instance runtime.Object) error {
+	spec, err := d.SpecGetter(instance)
+	if err != nil {
+		return err
+	}
+	if spec.Id == "" {
+		meta, err := apimeta.Accessor(instance)
+		if err != nil {
+			return err
+		}
+		spec.Id = meta.GetNamespace() + "/" + meta.GetName()
+	}
+	return nil
+}
@@ -16,8 +16,10 @@ limitations under the License.
 package controllers
 
 import (
+	"fmt"
 	"math/rand"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -30,9 +32,12 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/go-logr/logr"
 	testv1alpha1 "github.com/operatify/operatify/api/v1alpha1"
+	testv1beta1 "github.com/operatify/operatify/api/v1beta1"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
@@ -54,8 +59,42 @@ const interval = time.Millisecond * 100
 
 var accessPermissionAnnotation = shared.AnnotationBaseName + reconciler.AccessPermissionAnnotation
 
+// BTest resources with this label set to true are outside the label selector of the controller
+const ignoredLabel = "test.stephenzoio.com/ignored"
+
+// the annotations of ATest were previously set under this base name
+const legacyAnnotationBaseName = "legacy.stephenzoio.com"
+
+var operationTokenAnnotation = shared.AnnotationBaseName + reconciler.OperationTokenAnnotation
+var verifyingTimeoutAnnotation = shared.AnnotationBaseName + reconciler.VerifyingTimeoutAnnotation
+var terminatingTimeoutAnnotation = shared.AnnotationBaseName + reconciler.TerminatingTimeoutAnnotation
+var terminationEscalationAnnotation = shared.AnnotationBaseName + reconciler.TerminationEscalationAnnotation
+var controllerClassAnnotation = shared.AnnotationBaseName + reconciler.ControllerClassAnnotation
+var managingControllerClassAnnotation = shared.AnnotationBaseName + reconciler.ManagingControllerClassAnnotation
+
 var resourceManager = manager.CreateManager()
 
+// ATest resources referencing a provider config are managed in a fake backend of their own for each endpoint
+var providerBackends = map[string]*manager.Manager{}
+var providerBackendsLock sync.Mutex
+
+func providerBackend(endpoint string) *manager.Manager {
+	providerBackendsLock.Lock()
+	defer providerBackendsLock.Unlock()
+	if _, ok := providerBackends[endpoint]; !ok {
+		providerBackends[endpoint] = manager.CreateManager()
+	}
+	return providerBackends[endpoint]
+}
+
+func createProviderResourceManagerA(logger logr.Logger, recorder record.EventRecorder, providerConfig *reconciler.ProviderConfig) (reconciler.ResourceManager, error) {
+	if len(providerConfig.Credentials["token"]) == 0 {
+		return nil, fmt.Errorf("no token in the credentials of %s", providerConfig.Name)
+	}
+	resourceManagerClient := a.CreateResourceManager(logger, recorder, providerBackend(providerConfig.Endpoint))
+	return &resourceManagerClient, nil
+}
+
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
 
@@ -79,6 +118,8 @@ var _ = BeforeSuite(func(done Done) {
 
 	err = testv1alpha1.AddToScheme(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
+	err = testv1beta1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
 
 	// +kubebuilder:scaffold:scheme
 	k8sManager, err := ctrl.NewManager(cfg, ctrl.Options{
@@ -93,23 +134,27 @@ var _ = BeforeSuite(func(done Done) {
 	Expect(k8sClient).ToNot(BeNil())
 
 	// Create test controllers
-	err = (&a.ControllerFactory{
-		ResourceManagerCreator: a.CreateResourceManager,
-		Scheme:                 scheme.Scheme,
-		Manager:                resourceManager,
+	_, err = (&a.ControllerFactory{
+		ControllerFactory: reconciler.ControllerFactory{
+			LegacyAnnotationBaseNames:      []string{legacyAnnotationBaseName},
+			ProviderResourceManagerCreator: createProviderResourceManagerA,
+		},
+		Manager: resourceManager,
 	}).SetupWithManager(k8sManager, reconciler.ReconcileParameters{
 		RequeueAfter: 100,
 	}, nil)
 	Expect(err).ToNot(HaveOccurred())
 
-	err = (&b.ControllerFactory{
-		ResourceManagerCreator: b.CreateResourceManager,
-		Scheme:                 scheme.Scheme,
-		Manager:                resourceManager,
+	_, err = (&b.ControllerFactory{
+		ControllerFactory: reconciler.ControllerFactory{
+			ImmutableFields: []string{"spec.intData"},
+		},
+		Manager: resourceManager,
 	}).SetupWithManager(k8sManager, reconciler.ReconcileParameters{
 		RequeueAfter:        100,
 		RequeueAfterSuccess: 1000,
 		RequeueAfterFailure: 1000,
+		LabelSelector:       ignoredLabel + "!=true",
 	}, nil)
 	Expect(err).ToNot(HaveOccurred())
 
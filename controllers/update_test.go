@@ -95,5 +95,33 @@ var _ = Describe("Test Update and Recreate", func() {
 			updated, _ := getObjectA(key)
 			Expect(updated.Spec.StringData).To(Equal("Updated"))
 		})
+
+		It("should recreate when an immutable field changes", func() {
+			bId := "b-" + RandomString(10)
+			ownerId := "a-" + RandomString(10)
+			keyA, createdA := nameAndSpecA(ownerId)
+			keyB, createdB := nameAndSpecB(bId, ownerId, []string{})
+
+			Expect(k8sClient.Create(context.Background(), createdA)).To(Succeed())
+			Expect(k8sClient.Create(context.Background(), createdB)).To(Succeed())
+			waitUntilReconcileStateB(keyB, reconciler.Succeeded)
+
+			// spec.intData is declared immutable for B, so the external resource is recreated
+			// even though the ResourceManager reports that it is ready
+			toUpdate, _ := getObjectB(keyB)
+			toUpdate.Spec.IntData = 1
+			Expect(k8sClient.Update(context.Background(), toUpdate)).To(Succeed())
+
+			Eventually(func() int {
+				return resourceManager.CountEvents(bId, manager.EventCreate)
+			}, timeout, interval).Should(Equal(2))
+			waitUntilReconcileStateB(keyB, reconciler.Succeeded)
+
+			Expect(resourceManager.CountEvents(bId, manager.EventDelete)).To(Equal(1))
+			Expect(resourceManager.CountEvents(bId, manager.EventUpdate)).To(Equal(0))
+
+			Expect(deleteObjectB(keyB)).To(Succeed())
+			Expect(deleteObjectA(keyA)).To(Succeed())
+		})
 	})
 })
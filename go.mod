@@ -4,10 +4,13 @@ go 1.13
 
 require (
 	github.com/go-logr/logr v0.1.0
+	github.com/golang/protobuf v1.4.2
 	github.com/onsi/ginkgo v1.14.1
 	github.com/onsi/gomega v1.10.2
+	google.golang.org/grpc v1.26.0
 	k8s.io/api v0.18.6
 	k8s.io/apimachinery v0.18.6
 	k8s.io/client-go v0.18.6
 	sigs.k8s.io/controller-runtime v0.6.2
+	sigs.k8s.io/yaml v1.2.0
 )
@@ -17,19 +17,28 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"github.com/operatify/operatify/controllers/b"
 	"os"
+	"strings"
 
 	"github.com/operatify/operatify/controllers/a"
 	"github.com/operatify/operatify/controllers/manager"
+	"github.com/operatify/operatify/controllers/shared"
 	"github.com/operatify/operatify/reconciler"
 
+	"github.com/go-logr/logr"
 	api "github.com/operatify/operatify/api/v1alpha1"
 	testv1alpha1 "github.com/operatify/operatify/api/v1alpha1"
+	testv1beta1 "github.com/operatify/operatify/api/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	// +kubebuilder:scaffold:imports
 )
@@ -44,54 +53,174 @@ func init() {
 
 	_ = api.AddToScheme(scheme)
 	_ = testv1alpha1.AddToScheme(scheme)
+	_ = testv1beta1.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var unstructuredConfig string
+	var enableWebhooks bool
+	var shards int
+	var shardIdentity string
+	var shardNamespace string
+	var namespaces string
+	var labelSelector string
+	var controllerClass string
+	var healthProbeAddr string
+	var faultScenarios string
+	var backendReadiness bool
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&unstructuredConfig, "unstructured-config", "",
+		"A file declaring additional kinds to reconcile as unstructured resources.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Serve the admission webhooks of the kinds. The webhook configuration and certificates must be deployed.")
+	flag.IntVar(&shards, "shards", 0,
+		"Share the resources between the replicas in this number of shards, claimed with Leases. Sharding is disabled if this is 0, and can't be used with leader election.")
+	flag.StringVar(&shardIdentity, "shard-identity", "",
+		"Identifies this replica when claiming shards. Defaults to the host name.")
+	flag.StringVar(&shardNamespace, "shard-namespace", "operatify-system",
+		"The namespace of the shard Leases.")
+	flag.StringVar(&namespaces, "namespaces", "",
+		"A comma separated list of the namespaces to reconcile resources in. Resources in all namespaces are reconciled if this is empty.")
+	flag.StringVar(&labelSelector, "label-selector", "",
+		"Only reconcile resources matching this label selector, such as team=payments.")
+	flag.StringVar(&controllerClass, "controller-class", "",
+		"Only reconcile resources assigned to this class with the controller-class annotation, such as staging. Resources without the annotation belong to the default class, \"\".")
+	flag.StringVar(&healthProbeAddr, "health-probe-addr", ":8081",
+		"The address the liveness (/healthz) and readiness (/readyz) probes bind to.")
+	flag.BoolVar(&backendReadiness, "backend-readiness", true,
+		"Include the health of the backends of the kinds in the readiness probe. Disable this to keep serving the webhooks of every kind while a backend is unavailable.")
+	flag.StringVar(&faultScenarios, "fault-scenarios", "",
+		"A file of scenarios injecting faults, such as latency and failures, into the fake backend of the kinds.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.Logger(true))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	options := ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
 		LeaderElection:     enableLeaderElection,
 		Port:               9443,
-	})
+	}
+	options.HealthProbeBindAddress = healthProbeAddr
+	// only cache the namespaces that are reconciled, so the operator only needs access to those
+	namespaceList := splitList(namespaces)
+	if len(namespaceList) == 1 {
+		options.Namespace = namespaceList[0]
+	} else if len(namespaceList) > 1 {
+		options.NewCache = cache.MultiNamespacedCacheBuilder(namespaceList)
+	}
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	var shardManager *reconciler.ShardManager
+	if shards > 0 {
+		if shardManager, err = createShardManager(mgr, enableLeaderElection, shards, shardIdentity, shardNamespace); err != nil {
+			setupLog.Error(err, "unable to create shard manager")
+			os.Exit(1)
+		}
+	}
+
+	// the backends of the kinds don't check liveness, as restarting the operator won't make them available
+	healthChecks := reconciler.CreateHealthChecks(10000, 5000)
+	if err = mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to add liveness check")
+		os.Exit(1)
+	}
+	if err = mgr.AddReadyzCheck("ping", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to add readiness check")
+		os.Exit(1)
+	}
+	if backendReadiness {
+		if err = mgr.AddReadyzCheck("backends", healthChecks.Check); err != nil {
+			setupLog.Error(err, "unable to add backend readiness check")
+			os.Exit(1)
+		}
+	}
+	// the status of each backend is served alongside the metrics
+	if err = mgr.AddMetricsExtraHandler("/backends", healthChecks); err != nil {
+		setupLog.Error(err, "unable to add backend status handler")
+		os.Exit(1)
+	}
+
 	// create controllers
 	controllerParams := reconciler.ReconcileParameters{
 		RequeueAfter:        5000,
 		RequeueAfterSuccess: 15000,
 		RequeueAfterFailure: 30000,
+		Namespaces:          namespaceList,
+		LabelSelector:       labelSelector,
+		ControllerClass:     controllerClass,
 	}
 	store := manager.CreateManager()
-	if err = (&a.ControllerFactory{
-		ResourceManagerCreator: a.CreateResourceManager,
-		Scheme:                 scheme,
-		Manager:                store,
+	if faultScenarios != "" {
+		scenarios, err := manager.LoadScenarios(faultScenarios)
+		if err != nil {
+			setupLog.Error(err, "unable to load fault scenarios")
+			os.Exit(1)
+		}
+		for _, scenario := range scenarios {
+			if err := store.AddScenario(scenario); err != nil {
+				setupLog.Error(err, "unable to add fault scenario", "scenario", scenario.Name)
+				os.Exit(1)
+			}
+			setupLog.Info("added fault scenario", "scenario", scenario.Name)
+		}
+	}
+	if _, err = (&a.ControllerFactory{
+		ControllerFactory: reconciler.ControllerFactory{
+			EnableWebhooks: enableWebhooks,
+			ShardManager:   shardManager,
+			HealthChecks:   healthChecks,
+		},
+		Manager: store,
 	}).SetupWithManager(mgr, controllerParams, nil); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ATest")
 		os.Exit(1)
 	}
 
-	if err = (&b.ControllerFactory{
-		ResourceManagerCreator: b.CreateResourceManager,
-		Scheme:                 scheme,
-		Manager:                store,
+	if _, err = (&b.ControllerFactory{
+		ControllerFactory: reconciler.ControllerFactory{
+			EnableWebhooks: enableWebhooks,
+			ShardManager:   shardManager,
+			HealthChecks:   healthChecks,
+		},
+		Manager: store,
 	}).SetupWithManager(mgr, controllerParams, nil); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "BTest")
 		os.Exit(1)
 	}
+	if unstructuredConfig != "" {
+		config, err := reconciler.LoadUnstructuredConfig(unstructuredConfig)
+		if err != nil {
+			setupLog.Error(err, "unable to load unstructured config")
+			os.Exit(1)
+		}
+		for _, kind := range config.Kinds {
+			factory, err := reconciler.CreateUnstructuredControllerFactory(kind,
+				func(logger logr.Logger, recorder record.EventRecorder) reconciler.ResourceManager {
+					resourceManager := shared.CreateResourceManager(logger, recorder, store, shared.UnstructuredSpecGetter)
+					return &resourceManager
+				}, shared.AnnotationBaseName)
+			if err == nil {
+				factory.ShardManager = shardManager
+				factory.ProviderConfigKinds = shared.ProviderConfigKinds()
+				factory.HealthChecks = healthChecks
+				_, err = factory.SetupWithManager(mgr, controllerParams, nil)
+			}
+			if err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", kind.Kind)
+				os.Exit(1)
+			}
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -100,3 +229,42 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+func createShardManager(mgr ctrl.Manager, enableLeaderElection bool, shards int, identity string, namespace string) (*reconciler.ShardManager, error) {
+	if enableLeaderElection {
+		return nil, fmt.Errorf("sharding can't be used with leader election, as only the leader would reconcile resources")
+	}
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		identity = hostname
+	}
+	// the Leases are read from the API server rather than a cache, as every replica updates them
+	kubeClient, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme()})
+	if err != nil {
+		return nil, err
+	}
+	shardManager, err := reconciler.CreateShardManager(reconciler.ShardingConfig{
+		Namespace: namespace,
+		Name:      "operatify",
+		Shards:    shards,
+		Identity:  identity,
+	}, kubeClient, ctrl.Log.WithName("shards"))
+	if err != nil {
+		return nil, err
+	}
+	return shardManager, mgr.Add(shardManager)
+}
+
+// splits the comma separated list, ignoring empty items
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
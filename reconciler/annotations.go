@@ -0,0 +1,79 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"strings"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// returns the value of the annotation, such as LastAppliedAnnotation, under the AnnotationBaseName of the controller,
+// falling back to each of its LegacyAnnotationBaseNames in turn
+func (r *reconcileRunner) getAnnotation(name string) string {
+	value, _ := r.lookupAnnotation(r.objectMeta, name)
+	return value
+}
+
+// returns the value of the annotation of the resource as getAnnotation does, and whether it is set
+func (gc *GenericController) lookupAnnotation(meta metav1.Object, name string) (string, bool) {
+	annotations := meta.GetAnnotations()
+	if value, ok := annotations[gc.AnnotationBaseName+name]; ok {
+		return value, true
+	}
+	for _, legacyBaseName := range gc.LegacyAnnotationBaseNames {
+		if value, ok := annotations[legacyBaseName+name]; ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// rewrites the annotations of the instance under the LegacyAnnotationBaseNames to the AnnotationBaseName.
+// this is done whenever the instance is updated, so existing resources migrate without an update of their own
+func (r *reconcileRunner) migrateLegacyAnnotations(instance runtime.Object) {
+	if len(r.LegacyAnnotationBaseNames) == 0 {
+		return
+	}
+	if meta, err := apimeta.Accessor(instance); err == nil {
+		migrateAnnotations(meta, r.AnnotationBaseName, r.LegacyAnnotationBaseNames)
+	}
+}
+
+// moves the annotations under the legacy base names to the base name, unless they are already set under the base name.
+// earlier legacy base names take precedence over later ones, as they do when the annotations are read
+func migrateAnnotations(meta metav1.Object, baseName string, legacyBaseNames []string) {
+	annotations := meta.GetAnnotations()
+	migrated := false
+	for _, legacyBaseName := range legacyBaseNames {
+		for key, value := range annotations {
+			if !strings.HasPrefix(key, legacyBaseName+"/") {
+				continue
+			}
+			name := baseName + strings.TrimPrefix(key, legacyBaseName)
+			if _, ok := annotations[name]; !ok {
+				annotations[name] = value
+			}
+			delete(annotations, key)
+			migrated = true
+		}
+	}
+	if migrated {
+		meta.SetAnnotations(annotations)
+	}
+}
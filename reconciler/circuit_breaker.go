@@ -0,0 +1,148 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"sync"
+	"time"
+)
+
+// The reason used for events and status messages while calls to the ResourceManager are paused
+const BackendUnavailable = "BackendUnavailable"
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "Closed"
+	CircuitOpen     CircuitState = "Open"
+	CircuitHalfOpen CircuitState = "HalfOpen"
+)
+
+// CircuitBreaker guards the calls a GenericController makes to its ResourceManager.
+// It opens after Threshold consecutive errors, after which no calls are made until ResetAfter has elapsed.
+// A single probe call is then allowed through (half-open): if it succeeds the circuit closes again,
+// otherwise it stays open for another ResetAfter period.
+type CircuitBreaker struct {
+	Threshold  int
+	ResetAfter time.Duration
+	now        func() time.Time
+
+	mutex         sync.Mutex
+	state         CircuitState
+	failures      int
+	openedAt      time.Time
+	probeInFlight bool
+}
+
+func CreateCircuitBreaker(threshold int, resetAfter time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		Threshold:  threshold,
+		ResetAfter: resetAfter,
+		now:        time.Now,
+		state:      CircuitClosed,
+	}
+}
+
+// returns a CircuitBreaker configured by the parameters, or nil if the circuit breaker is disabled
+func (parameters ReconcileParameters) circuitBreaker() *CircuitBreaker {
+	if parameters.CircuitBreakerThreshold <= 0 {
+		return nil
+	}
+	resetAfter := parameters.CircuitBreakerResetAfter
+	if resetAfter == 0 {
+		resetAfter = 30000
+	}
+	return CreateCircuitBreaker(parameters.CircuitBreakerThreshold, time.Duration(resetAfter)*time.Millisecond)
+}
+
+// State returns the current state of the circuit
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+	return cb.state
+}
+
+// allow returns whether calls to the ResourceManager may be made, and if not, how long until they may be retried.
+// It doesn't take the probe call of a half-open circuit, which is only taken by acquire when the call is made
+func (cb *CircuitBreaker) allow() (bool, time.Duration) {
+	if cb == nil {
+		return true, 0
+	}
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		remaining := cb.openedAt.Add(cb.ResetAfter).Sub(cb.now())
+		if remaining > 0 {
+			return false, remaining
+		}
+	case CircuitHalfOpen:
+		if cb.probeInFlight {
+			return false, cb.ResetAfter
+		}
+	}
+	return true, 0
+}
+
+// acquire is called immediately before a call to the ResourceManager, returning false if the call may not be made.
+// Once the circuit may be probed, the first call acquired is the probe, and no other calls are allowed until it is recorded
+func (cb *CircuitBreaker) acquire() bool {
+	if cb == nil {
+		return true
+	}
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		if cb.now().Before(cb.openedAt.Add(cb.ResetAfter)) {
+			return false
+		}
+		cb.state = CircuitHalfOpen
+		cb.probeInFlight = true
+	case CircuitHalfOpen:
+		if cb.probeInFlight {
+			return false
+		}
+		cb.probeInFlight = true
+	}
+	return true
+}
+
+// record registers the outcome of a call to the ResourceManager made after acquire, returning true if this caused the circuit to open
+func (cb *CircuitBreaker) record(err error) bool {
+	if cb == nil {
+		return false
+	}
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	cb.probeInFlight = false
+	if err == nil {
+		cb.failures = 0
+		cb.state = CircuitClosed
+		return false
+	}
+
+	cb.failures++
+	if cb.state == CircuitHalfOpen || (cb.state == CircuitClosed && cb.failures >= cb.Threshold) {
+		cb.state = CircuitOpen
+		cb.openedAt = cb.now()
+		return true
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package reconciler
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Circuit breaker", func() {
+
+	var now time.Time
+	var cb *CircuitBreaker
+	failure := errors.New("backend unavailable")
+
+	BeforeEach(func() {
+		now = time.Unix(0, 0)
+		cb = CreateCircuitBreaker(2, time.Minute)
+		cb.now = func() time.Time { return now }
+	})
+
+	// makes a call through the breaker, as the reconciler does
+	call := func(err error) bool {
+		if !cb.acquire() {
+			return false
+		}
+		cb.record(err)
+		return true
+	}
+
+	open := func() {
+		Expect(call(failure)).To(BeTrue())
+		Expect(call(failure)).To(BeTrue())
+		Expect(cb.State()).To(Equal(CircuitOpen))
+	}
+
+	It("opens after consecutive errors", func() {
+		Expect(call(failure)).To(BeTrue())
+		Expect(call(nil)).To(BeTrue())
+		Expect(call(failure)).To(BeTrue())
+		Expect(cb.State()).To(Equal(CircuitClosed))
+		Expect(call(failure)).To(BeTrue())
+		Expect(cb.State()).To(Equal(CircuitOpen))
+
+		allowed, retryAfter := cb.allow()
+		Expect(allowed).To(BeFalse())
+		Expect(retryAfter).To(Equal(time.Minute))
+		Expect(cb.acquire()).To(BeFalse())
+	})
+
+	It("allows a single probe once reset, closing if it succeeds", func() {
+		open()
+		now = now.Add(time.Minute)
+
+		// checking doesn't take the probe
+		Expect(cb.allow()).To(BeTrue())
+		Expect(cb.allow()).To(BeTrue())
+
+		Expect(cb.acquire()).To(BeTrue())
+		Expect(cb.State()).To(Equal(CircuitHalfOpen))
+		Expect(cb.acquire()).To(BeFalse())
+		allowed, _ := cb.allow()
+		Expect(allowed).To(BeFalse())
+
+		cb.record(nil)
+		Expect(cb.State()).To(Equal(CircuitClosed))
+		Expect(cb.allow()).To(BeTrue())
+		Expect(call(nil)).To(BeTrue())
+	})
+
+	It("opens again if the probe fails", func() {
+		open()
+		now = now.Add(time.Minute)
+		Expect(call(failure)).To(BeTrue())
+		Expect(cb.State()).To(Equal(CircuitOpen))
+
+		now = now.Add(time.Second)
+		allowed, retryAfter := cb.allow()
+		Expect(allowed).To(BeFalse())
+		Expect(retryAfter).To(Equal(59 * time.Second))
+	})
+
+	It("keeps the probe available until a call is made", func() {
+		open()
+		now = now.Add(time.Minute)
+
+		// reconciles that check the breaker but return before calling the ResourceManager don't hold the probe
+		for i := 0; i < 3; i++ {
+			allowed, _ := cb.allow()
+			Expect(allowed).To(BeTrue())
+		}
+		Expect(call(nil)).To(BeTrue())
+		Expect(cb.State()).To(Equal(CircuitClosed))
+	})
+
+	It("allows every call if disabled", func() {
+		var disabled *CircuitBreaker
+		Expect(disabled.allow()).To(BeTrue())
+		Expect(disabled.acquire()).To(BeTrue())
+		Expect(disabled.record(failure)).To(BeFalse())
+	})
+
+	It("releases the probe taken for a call to the ResourceManager, and clears the BackendUnavailable condition", func() {
+		open()
+		now = now.Add(time.Minute)
+		status := &Status{State: Verifying, Conditions: []Condition{{Type: BackendUnavailable, Status: corev1.ConditionTrue}}}
+		r := &reconcileRunner{
+			GenericController: &GenericController{CircuitBreaker: cb},
+			CircuitBreaker:    cb,
+			status:            status,
+			log:               ctrl.Log,
+			instanceUpdater:   &instanceUpdater{},
+		}
+
+		// a concurrent call is refused while the probe is in flight
+		err := r.callBackend(func() error {
+			Expect(r.callBackend(func() error { return nil })).To(Equal(errBackendUnavailable))
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cb.State()).To(Equal(CircuitClosed))
+
+		for _, update := range r.instanceUpdater.statusUpdates {
+			update(status)
+		}
+		Expect(status.GetCondition(BackendUnavailable).Status).To(Equal(corev1.ConditionFalse))
+	})
+})
@@ -0,0 +1,91 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// The class of the controller that should reconcile the resource. Resources without it belong to the default class, ""
+	ControllerClassAnnotation = "/controller-class"
+	// The class of the controller reconciling the resource. This is set by the controller when it claims the resource,
+	// and removed by it when it hands the resource over to the controller of another class
+	ManagingControllerClassAnnotation = "/managing-controller-class"
+)
+
+// returns whether the resource belongs to the class of the controller: either the controller manages it,
+// or it is assigned to the class of the controller and no controller has claimed it
+func (gc *GenericController) inClass(meta metav1.Object) bool {
+	class, _ := gc.lookupAnnotation(meta, ControllerClassAnnotation)
+	managingClass, claimed := gc.lookupAnnotation(meta, ManagingControllerClassAnnotation)
+	if claimed {
+		return managingClass == gc.Parameters.ControllerClass
+	}
+	return class == gc.Parameters.ControllerClass
+}
+
+// claims the resource for the class of the controller if it isn't claimed yet, or hands it over to the controller
+// of the class it has been assigned to, once no operation on the external resource is in progress.
+// returns whether the reconcile is done with, because the resource was claimed or handed over
+func (r *reconcileRunner) handOver(ctx context.Context) (bool, ctrl.Result, error) {
+	class := r.getAnnotation(ControllerClassAnnotation)
+	_, claimed := r.lookupAnnotation(r.objectMeta, ManagingControllerClassAnnotation)
+	ownClass := r.Parameters.ControllerClass
+
+	if !claimed {
+		r.instanceUpdater.setAnnotation(r.AnnotationBaseName+ManagingControllerClassAnnotation, ownClass)
+		message := fmt.Sprintf("Claimed by controller class %s", className(ownClass))
+		r.log.Info(message)
+		if err := r.updateAndLog(ctx, corev1.EventTypeNormal, "ControllerClass", message); err != nil {
+			return true, ctrl.Result{}, err
+		}
+		return true, ctrl.Result{Requeue: true}, nil
+	}
+	if class == ownClass {
+		return false, ctrl.Result{}, nil
+	}
+	// a resource being deleted is finalized by the controller managing it
+	if !r.objectMeta.GetDeletionTimestamp().IsZero() {
+		return false, ctrl.Result{}, nil
+	}
+	// the operation in progress is seen through by this controller, which hands the resource over on a later reconcile
+	if r.status.Operation != nil {
+		r.log.Info(fmt.Sprintf("Handover to controller class %s waits for the operation in progress", className(class)))
+		return false, ctrl.Result{}, nil
+	}
+
+	names := []string{r.AnnotationBaseName + ManagingControllerClassAnnotation}
+	for _, legacyBaseName := range r.LegacyAnnotationBaseNames {
+		names = append(names, legacyBaseName+ManagingControllerClassAnnotation)
+	}
+	r.instanceUpdater.removeAnnotation(names...)
+	message := fmt.Sprintf("Handed over by controller class %s to controller class %s", className(ownClass), className(class))
+	r.log.Info(message)
+	return true, ctrl.Result{}, r.updateAndLog(ctx, corev1.EventTypeNormal, "ControllerClass", message)
+}
+
+func className(class string) string {
+	if class == "" {
+		return "(default)"
+	}
+	return class
+}
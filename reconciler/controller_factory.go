@@ -0,0 +1,351 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlbuilder "sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// ControllerFactory creates the GenericController for a kind and registers it with the controller manager
+type ControllerFactory struct {
+	// An empty instance of the kind
+	Prototype runtime.Object
+	// Defaults to the name of the type of the Prototype
+	ResourceKind           string
+	ResourceManagerCreator func(logr.Logger, record.EventRecorder) ResourceManager
+	DefinitionManager      DefinitionManager
+	FinalizerName          string
+	AnnotationBaseName     string
+	CompletionRunner       func(*GenericController) CompletionRunner
+	// Empty instances of the kinds of the owners and dependencies of the kind.
+	// When a resource of one of these kinds changes, the resources that depend on it are reconciled
+	DependencyKinds []runtime.Object
+	// Empty instances of the kinds of objects owned by the kind, for example secrets created by the CompletionRunner.
+	// When one of these changes, its owner is reconciled
+	OwnedKinds []runtime.Object
+	// Whether to serve the admission webhooks of the kind with the webhook server of the manager.
+	// A validating webhook is served if the DefinitionManager or the ResourceManager implements Validator,
+	// and a defaulting webhook if either implements Defaulter. Otherwise defaults are applied by the reconciler.
+	// If the kind has several versions in the scheme, one of which is a conversion.Hub, the conversion webhook is served too
+	EnableWebhooks bool
+	// The paths of the spec fields, such as spec.location, that can't be changed on the external resource once it is applied.
+	// Changing one of these recreates the external resource, or is rejected, depending on ReconcileParameters.ImmutableFieldChange
+	ImmutableFields []string
+	// Base names the annotations of the kind were previously set under, such as a former domain.
+	// They are read if an annotation isn't set under the AnnotationBaseName, and rewritten to it on the next update of the resource
+	LegacyAnnotationBaseNames []string
+	// If set, only the resources in the shards held by this replica are reconciled,
+	// and the resources of the shards it claims are reconciled when it claims them
+	ShardManager *ShardManager
+	// Empty instances of the kinds resources can reference as their provider config with spec.providerConfigRef,
+	// such as a namespaced ProviderConfig and a cluster-scoped ClusterProviderConfig.
+	// When a provider config changes, the resources referencing it are reconciled
+	ProviderConfigKinds []runtime.Object
+	// If set, creates the ResourceManager used for the resources referencing a provider config.
+	// It is created again whenever the provider config or its credentials Secret changes
+	ProviderResourceManagerCreator func(logr.Logger, record.EventRecorder, *ProviderConfig) (ResourceManager, error)
+	// If set, the backend of the kind is added to the HealthChecks, which serve its status and can gate the readiness of the manager
+	HealthChecks *HealthChecks
+}
+
+// SetupWithManager creates the GenericController and registers it with the manager, returning it for testing
+func (factory *ControllerFactory) SetupWithManager(mgr ctrl.Manager, parameters ReconcileParameters, log *logr.Logger) (*GenericController, error) {
+	if factory.Prototype == nil {
+		return nil, fmt.Errorf("no Prototype defined for ControllerFactory")
+	}
+	if factory.ResourceManagerCreator == nil {
+		return nil, fmt.Errorf("no ResourceManagerCreator defined for ControllerFactory")
+	}
+	resourceKind := factory.getResourceKind()
+	if log == nil {
+		l := ctrl.Log.WithName("controllers")
+		log = &l
+	}
+	logger := (*log).WithName(resourceKind)
+	recorder := mgr.GetEventRecorderFor(resourceKind + "-controller")
+
+	gc, err := CreateGenericController(parameters, resourceKind, mgr.GetClient(), logger, recorder, mgr.GetScheme(),
+		factory.ResourceManagerCreator(logger, recorder), factory.DefinitionManager, factory.FinalizerName,
+		factory.AnnotationBaseName, factory.CompletionRunner)
+	if err != nil {
+		return nil, err
+	}
+	gc.ImmutableFields = factory.ImmutableFields
+	gc.LegacyAnnotationBaseNames = factory.LegacyAnnotationBaseNames
+	gc.ShardManager = factory.ShardManager
+	gc.ProviderConfigKinds = factory.ProviderConfigKinds
+	gc.RESTMapper = mgr.GetRESTMapper()
+	gc.APIReader = mgr.GetAPIReader()
+	if factory.ProviderResourceManagerCreator != nil {
+		gc.ProviderResourceManagerCreator = func(providerConfig *ProviderConfig) (ResourceManager, error) {
+			return factory.ProviderResourceManagerCreator(logger.WithValues("ProviderConfig", providerConfig.Kind+" "+providerConfig.NamespacedName.String()), recorder, providerConfig)
+		}
+	}
+
+	builder := ctrl.NewControllerManagedBy(mgr).For(factory.Prototype, ctrlbuilder.WithPredicates(gc.scopePredicate()))
+	for _, owned := range factory.OwnedKinds {
+		builder = builder.Owns(owned)
+	}
+	for _, dependency := range factory.DependencyKinds {
+		builder = builder.Watches(&source.Kind{Type: dependency}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: factory.dependentsOf(gc, dependency),
+		})
+	}
+	for _, providerConfigKind := range factory.ProviderConfigKinds {
+		builder = builder.Watches(&source.Kind{Type: providerConfigKind}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: factory.referrersOf(gc, providerConfigKind),
+		})
+	}
+	if factory.ShardManager != nil {
+		claimed := make(chan event.GenericEvent)
+		builder = builder.Watches(&source.Channel{Source: claimed}, &handler.EnqueueRequestForObject{})
+		factory.ShardManager.AddListener(func(acquired map[int]bool) {
+			go factory.enqueueShards(gc, acquired, claimed)
+		})
+	}
+	if err := builder.Complete(gc); err != nil {
+		return nil, err
+	}
+	if factory.HealthChecks != nil {
+		factory.HealthChecks.Add(gc)
+	}
+	if factory.EnableWebhooks {
+		if err := factory.setupWebhooks(mgr, gc); err != nil {
+			return nil, err
+		}
+	} else {
+		gc.Defaulter = findDefaulter(gc)
+	}
+	return gc, nil
+}
+
+// registers the admission webhooks supported by the kind at the paths Kubebuilder would generate for them
+func (factory *ControllerFactory) setupWebhooks(mgr ctrl.Manager, gc *GenericController) error {
+	gvk, err := apiutil.GVKForObject(factory.Prototype, mgr.GetScheme())
+	if err != nil {
+		return err
+	}
+	validator, ok := gc.DefinitionManager.(Validator)
+	if !ok {
+		validator, ok = findImplementation(gc.ResourceManager, func(rm ResourceManager) bool {
+			_, ok := rm.(Validator)
+			return ok
+		}).(Validator)
+	}
+	if len(gc.ImmutableFields) > 0 && gc.Parameters.ImmutableFieldChange == ImmutableFieldChangeReject {
+		validator, ok = &immutableFieldsValidator{paths: gc.ImmutableFields, validator: validator}, true
+	}
+	if ok {
+		validatingWebhook, err := CreateValidatingWebhook(validator, gc.DefinitionManager, mgr.GetScheme())
+		if err != nil {
+			return err
+		}
+		mgr.GetWebhookServer().Register(validatingWebhookPath(gvk), &webhook.Admission{Handler: validatingWebhook})
+	}
+	if defaulter := findDefaulter(gc); defaulter != nil {
+		defaultingWebhook, err := CreateDefaultingWebhook(defaulter, gc.DefinitionManager, mgr.GetScheme())
+		if err != nil {
+			return err
+		}
+		mgr.GetWebhookServer().Register(defaultingWebhookPath(gvk), &webhook.Admission{Handler: defaultingWebhook})
+	}
+	// serves /convert if the kind is convertible
+	return ctrl.NewWebhookManagedBy(mgr).For(factory.Prototype).Complete()
+}
+
+func findDefaulter(gc *GenericController) Defaulter {
+	if defaulter, ok := gc.DefinitionManager.(Defaulter); ok {
+		return defaulter
+	}
+	if defaulter, ok := findImplementation(gc.ResourceManager, func(rm ResourceManager) bool {
+		_, ok := rm.(Defaulter)
+		return ok
+	}).(Defaulter); ok {
+		return defaulter
+	}
+	return nil
+}
+
+func (factory *ControllerFactory) getResourceKind() string {
+	if factory.ResourceKind != "" {
+		return factory.ResourceKind
+	}
+	if u, ok := factory.Prototype.(*unstructured.Unstructured); ok {
+		return u.GetKind()
+	}
+	return reflect.TypeOf(factory.Prototype).Elem().Name()
+}
+
+// returns a mapping from a changed dependency to the requests for the resources of the kind that depend on it
+func (factory *ControllerFactory) dependentsOf(gc *GenericController, dependencyKind runtime.Object) handler.ToRequestsFunc {
+	return func(o handler.MapObject) []reconcile.Request {
+		ctx := context.Background()
+		changed := types.NamespacedName{Namespace: o.Meta.GetNamespace(), Name: o.Meta.GetName()}
+		log := gc.Log.WithValues("Dependency", changed)
+
+		list, err := factory.newList(gc.Scheme)
+		if err != nil {
+			log.Info(fmt.Sprintf("Unable to create list of %s: %v", gc.ResourceKind, err))
+			return nil
+		}
+		if err := gc.KubeClient.List(ctx, list, client.InNamespace(changed.Namespace)); err != nil {
+			log.Info(fmt.Sprintf("Unable to list %s to find dependents: %v", gc.ResourceKind, err))
+			return nil
+		}
+		items, err := apimeta.ExtractList(list)
+		if err != nil {
+			log.Info(fmt.Sprintf("Unable to extract list of %s: %v", gc.ResourceKind, err))
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, item := range items {
+			defs, err := gc.DefinitionManager.GetDependencies(ctx, item)
+			if err != nil || defs == nil {
+				continue
+			}
+			deps := defs.Dependencies
+			if defs.Owner != nil {
+				deps = append([]*Dependency{defs.Owner}, deps...)
+			}
+			for _, dep := range deps {
+				if dep.NamespacedName == changed && sameKind(dep.InitialInstance, dependencyKind) {
+					m, _ := apimeta.Accessor(item)
+					requests = append(requests, reconcile.Request{
+						NamespacedName: types.NamespacedName{Namespace: m.GetNamespace(), Name: m.GetName()},
+					})
+					break
+				}
+			}
+		}
+		return requests
+	}
+}
+
+// returns a mapping from a changed provider config to the requests for the resources of the kind that reference it
+func (factory *ControllerFactory) referrersOf(gc *GenericController, providerConfigKind runtime.Object) handler.ToRequestsFunc {
+	return func(o handler.MapObject) []reconcile.Request {
+		gvk, err := apiutil.GVKForObject(providerConfigKind, gc.Scheme)
+		if err != nil {
+			gc.Log.Info(fmt.Sprintf("Unable to get kind of provider config: %v", err))
+			return nil
+		}
+		list, err := factory.newList(gc.Scheme)
+		if err != nil {
+			gc.Log.Info(fmt.Sprintf("Unable to create list of %s: %v", gc.ResourceKind, err))
+			return nil
+		}
+		// a cluster-scoped provider config can be referenced from every namespace
+		if err := gc.KubeClient.List(context.Background(), list, client.InNamespace(o.Meta.GetNamespace())); err != nil {
+			gc.Log.Info(fmt.Sprintf("Unable to list %s referencing provider config: %v", gc.ResourceKind, err))
+			return nil
+		}
+		items, err := apimeta.ExtractList(list)
+		if err != nil {
+			gc.Log.Info(fmt.Sprintf("Unable to extract list of %s: %v", gc.ResourceKind, err))
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, item := range items {
+			object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(item)
+			if err != nil {
+				continue
+			}
+			kind, _, _ := unstructured.NestedString(object, "spec", "providerConfigRef", "kind")
+			name, _, _ := unstructured.NestedString(object, "spec", "providerConfigRef", "name")
+			if kind == gvk.Kind && name == o.Meta.GetName() {
+				m, _ := apimeta.Accessor(item)
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Namespace: m.GetNamespace(), Name: m.GetName()},
+				})
+			}
+		}
+		return requests
+	}
+}
+
+// sends an event for each resource of the kind in the shards, so that the resources of shards claimed from other replicas are reconciled
+func (factory *ControllerFactory) enqueueShards(gc *GenericController, shards map[int]bool, events chan<- event.GenericEvent) {
+	list, err := factory.newList(gc.Scheme)
+	if err != nil {
+		gc.Log.Info(fmt.Sprintf("Unable to create list of %s: %v", gc.ResourceKind, err))
+		return
+	}
+	if err := gc.KubeClient.List(context.Background(), list); err != nil {
+		gc.Log.Info(fmt.Sprintf("Unable to list %s in claimed shards: %v", gc.ResourceKind, err))
+		return
+	}
+	items, err := apimeta.ExtractList(list)
+	if err != nil {
+		gc.Log.Info(fmt.Sprintf("Unable to extract list of %s: %v", gc.ResourceKind, err))
+		return
+	}
+	for _, item := range items {
+		m, err := apimeta.Accessor(item)
+		if err != nil {
+			continue
+		}
+		if shards[ShardOf(types.NamespacedName{Namespace: m.GetNamespace(), Name: m.GetName()}, gc.ShardManager.Config.Shards)] {
+			events <- event.GenericEvent{Meta: m, Object: item}
+		}
+	}
+}
+
+// creates an empty list of the kind, which is registered in the scheme as <Kind>List
+func (factory *ControllerFactory) newList(scheme *runtime.Scheme) (runtime.Object, error) {
+	if u, ok := factory.Prototype.(*unstructured.Unstructured); ok {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(u.GroupVersionKind().GroupVersion().WithKind(u.GetKind() + "List"))
+		return list, nil
+	}
+	gvks, _, err := scheme.ObjectKinds(factory.Prototype)
+	if err != nil {
+		return nil, err
+	}
+	gvk := gvks[0]
+	gvk.Kind = gvk.Kind + "List"
+	return scheme.New(gvk)
+}
+
+// returns whether the objects are of the same kind. unstructured objects are compared by GroupVersionKind
+func sameKind(a runtime.Object, b runtime.Object) bool {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+	if _, ok := a.(*unstructured.Unstructured); ok {
+		return a.GetObjectKind().GroupVersionKind() == b.GetObjectKind().GroupVersionKind()
+	}
+	return true
+}
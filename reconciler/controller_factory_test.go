@@ -0,0 +1,127 @@
+package reconciler
+
+import (
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/operatify/operatify/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// a manager that records the sources and handlers injected into the controllers registered with it, without running them
+type recordingManager struct {
+	manager.Manager
+	scheme   *runtime.Scheme
+	client   client.Client
+	injected []interface{}
+}
+
+func (m *recordingManager) GetScheme() *runtime.Scheme { return m.scheme }
+
+func (m *recordingManager) GetClient() client.Client { return m.client }
+
+func (m *recordingManager) GetAPIReader() client.Reader { return m.client }
+
+func (m *recordingManager) GetConfig() *rest.Config { return &rest.Config{} }
+
+func (m *recordingManager) GetRESTMapper() apimeta.RESTMapper {
+	return apimeta.NewDefaultRESTMapper(nil)
+}
+
+func (m *recordingManager) GetLogger() logr.Logger { return ctrl.Log }
+
+func (m *recordingManager) GetEventRecorderFor(string) record.EventRecorder {
+	return record.NewFakeRecorder(10)
+}
+
+func (m *recordingManager) SetFields(i interface{}) error {
+	m.injected = append(m.injected, i)
+	return nil
+}
+
+func (m *recordingManager) Add(manager.Runnable) error { return nil }
+
+// returns the handler watching the kind, which is injected after its source
+func (m *recordingManager) handlerOf(kind runtime.Object) handler.EventHandler {
+	for i, injected := range m.injected {
+		if src, ok := injected.(*source.Kind); ok && sameKind(src.Type, kind) && i+1 < len(m.injected) {
+			h, _ := m.injected[i+1].(handler.EventHandler)
+			return h
+		}
+	}
+	return nil
+}
+
+var _ = Describe("ControllerFactory", func() {
+
+	var mgr *recordingManager
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+		mgr = &recordingManager{scheme: scheme, client: fake.NewFakeClientWithScheme(scheme)}
+	})
+
+	createFactory := func() *ControllerFactory {
+		return &ControllerFactory{
+			Prototype: &v1alpha1.ATest{},
+			ResourceManagerCreator: func(logr.Logger, record.EventRecorder) ResourceManager {
+				return &stubResourceManager{}
+			},
+			DefinitionManager:  aTestDefinitionManager{},
+			FinalizerName:      "a.finalizers.com",
+			AnnotationBaseName: "test.example.com",
+		}
+	}
+
+	It("returns the GenericController of the kind", func() {
+		gc, err := createFactory().SetupWithManager(mgr, ReconcileParameters{}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gc.ResourceKind).To(Equal("ATest"))
+		Expect(gc.ResourceManager).To(Equal(&stubResourceManager{}))
+		// without webhooks, the reconciler applies the defaults of the DefinitionManager
+		Expect(gc.Defaulter).To(Equal(aTestDefinitionManager{}))
+		Expect(mgr.handlerOf(&v1alpha1.ATest{})).To(Equal(&handler.EnqueueRequestForObject{}))
+	})
+
+	It("reconciles the owner of a changed object of the OwnedKinds", func() {
+		factory := createFactory()
+		factory.OwnedKinds = []runtime.Object{&corev1.Secret{}}
+		_, err := factory.SetupWithManager(mgr, ReconcileParameters{}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mgr.handlerOf(&corev1.Secret{})).To(Equal(&handler.EnqueueRequestForOwner{OwnerType: &v1alpha1.ATest{}, IsController: true}))
+	})
+
+	It("reconciles the dependents of a changed object of the DependencyKinds", func() {
+		factory := createFactory()
+		factory.DependencyKinds = []runtime.Object{&v1alpha1.BTest{}}
+		_, err := factory.SetupWithManager(mgr, ReconcileParameters{}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mgr.handlerOf(&v1alpha1.BTest{})).To(BeAssignableToTypeOf(&handler.EnqueueRequestsFromMapFunc{}))
+		Expect(mgr.handlerOf(&corev1.Secret{})).To(BeNil())
+	})
+
+	It("requires a Prototype and a ResourceManagerCreator", func() {
+		factory := createFactory()
+		factory.Prototype = nil
+		_, err := factory.SetupWithManager(mgr, ReconcileParameters{}, nil)
+		Expect(err).To(HaveOccurred())
+
+		factory = createFactory()
+		factory.ResourceManagerCreator = nil
+		_, err = factory.SetupWithManager(mgr, ReconcileParameters{}, nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,103 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Defaulter can optionally be implemented by the DefinitionManager or the ResourceManager of a kind to set the defaults of the spec,
+// for example to derive an id from the namespace and name of the resource.
+// The defaults are applied by a defaulting webhook if the webhooks of the kind are enabled, and by the reconciler otherwise.
+// Default must be idempotent
+type Defaulter interface {
+	// sets the defaults of the instance in place
+	Default(ctx context.Context, instance runtime.Object) error
+}
+
+// DefaultingWebhook is an admission handler that calls the Defaulter of a kind
+type DefaultingWebhook struct {
+	Defaulter         Defaulter
+	DefinitionManager DefinitionManager
+	decoder           *admission.Decoder
+}
+
+func CreateDefaultingWebhook(defaulter Defaulter, definitionManager DefinitionManager, scheme *runtime.Scheme) (*DefaultingWebhook, error) {
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		return nil, err
+	}
+	return &DefaultingWebhook{
+		Defaulter:         defaulter,
+		DefinitionManager: definitionManager,
+		decoder:           decoder,
+	}, nil
+}
+
+// InjectDecoder is called by the webhook server to set the decoder
+func (w *DefaultingWebhook) InjectDecoder(decoder *admission.Decoder) error {
+	w.decoder = decoder
+	return nil
+}
+
+func (w *DefaultingWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	instance := w.DefinitionManager.GetDefinition(ctx, types.NamespacedName{Namespace: req.Namespace, Name: req.Name}).InitialInstance
+	if err := w.decoder.Decode(req, instance); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if err := w.Defaulter.Default(ctx, instance); err != nil {
+		return admission.Denied(err.Error())
+	}
+	defaulted, err := json.Marshal(instance)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, defaulted)
+}
+
+// returns the path the defaulting webhook of the kind is served on, following the Kubebuilder convention
+func defaultingWebhookPath(gvk schema.GroupVersionKind) string {
+	return "/mutate-" + strings.Replace(gvk.Group, ".", "-", -1) + "-" + gvk.Version + "-" + strings.ToLower(gvk.Kind)
+}
+
+// applies the defaults of the Defaulter of the controller, updating the resource if they change it.
+// returns whether the resource was updated, in which case the update triggers the next reconcile
+func (r *reconcileRunner) applyDefaults(ctx context.Context) (bool, error) {
+	if r.Defaulter == nil {
+		return false, nil
+	}
+	defaulted := r.instance.DeepCopyObject()
+	if err := r.Defaulter.Default(ctx, defaulted); err != nil {
+		return false, err
+	}
+	if equality.Semantic.DeepEqual(r.instance, defaulted) {
+		return false, nil
+	}
+	r.log.Info("Applying defaults to resource")
+	if err := r.KubeClient.Update(ctx, defaulted); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,267 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// fetches the Spec of the instance of runtime.Object
+type SpecGetter = func(instance runtime.Object) (interface{}, error)
+
+// DefinitionBuilder builds the ResourceDefinition of a kind from a prototype object using reflection,
+// so that no accessor code needs to be written for the kind.
+// The prototype must be a pointer to a struct with Spec and Status fields. The Status must have State and Message string fields,
+// either directly or through an embedded struct (typically the shared status type of the API).
+// The LastTransitionTime, Operation, Transitions and Conditions fields of Status are persisted if the Status has fields with the same names,
+// and fields of nested structs are matched by name, ignoring case.
+// Matching fields must have assignable types, or be strings, booleans or numbers of the same kind, such as a ReconcileState and a string.
+// Fields of other types, and structs embedded by pointer, are rejected when the DefinitionBuilder is created
+type DefinitionBuilder struct {
+	Kind       string
+	objectType reflect.Type
+}
+
+func CreateDefinitionBuilder(prototype runtime.Object) (*DefinitionBuilder, error) {
+	t := reflect.TypeOf(prototype)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("prototype must be a pointer to a struct, got %T", prototype)
+	}
+	b := &DefinitionBuilder{Kind: t.Elem().Name(), objectType: t.Elem()}
+	if _, ok := b.objectType.FieldByName("Spec"); !ok {
+		return nil, fmt.Errorf("kind %s has no Spec field", b.Kind)
+	}
+	statusField, ok := b.objectType.FieldByName("Status")
+	if !ok || statusField.Type.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("kind %s has no Status struct field", b.Kind)
+	}
+	for _, name := range []string{"State", "Message"} {
+		f, ok := statusField.Type.FieldByName(name)
+		if !ok || f.Type.Kind() != reflect.String {
+			return nil, fmt.Errorf("Status of kind %s has no %s string field", b.Kind, name)
+		}
+	}
+	if path := embeddedPointer(statusField.Type, "Status", map[reflect.Type]bool{}); path != "" {
+		return nil, fmt.Errorf("kind %s embeds a struct by pointer at %s, which can't be copied", b.Kind, path)
+	}
+	statusType := reflect.TypeOf(Status{})
+	incompatible := incompatibleFields(statusType, statusField.Type, "Status", nil)
+	incompatible = incompatibleFields(statusField.Type, statusType, "Status", incompatible)
+	if len(incompatible) > 0 {
+		return nil, fmt.Errorf("Status of kind %s has fields of types that can't be copied: %s", b.Kind, strings.Join(incompatible, ", "))
+	}
+	return b, nil
+}
+
+// MustCreateDefinitionBuilder is like CreateDefinitionBuilder, but panics if the prototype is invalid.
+// It is intended for package level variables
+func MustCreateDefinitionBuilder(prototype runtime.Object) *DefinitionBuilder {
+	b, err := CreateDefinitionBuilder(prototype)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// GetDefinition returns a ResourceDefinition with a new empty instance of the kind.
+// This enables the DefinitionBuilder to be embedded in a DefinitionManager
+func (b *DefinitionBuilder) GetDefinition(ctx context.Context, namespacedName types.NamespacedName) *ResourceDefinition {
+	return &ResourceDefinition{
+		InitialInstance: b.NewInstance(),
+		StatusAccessor:  b.GetStatus,
+		StatusUpdater:   b.UpdateStatus,
+	}
+}
+
+// GetDependency returns a Dependency on a resource of the kind
+func (b *DefinitionBuilder) GetDependency(namespacedName types.NamespacedName) *Dependency {
+	return &Dependency{
+		InitialInstance:   b.NewInstance(),
+		NamespacedName:    namespacedName,
+		SucceededAccessor: b.GetSuccess,
+	}
+}
+
+// NewInstance returns a new empty instance of the kind
+func (b *DefinitionBuilder) NewInstance() runtime.Object {
+	return reflect.New(b.objectType).Interface().(runtime.Object)
+}
+
+func (b *DefinitionBuilder) GetStatus(instance runtime.Object) (*Status, error) {
+	v, err := b.convertInstance(instance)
+	if err != nil {
+		return nil, err
+	}
+	status := &Status{}
+	copyFields(reflect.ValueOf(status).Elem(), v.FieldByName("Status"))
+	return status, nil
+}
+
+func (b *DefinitionBuilder) UpdateStatus(instance runtime.Object, status *Status) error {
+	v, err := b.convertInstance(instance)
+	if err != nil {
+		return err
+	}
+	copyFields(v.FieldByName("Status"), reflect.ValueOf(status).Elem())
+	return nil
+}
+
+func (b *DefinitionBuilder) GetSuccess(instance runtime.Object) (bool, error) {
+	return AsSuccessAccessor(b.GetStatus)(instance)
+}
+
+// GetSpec returns a pointer to the Spec of the instance
+func (b *DefinitionBuilder) GetSpec(instance runtime.Object) (interface{}, error) {
+	v, err := b.convertInstance(instance)
+	if err != nil {
+		return nil, err
+	}
+	return v.FieldByName("Spec").Addr().Interface(), nil
+}
+
+func (b *DefinitionBuilder) convertInstance(instance runtime.Object) (reflect.Value, error) {
+	v := reflect.ValueOf(instance)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Type() != b.objectType {
+		return reflect.Value{}, fmt.Errorf("failed type assertion on kind: %s", b.Kind)
+	}
+	return v.Elem(), nil
+}
+
+// copies each field of dst from the field of src with the same name (ignoring case), converting where necessary.
+// fields of embedded structs in dst are treated as fields of dst
+func copyFields(dst reflect.Value, src reflect.Value) {
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			copyFields(dst.Field(i), src)
+			continue
+		}
+		name := field.Name
+		srcField := src.FieldByNameFunc(func(n string) bool { return strings.EqualFold(n, name) })
+		if srcField.IsValid() {
+			convertValue(dst.Field(i), srcField)
+		}
+	}
+}
+
+// copies src to dst if their types are compatible, converting strings, booleans and numbers of the same kind
+func convertValue(dst reflect.Value, src reflect.Value) {
+	switch {
+	case src.Type().AssignableTo(dst.Type()):
+		dst.Set(src)
+	case src.Kind() == dst.Kind() && isScalar(src.Kind()):
+		dst.Set(src.Convert(dst.Type()))
+	case src.Kind() == reflect.Ptr && dst.Kind() == reflect.Ptr:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(dst.Type()))
+			return
+		}
+		dst.Set(reflect.New(dst.Type().Elem()))
+		convertValue(dst.Elem(), src.Elem())
+	case src.Kind() == reflect.Slice && dst.Kind() == reflect.Slice:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(dst.Type()))
+			return
+		}
+		dst.Set(reflect.MakeSlice(dst.Type(), src.Len(), src.Len()))
+		for i := 0; i < src.Len(); i++ {
+			convertValue(dst.Index(i), src.Index(i))
+		}
+	case src.Kind() == reflect.Struct && dst.Kind() == reflect.Struct:
+		copyFields(dst, src)
+	}
+}
+
+func isScalar(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// appends the paths of the fields of dst that copyFields would match in src, but whose types convertValue can't copy
+func incompatibleFields(dst reflect.Type, src reflect.Type, path string, incompatible []string) []string {
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			incompatible = incompatibleFields(field.Type, src, path, incompatible)
+			continue
+		}
+		name := field.Name
+		srcField, ok := src.FieldByNameFunc(func(n string) bool { return strings.EqualFold(n, name) })
+		if ok {
+			incompatible = incompatibleTypes(field.Type, srcField.Type, path+"."+name, incompatible)
+		}
+	}
+	return incompatible
+}
+
+func incompatibleTypes(dst reflect.Type, src reflect.Type, path string, incompatible []string) []string {
+	switch {
+	case src.AssignableTo(dst):
+		return incompatible
+	case src.Kind() == dst.Kind() && isScalar(src.Kind()):
+		return incompatible
+	case src.Kind() == reflect.Ptr && dst.Kind() == reflect.Ptr,
+		src.Kind() == reflect.Slice && dst.Kind() == reflect.Slice:
+		return incompatibleTypes(dst.Elem(), src.Elem(), path, incompatible)
+	case src.Kind() == reflect.Struct && dst.Kind() == reflect.Struct:
+		return incompatibleFields(dst, src, path, incompatible)
+	}
+	return append(incompatible, fmt.Sprintf("%s (%s and %s)", path, src, dst))
+}
+
+// returns the path of a struct embedded by pointer in the type, or in the types of its fields, or "" if there is none.
+// finding a field of a struct through a nil embedded pointer panics
+func embeddedPointer(t reflect.Type, path string, visited map[reflect.Type]bool) string {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return embeddedPointer(t.Elem(), path, visited)
+	case reflect.Struct:
+	default:
+		return ""
+	}
+	if visited[t] {
+		return ""
+	}
+	visited[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Ptr {
+			return path + "." + field.Name
+		}
+		if found := embeddedPointer(field.Type, path+"."+field.Name, visited); found != "" {
+			return found
+		}
+	}
+	return ""
+}
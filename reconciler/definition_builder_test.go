@@ -0,0 +1,113 @@
+package reconciler
+
+import (
+	"reflect"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/operatify/operatify/api/v1alpha1"
+	"github.com/operatify/operatify/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type sharedStatus struct {
+	State   string
+	Message string
+}
+
+// a kind whose Status embeds the shared status type by pointer
+type pointerEmbedKind struct {
+	metav1.TypeMeta
+	Spec   struct{}
+	Status struct {
+		*sharedStatus
+	}
+}
+
+func (k *pointerEmbedKind) DeepCopyObject() runtime.Object { return k }
+
+// a kind whose Status has an Operation of a type that can't be converted from an OperationHandle
+type mismatchedKind struct {
+	metav1.TypeMeta
+	Spec   struct{}
+	Status struct {
+		State     string
+		Message   string
+		Operation string
+	}
+}
+
+func (k *mismatchedKind) DeepCopyObject() runtime.Object { return k }
+
+// a kind whose Transitions have a From of a different kind than a ReconcileState
+type mismatchedNestedKind struct {
+	metav1.TypeMeta
+	Spec   struct{}
+	Status struct {
+		sharedStatus
+		Transitions []struct {
+			From int
+		}
+	}
+}
+
+func (k *mismatchedNestedKind) DeepCopyObject() runtime.Object { return k }
+
+var _ = Describe("DefinitionBuilder", func() {
+
+	table.DescribeTable("validates the prototype",
+		func(prototype runtime.Object, expectedErr string) {
+			_, err := CreateDefinitionBuilder(prototype)
+			if expectedErr == "" {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(MatchError(ContainSubstring(expectedErr)))
+			}
+		},
+		table.Entry("v1alpha1", &v1alpha1.ATest{}, ""),
+		table.Entry("v1alpha1 with a payload", &v1alpha1.BTest{}, ""),
+		table.Entry("v1beta1", &v1beta1.ATest{}, ""),
+		table.Entry("pointer embed", &pointerEmbedKind{}, "embeds a struct by pointer at Status.sharedStatus"),
+		table.Entry("mismatched field", &mismatchedKind{}, "Status.Operation"),
+		table.Entry("mismatched nested field", &mismatchedNestedKind{}, "Status.Transitions.From"),
+	)
+
+	It("copies the Status to and from an instance", func() {
+		b := MustCreateDefinitionBuilder(&v1alpha1.ATest{})
+		now := metav1.Now()
+		status := &Status{
+			State:              Verifying,
+			Message:            "verifying",
+			LastTransitionTime: &now,
+			Operation:          &OperationHandle{ID: "op-1", Type: OperationCreate},
+			Transitions:        []Transition{{From: Creating, To: Verifying, Reason: "Ensure", Time: now, Generation: 2}},
+			Conditions:         []Condition{{Type: BackendUnavailable, Status: corev1.ConditionFalse, Reason: "CircuitClosed"}},
+		}
+		instance := &v1alpha1.ATest{}
+		Expect(b.UpdateStatus(instance, status)).To(Succeed())
+		Expect(instance.Status.State).To(Equal("Verifying"))
+		Expect(instance.Status.Operation).To(Equal(&v1alpha1.Operation{Id: "op-1", Type: "Create"}))
+		Expect(instance.Status.Transitions[0].From).To(Equal("Creating"))
+
+		Expect(b.GetStatus(instance)).To(Equal(status))
+	})
+
+	table.DescribeTable("converts values of compatible types",
+		func(dst interface{}, src interface{}, expected interface{}) {
+			dstValue := reflect.New(reflect.TypeOf(dst)).Elem()
+			dstValue.Set(reflect.ValueOf(dst))
+			convertValue(dstValue, reflect.ValueOf(src))
+			Expect(dstValue.Interface()).To(Equal(expected))
+		},
+		table.Entry("named string to string", "", Succeeded, "Succeeded"),
+		table.Entry("string to named string", Pending, "Failed", Failed),
+		table.Entry("int to int64 isn't converted", int64(1), 3, int64(1)),
+		table.Entry("int to string isn't converted", "unchanged", 65, "unchanged"),
+		table.Entry("string to int isn't converted", 1, "2", 1),
+		table.Entry("nil pointer", &OperationHandle{ID: "op"}, (*v1alpha1.Operation)(nil), (*OperationHandle)(nil)),
+		table.Entry("nil slice", []Transition{{}}, []v1alpha1.Transition(nil), []Transition(nil)),
+	)
+})
@@ -21,6 +21,7 @@ import (
 
 	"github.com/go-logr/logr"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 
 	"k8s.io/client-go/tools/record"
@@ -41,6 +42,30 @@ type GenericController struct {
 	FinalizerName      string
 	AnnotationBaseName string
 	CompletionRunner   func(*GenericController) CompletionRunner
+	CircuitBreaker     *CircuitBreaker
+	// If set, its defaults are applied to resources before they are reconciled.
+	// This is set by the ControllerFactory when the defaulting webhook of the kind is not served
+	Defaulter Defaulter
+	// The paths of the spec fields, such as spec.location, that can't be changed on the external resource once it is applied.
+	// A change to one of these is handled according to Parameters.ImmutableFieldChange
+	ImmutableFields []string
+	// Base names the annotations were previously set under. These are read if an annotation isn't set under the AnnotationBaseName,
+	// and the annotations are moved to the AnnotationBaseName the next time the resource is updated
+	LegacyAnnotationBaseNames []string
+	// If set, only the resources in the shards held by this replica are reconciled
+	ShardManager *ShardManager
+	// Empty instances of the kinds resources can reference as their provider config with spec.providerConfigRef
+	ProviderConfigKinds []runtime.Object
+	// If set, creates the ResourceManager used for the resources referencing a provider config, instead of the ResourceManager
+	ProviderResourceManagerCreator func(*ProviderConfig) (ResourceManager, error)
+	// If set, used to find whether a provider config kind is cluster-scoped. It is set by the ControllerFactory
+	RESTMapper apimeta.RESTMapper
+	// If set, the credentials Secrets of provider configs are read with this instead of the KubeClient,
+	// so Secrets aren't cached. It is set by the ControllerFactory to the API reader of the manager
+	APIReader client.Reader
+	// parsed from the LabelSelector of the Parameters
+	selector                 labels.Selector
+	providerResourceManagers providerResourceManagers
 }
 
 // A handler that is invoked after the resource has been successfully created
@@ -54,6 +79,30 @@ type ReconcileParameters struct {
 	RequeueAfter        int
 	RequeueAfterSuccess int
 	RequeueAfterFailure int
+	// The number of consecutive ResourceManager errors after which calls to the ResourceManager are paused.
+	// The circuit breaker is disabled if this is 0
+	CircuitBreakerThreshold int
+	// The number of milliseconds calls are paused for before a probe call is allowed through (defaults to 30000)
+	CircuitBreakerResetAfter int
+	// The maximum number of milliseconds a resource may stay in the Verifying or Recreating state before it fails.
+	// There is no limit if these are 0
+	VerifyingTimeout  int
+	RecreatingTimeout int
+	// The number of milliseconds after which TerminationEscalation is applied to a resource awaiting deletion.
+	// There is no limit if this is 0
+	TerminatingTimeout    int
+	TerminationEscalation TerminationEscalation
+	// The number of state transitions kept in the Status (defaults to 10). A negative value disables the history
+	TransitionHistorySize int
+	// What is done when an immutable field of a resource is changed (defaults to ImmutableFieldChangeRecreate)
+	ImmutableFieldChange ImmutableFieldChange
+	// The namespaces resources are reconciled in. Resources in every namespace are reconciled if this is empty
+	Namespaces []string
+	// If set, only resources matching this label selector, such as "team=payments", are reconciled
+	LabelSelector string
+	// The class of the controller. Only the resources assigned to this class with the controller-class annotation are reconciled,
+	// and the resources assigned to another class are handed over to the controller of that class
+	ControllerClass string
 }
 
 func CreateGenericController(
@@ -81,9 +130,15 @@ func CreateGenericController(
 		AnnotationBaseName: annotationBaseName,
 		CompletionRunner:   completionRunner,
 	}
+	gc.CircuitBreaker = parameters.circuitBreaker()
 	if err := gc.validate(); err != nil {
 		return nil, err
 	}
+	selector, err := parameters.labelSelector()
+	if err != nil {
+		return nil, fmt.Errorf("invalid label selector for controller for %s: %v", resourceKind, err)
+	}
+	gc.selector = selector
 	return gc, nil
 }
 
@@ -111,6 +166,11 @@ func (gc *GenericController) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.TODO()
 	log := gc.Log.WithValues("Name", req.NamespacedName)
 
+	// resources in the shards of other replicas are reconciled by those replicas
+	if gc.ShardManager != nil && !gc.ShardManager.Owns(req.NamespacedName) {
+		return ctrl.Result{}, nil
+	}
+
 	// fetch the manifest object
 	thisDefs := gc.DefinitionManager.GetDefinition(ctx, req.NamespacedName)
 
@@ -127,6 +187,11 @@ func (gc *GenericController) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	status, err := thisDefs.StatusAccessor(instance)
 	metaObject, _ := apimeta.Accessor(instance)
 
+	// resources outside the namespaces, the label selector or the class of the controller are left to other controllers
+	if !gc.inScope(metaObject) {
+		return ctrl.Result{}, nil
+	}
+
 	instanceUpdater := instanceUpdater{
 		StatusUpdater: thisDefs.StatusUpdater,
 	}
@@ -151,6 +216,18 @@ func (gc *GenericController) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		req:                   req,
 		log:                   log,
 		instanceUpdater:       &instanceUpdater,
+		ResourceManager:       gc.ResourceManager,
+		CircuitBreaker:        gc.CircuitBreaker,
+	}
+
+	// claim the resource, or hand it over if it has been assigned to the controller of another class
+	if done, result, err := reconcileRunner.handOver(ctx); done {
+		return result, err
+	}
+
+	// resolve the provider config of the resource, which is passed to the ResourceManager
+	if done, result, err := reconcileRunner.useProviderConfig(ctx); done {
+		return result, err
 	}
 
 	// handle finalization first
@@ -164,6 +241,14 @@ func (gc *GenericController) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return reconcileFinalizer.handle()
 	}
 
+	// apply the defaults the defaulting webhook would otherwise have applied
+	if defaulted, err := reconcileRunner.applyDefaults(ctx); defaulted || err != nil {
+		if err != nil {
+			log.Info("Unable to apply defaults to resource", "err", err.Error())
+		}
+		return ctrl.Result{}, err
+	}
+
 	// if no finalizers have been defined, do that and requeue
 	if !reconcileFinalizer.isDefined() {
 		return reconcileFinalizer.add(ctx)
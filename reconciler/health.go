@@ -0,0 +1,218 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// HealthChecker can be implemented by a ResourceManager to report whether the backend of the external resources is reachable
+type HealthChecker interface {
+	// Returns an error if the backend is unavailable
+	CheckHealth(ctx context.Context) error
+}
+
+// BackendStatus is the health of the backend of a kind, as last checked
+type BackendStatus struct {
+	Kind    string `json:"kind"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+	// The state of the circuit breaker of the controller, if it has one
+	CircuitState CircuitState `json:"circuitState,omitempty"`
+	LastChecked  time.Time    `json:"lastChecked"`
+}
+
+// HealthChecks checks the backends of the controllers added to it, and serves the status of each backend as JSON.
+// Check can be added to the readiness probe of the manager, so that the operator isn't ready while a backend is unavailable
+type HealthChecks struct {
+	// The number of milliseconds a check of a backend is reused for (defaults to 10000), so probes don't load the backend
+	CacheFor int
+	// The number of milliseconds a backend is given to respond to a check (defaults to 5000)
+	Timeout int
+	now     func() time.Time
+
+	lock        sync.Mutex
+	controllers map[string]*GenericController
+	statuses    map[string]BackendStatus
+}
+
+func CreateHealthChecks(cacheFor int, timeout int) *HealthChecks {
+	return &HealthChecks{
+		CacheFor:    cacheFor,
+		Timeout:     timeout,
+		now:         time.Now,
+		controllers: map[string]*GenericController{},
+		statuses:    map[string]BackendStatus{},
+	}
+}
+
+// Add adds the controller, returning the check of its backend alone
+func (h *HealthChecks) Add(gc *GenericController) healthz.Checker {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.controllers[gc.ResourceKind] = gc
+	return func(_ *http.Request) error {
+		status := h.Status(gc.ResourceKind)
+		if !status.Healthy {
+			return fmt.Errorf("backend of %s is unavailable: %s", gc.ResourceKind, status.Error)
+		}
+		return nil
+	}
+}
+
+// Status returns the health of the backend of the kind, checking it again if the last check is older than CacheFor
+func (h *HealthChecks) Status(kind string) BackendStatus {
+	h.lock.Lock()
+	gc, ok := h.controllers[kind]
+	status, checked := h.statuses[kind]
+	h.lock.Unlock()
+	if !ok {
+		return BackendStatus{Kind: kind, Error: "no controller for kind"}
+	}
+	if checked && h.now().Sub(status.LastChecked) < durationOrDefault(h.CacheFor, 10000) {
+		return status
+	}
+
+	status = BackendStatus{Kind: kind, LastChecked: h.now()}
+	err := h.check(gc)
+	if err != nil {
+		status.Error = err.Error()
+	}
+	status.Healthy = err == nil
+	if gc.CircuitBreaker != nil {
+		status.CircuitState = gc.CircuitBreaker.State()
+	}
+	h.lock.Lock()
+	h.statuses[kind] = status
+	h.lock.Unlock()
+	return status
+}
+
+// Statuses returns the health of the backends of all the kinds, ordered by kind
+func (h *HealthChecks) Statuses() []BackendStatus {
+	h.lock.Lock()
+	kinds := make([]string, 0, len(h.controllers))
+	for kind := range h.controllers {
+		kinds = append(kinds, kind)
+	}
+	h.lock.Unlock()
+	sort.Strings(kinds)
+
+	statuses := make([]BackendStatus, len(kinds))
+	for i, kind := range kinds {
+		statuses[i] = h.Status(kind)
+	}
+	return statuses
+}
+
+// Check is a healthz.Checker of the backends of all the kinds, which fails if any backend is unavailable
+func (h *HealthChecks) Check(_ *http.Request) error {
+	var errs []string
+	for _, status := range h.Statuses() {
+		if !status.Healthy {
+			errs = append(errs, fmt.Sprintf("backend of %s is unavailable: %s", status.Kind, status.Error))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ServeHTTP serves the Statuses as JSON, with a 503 status code if any backend is unavailable
+func (h *HealthChecks) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	statuses := h.Statuses()
+	code := http.StatusOK
+	for _, status := range statuses {
+		if !status.Healthy {
+			code = http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(statuses)
+}
+
+// checks the ResourceManager of the controller and those created for provider configs.
+// a backend is unavailable if calls to it are paused by the circuit breaker, even if its ResourceManager can't be checked
+func (h *HealthChecks) check(gc *GenericController) error {
+	if gc.CircuitBreaker != nil && gc.CircuitBreaker.State() == CircuitOpen {
+		return fmt.Errorf("calls are paused after repeated errors")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), durationOrDefault(h.Timeout, 5000))
+	defer cancel()
+
+	var errs []string
+	for _, key := range gc.openProviderCircuits() {
+		errs = append(errs, fmt.Sprintf("calls with %s are paused after repeated errors", key))
+	}
+	for _, resourceManager := range gc.resourceManagers() {
+		if checker, ok := findImplementation(resourceManager, func(rm ResourceManager) bool {
+			_, ok := rm.(HealthChecker)
+			return ok
+		}).(HealthChecker); ok {
+			if err := checker.CheckHealth(ctx); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// returns the ResourceManager of the controller and those created for provider configs
+func (gc *GenericController) resourceManagers() []ResourceManager {
+	gc.providerResourceManagers.lock.Lock()
+	defer gc.providerResourceManagers.lock.Unlock()
+	resourceManagers := []ResourceManager{gc.ResourceManager}
+	for _, providerResourceManager := range gc.providerResourceManagers.managers {
+		resourceManagers = append(resourceManagers, providerResourceManager.resourceManager)
+	}
+	return resourceManagers
+}
+
+func durationOrDefault(millis int, defaultMillis int) time.Duration {
+	if millis == 0 {
+		millis = defaultMillis
+	}
+	return time.Duration(millis) * time.Millisecond
+}
+
+// returns the provider configs whose calls are paused by their circuit breakers, ordered by kind and name
+func (gc *GenericController) openProviderCircuits() []string {
+	gc.providerResourceManagers.lock.Lock()
+	defer gc.providerResourceManagers.lock.Unlock()
+	var keys []string
+	for key, circuitBreaker := range gc.providerResourceManagers.circuitBreakers {
+		if circuitBreaker.State() == CircuitOpen {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,107 @@
+package reconciler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// a ResourceManager whose backend can be made unavailable
+type checkedResourceManager struct {
+	stubResourceManager
+	health error
+}
+
+func (c *checkedResourceManager) CheckHealth(_ context.Context) error {
+	return c.health
+}
+
+// a ResourceManager decorating another
+type wrappingResourceManager struct {
+	ResourceManager
+}
+
+func (w *wrappingResourceManager) Unwrap() ResourceManager {
+	return w.ResourceManager
+}
+
+var _ = Describe("Health checks", func() {
+
+	var now time.Time
+	var healthChecks *HealthChecks
+	var resourceManager *checkedResourceManager
+	var gc *GenericController
+
+	BeforeEach(func() {
+		now = time.Unix(0, 0)
+		healthChecks = CreateHealthChecks(1000, 1000)
+		healthChecks.now = func() time.Time { return now }
+		resourceManager = &checkedResourceManager{}
+		gc = &GenericController{ResourceKind: "Resource", ResourceManager: resourceManager}
+	})
+
+	It("should report the health of the backend of each kind", func() {
+		checker := healthChecks.Add(gc)
+		Expect(checker(nil)).To(Succeed())
+
+		resourceManager.health = errors.New("connection refused")
+		// the last check is reused until it is older than CacheFor
+		Expect(checker(nil)).To(Succeed())
+		now = now.Add(time.Second)
+		Expect(checker(nil)).To(MatchError(ContainSubstring("connection refused")))
+
+		response := httptest.NewRecorder()
+		healthChecks.ServeHTTP(response, nil)
+		Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+		var statuses []BackendStatus
+		Expect(json.Unmarshal(response.Body.Bytes(), &statuses)).To(Succeed())
+		Expect(statuses).To(HaveLen(1))
+		Expect(statuses[0].Kind).To(Equal("Resource"))
+		Expect(statuses[0].Healthy).To(BeFalse())
+		Expect(statuses[0].Error).To(Equal("connection refused"))
+
+		resourceManager.health = nil
+		now = now.Add(time.Second)
+		Expect(checker(nil)).To(Succeed())
+	})
+
+	It("should report the backend unavailable while its circuit breaker is open", func() {
+		gc.Parameters = ReconcileParameters{CircuitBreakerThreshold: 1}
+		gc.CircuitBreaker = gc.Parameters.circuitBreaker()
+		healthChecks.Add(gc)
+
+		Expect(gc.CircuitBreaker.acquire()).To(BeTrue())
+		gc.CircuitBreaker.record(errors.New("timeout"))
+		status := healthChecks.Status("Resource")
+		Expect(status.Healthy).To(BeFalse())
+		Expect(status.CircuitState).To(Equal(CircuitOpen))
+	})
+
+	It("should check the backend of a wrapped ResourceManager", func() {
+		gc.ResourceManager = &wrappingResourceManager{ResourceManager: &wrappingResourceManager{ResourceManager: resourceManager}}
+		healthChecks.Add(gc)
+		resourceManager.health = errors.New("connection refused")
+		Expect(healthChecks.Status("Resource").Error).To(Equal("connection refused"))
+	})
+
+	It("should fail the check of all the backends if any is unavailable", func() {
+		healthChecks.Add(gc)
+		other := &checkedResourceManager{}
+		healthChecks.Add(&GenericController{ResourceKind: "Other", ResourceManager: other})
+		Expect(healthChecks.Check(nil)).To(Succeed())
+
+		other.health = errors.New("connection refused")
+		now = now.Add(time.Second)
+		Expect(healthChecks.Check(nil)).To(MatchError("backend of Other is unavailable: connection refused"))
+	})
+
+	It("should report kinds without a controller", func() {
+		Expect(healthChecks.Status("Unknown").Error).To(Equal("no controller for kind"))
+	})
+})
@@ -0,0 +1,136 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ImmutableFieldChange is what is done when an immutable field of a resource is changed
+type ImmutableFieldChange string
+
+const (
+	// The external resource is deleted and created again, if the 'D' access permission is set (the default)
+	ImmutableFieldChangeRecreate ImmutableFieldChange = "Recreate"
+	// The change is rejected: the resource fails until the field is changed back,
+	// and the validating webhook of the kind denies the update if webhooks are enabled
+	ImmutableFieldChangeReject ImmutableFieldChange = "Reject"
+	// The external resource is only deleted and created again once the change is approved,
+	// by setting the approve-recreate annotation to the generation of the resource with the change
+	ImmutableFieldChangeRequireApproval ImmutableFieldChange = "RequireApproval"
+)
+
+// approves recreating the external resource after a change to an immutable field, when set to the generation of the resource with the change
+const ApproveRecreateAnnotation = "/approve-recreate"
+
+// returns the immutable fields of the resource, such as spec.location, that differ from the last applied spec.
+// nothing is returned if there is no last applied spec, as the resource hasn't been applied successfully
+func (r *reconcileRunner) changedImmutableFields() []string {
+	if len(r.ImmutableFields) == 0 {
+		return nil
+	}
+	lastApplied := r.getAnnotation(LastAppliedAnnotation)
+	if lastApplied == "" {
+		return nil
+	}
+	var lastAppliedSpec interface{}
+	if err := json.Unmarshal([]byte(lastApplied), &lastAppliedSpec); err != nil {
+		r.log.Info(fmt.Sprintf("Unable to read last applied spec: %v", err))
+		return nil
+	}
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(r.instance)
+	if err != nil {
+		r.log.Info(fmt.Sprintf("Unable to convert resource: %v", err))
+		return nil
+	}
+	return changedFields(map[string]interface{}{"spec": lastAppliedSpec}, object, r.ImmutableFields)
+}
+
+func (r *reconcileRunner) rejectImmutableFieldChange() bool {
+	return r.Parameters.ImmutableFieldChange == ImmutableFieldChangeReject
+}
+
+// returns whether recreating the external resource after a change to an immutable field may go ahead
+func (r *reconcileRunner) recreateApproved() bool {
+	if r.Parameters.ImmutableFieldChange != ImmutableFieldChangeRequireApproval {
+		return true
+	}
+	return r.getAnnotation(ApproveRecreateAnnotation) == strconv.FormatInt(r.objectMeta.GetGeneration(), 10)
+}
+
+func (r *reconcileRunner) awaitingRecreateApprovalError(changed []string) error {
+	return fmt.Errorf("immutable fields changed: %s. Recreating the external resource must be approved by setting the annotation %s to %d",
+		strings.Join(changed, ", "), r.AnnotationBaseName+ApproveRecreateAnnotation, r.objectMeta.GetGeneration())
+}
+
+func immutableFieldsError(changed []string) error {
+	return fmt.Errorf("immutable fields cannot be changed: %s", strings.Join(changed, ", "))
+}
+
+// returns the paths of the fields that differ between the objects.
+// the values are compared as JSON, as numbers are float64 in objects decoded from JSON, but int64 in objects converted to unstructured
+func changedFields(oldObject map[string]interface{}, object map[string]interface{}, paths []string) []string {
+	var changed []string
+	for _, path := range paths {
+		oldValue, _, _ := unstructured.NestedFieldNoCopy(oldObject, fieldPath(path)...)
+		value, _, _ := unstructured.NestedFieldNoCopy(object, fieldPath(path)...)
+		if !equalAsJson(oldValue, value) {
+			changed = append(changed, path)
+		}
+	}
+	return changed
+}
+
+func equalAsJson(a interface{}, b interface{}) bool {
+	aJson, aErr := json.Marshal(a)
+	bJson, bErr := json.Marshal(b)
+	if aErr != nil || bErr != nil {
+		return reflect.DeepEqual(a, b)
+	}
+	return bytes.Equal(aJson, bJson)
+}
+
+// rejects updates that change the immutable fields of a kind, before calling the Validator of the kind if there is one
+type immutableFieldsValidator struct {
+	paths     []string
+	validator Validator
+}
+
+func (v *immutableFieldsValidator) ValidateCreate(ctx context.Context, instance runtime.Object) error {
+	if v.validator == nil {
+		return nil
+	}
+	return v.validator.ValidateCreate(ctx, instance)
+}
+
+func (v *immutableFieldsValidator) ValidateUpdate(ctx context.Context, oldInstance runtime.Object, instance runtime.Object) error {
+	if err := CheckImmutableFields(oldInstance, instance, v.paths...); err != nil {
+		return err
+	}
+	if v.validator == nil {
+		return nil
+	}
+	return v.validator.ValidateUpdate(ctx, oldInstance, instance)
+}
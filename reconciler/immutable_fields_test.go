@@ -0,0 +1,61 @@
+package reconciler
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Immutable fields", func() {
+
+	// the last applied spec is decoded from JSON, and the resource is converted to unstructured
+	decode := func(spec string) map[string]interface{} {
+		var decoded interface{}
+		Expect(json.Unmarshal([]byte(spec), &decoded)).To(Succeed())
+		return map[string]interface{}{"spec": decoded}
+	}
+
+	table.DescribeTable("changedFields",
+		func(lastApplied string, spec map[string]interface{}, expected []string) {
+			object := map[string]interface{}{"spec": spec}
+			Expect(changedFields(decode(lastApplied), object, []string{"spec.intData", "spec.location", "spec.tags"})).To(Equal(expected))
+		},
+		table.Entry("unchanged numbers", `{"intData": 5}`, map[string]interface{}{"intData": int64(5)}, nil),
+		table.Entry("changed numbers", `{"intData": 5}`, map[string]interface{}{"intData": int64(6)}, []string{"spec.intData"}),
+		table.Entry("unchanged strings", `{"location": "westus"}`, map[string]interface{}{"location": "westus"}, nil),
+		table.Entry("changed strings", `{"location": "westus"}`, map[string]interface{}{"location": "eastus"}, []string{"spec.location"}),
+		table.Entry("added fields", `{}`, map[string]interface{}{"location": "westus"}, []string{"spec.location"}),
+		table.Entry("removed fields", `{"intData": 5}`, map[string]interface{}{}, []string{"spec.intData"}),
+		table.Entry("unchanged nested numbers", `{"tags": {"size": 3}}`, map[string]interface{}{"tags": map[string]interface{}{"size": int64(3)}}, nil),
+		table.Entry("changed nested numbers", `{"tags": {"size": 3}}`, map[string]interface{}{"tags": map[string]interface{}{"size": int64(4)}}, []string{"spec.tags"}),
+	)
+
+	It("only recreates once the change is approved for the generation, if approval is required", func() {
+		instance := &unstructured.Unstructured{}
+		instance.SetGeneration(3)
+		r := &reconcileRunner{
+			GenericController: &GenericController{
+				AnnotationBaseName: "test.operatify.io",
+				Parameters:         ReconcileParameters{ImmutableFieldChange: ImmutableFieldChangeRequireApproval},
+				Log:                ctrl.Log,
+			},
+			objectMeta: instance,
+		}
+		Expect(r.recreateApproved()).To(BeFalse())
+		Expect(r.awaitingRecreateApprovalError([]string{"spec.location"})).To(MatchError(
+			"immutable fields changed: spec.location. Recreating the external resource must be approved by setting the annotation test.operatify.io/approve-recreate to 3"))
+
+		instance.SetAnnotations(map[string]string{"test.operatify.io/approve-recreate": "2"})
+		Expect(r.recreateApproved()).To(BeFalse())
+		instance.SetAnnotations(map[string]string{"test.operatify.io/approve-recreate": "3"})
+		Expect(r.recreateApproved()).To(BeTrue())
+
+		r.Parameters.ImmutableFieldChange = ImmutableFieldChangeRecreate
+		instance.SetAnnotations(nil)
+		Expect(r.recreateApproved()).To(BeTrue())
+	})
+})
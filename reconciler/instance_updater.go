@@ -16,6 +16,7 @@ limitations under the License.
 package reconciler
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
@@ -53,12 +54,67 @@ func (updater *instanceUpdater) setStatusPayload(statusPayload interface{}) {
 
 func (updater *instanceUpdater) setReconcileState(state ReconcileState, message string) {
 	updateFunc := func(s *Status) {
+		if s.State != state {
+			now := metav1.Now()
+			s.LastTransitionTime = &now
+		}
 		s.State = state
 		s.Message = message
 	}
 	updater.statusUpdates = append(updater.statusUpdates, updateFunc)
 }
 
+// appends the transition to the history, keeping only the last maxTransitions.
+// the transition is from the state of the refetched status the update is applied to, and isn't recorded if that is already its To
+func (updater *instanceUpdater) addTransition(transition Transition, maxTransitions int) {
+	updateFunc := func(s *Status) {
+		if s.State == transition.To {
+			return
+		}
+		transition.From = s.State
+		transitions := append(s.Transitions, transition)
+		if len(transitions) > maxTransitions {
+			transitions = transitions[len(transitions)-maxTransitions:]
+		}
+		s.Transitions = transitions
+	}
+	updater.statusUpdates = append(updater.statusUpdates, updateFunc)
+}
+
+// sets the condition of the type, changing its LastTransitionTime only if its status changes
+func (updater *instanceUpdater) setCondition(conditionType string, status corev1.ConditionStatus, reason string, message string) {
+	updateFunc := func(s *Status) {
+		condition := s.GetCondition(conditionType)
+		if condition == nil {
+			s.Conditions = append(s.Conditions, Condition{Type: conditionType})
+			condition = &s.Conditions[len(s.Conditions)-1]
+		}
+		if condition.Status != status || condition.LastTransitionTime == nil {
+			now := metav1.Now()
+			condition.LastTransitionTime = &now
+		}
+		condition.Status = status
+		condition.Reason = reason
+		condition.Message = message
+	}
+	updater.statusUpdates = append(updater.statusUpdates, updateFunc)
+}
+
+func (updater *instanceUpdater) restartTransitionTime() {
+	updateFunc := func(s *Status) {
+		now := metav1.Now()
+		s.LastTransitionTime = &now
+	}
+	updater.statusUpdates = append(updater.statusUpdates, updateFunc)
+}
+
+func (updater *instanceUpdater) setOperation(operation *OperationHandle) {
+	updateFunc := func(s *Status) {
+		s.Operation = operation
+	}
+	updater.statusUpdates = append(updater.statusUpdates, updateFunc)
+}
+
 func (updater *instanceUpdater) setAnnotation(name string, value string) {
 	updateFunc := func(meta metav1.Object) {
 		annotations := meta.GetAnnotations()
@@ -71,6 +127,18 @@ func (updater *instanceUpdater) setAnnotation(name string, value string) {
 	updater.metaUpdates = append(updater.metaUpdates, updateFunc)
 }
 
+func (updater *instanceUpdater) removeAnnotation(names ...string) {
+	updateFunc := func(meta metav1.Object) {
+		annotations := meta.GetAnnotations()
+		for _, name := range names {
+			delete(annotations, name)
+		}
+		meta.SetAnnotations(annotations)
+	}
+	updater.metaUpdates = append(updater.metaUpdates, updateFunc)
+}
+
+// sets the owners as the controllers of the resource. the GroupVersionKind of each owner must be set
 func (updater *instanceUpdater) setOwnerReferences(owners []runtime.Object) {
 	updateFunc := func(s metav1.Object) {
 		references := make([]metav1.OwnerReference, len(owners))
@@ -78,7 +146,7 @@ func (updater *instanceUpdater) setOwnerReferences(owners []runtime.Object) {
 			controller := true
 			meta, _ := apimeta.Accessor(o)
 			references[i] = metav1.OwnerReference{
-				APIVersion: "v1alpha1",
+				APIVersion: o.GetObjectKind().GroupVersionKind().GroupVersion().String(),
 				Kind:       o.GetObjectKind().GroupVersionKind().Kind,
 				Name:       meta.GetName(),
 				UID:        meta.GetUID(),
@@ -0,0 +1,64 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("instanceUpdater", func() {
+
+	// applies the status updates of the updater to the status, as they are applied to the refetched instance
+	applyStatusUpdates := func(updater *instanceUpdater, status *Status) {
+		for _, update := range updater.statusUpdates {
+			update(status)
+		}
+	}
+
+	It("records a transition from the state of the status the update is applied to", func() {
+		updater := &instanceUpdater{}
+		updater.addTransition(Transition{To: Succeeded, Reason: "Verify"}, 10)
+
+		// the state changed after the runner read the status
+		status := &Status{State: Verifying}
+		applyStatusUpdates(updater, status)
+		Expect(status.Transitions).To(HaveLen(1))
+		Expect(status.Transitions[0].From).To(Equal(Verifying))
+		Expect(status.Transitions[0].To).To(Equal(Succeeded))
+	})
+
+	It("doesn't record a transition the status has already made", func() {
+		updater := &instanceUpdater{}
+		updater.addTransition(Transition{To: Succeeded}, 10)
+
+		status := &Status{State: Succeeded, Transitions: []Transition{{From: Verifying, To: Succeeded}}}
+		applyStatusUpdates(updater, status)
+		Expect(status.Transitions).To(Equal([]Transition{{From: Verifying, To: Succeeded}}))
+	})
+
+	It("keeps only the most recent transitions", func() {
+		updater := &instanceUpdater{}
+		updater.addTransition(Transition{To: Verifying}, 2)
+		updater.setReconcileState(Verifying, "")
+		updater.addTransition(Transition{To: Succeeded}, 2)
+		updater.setReconcileState(Succeeded, "")
+
+		status := &Status{State: Creating, Transitions: []Transition{{To: Pending}, {From: Pending, To: Creating}}}
+		applyStatusUpdates(updater, status)
+		Expect(status.Transitions).To(Equal([]Transition{{From: Creating, To: Verifying}, {From: Verifying, To: Succeeded}}))
+	})
+})
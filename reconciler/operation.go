@@ -0,0 +1,91 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import "context"
+
+type OperationType string
+
+const (
+	OperationCreate OperationType = "Create"
+	OperationUpdate OperationType = "Update"
+	OperationDelete OperationType = "Delete"
+)
+
+// OperationHandle identifies a long-running operation on the external resource, typically the
+// operation id returned by a cloud API. It is persisted in the Status until the operation is complete
+type OperationHandle struct {
+	ID string
+	// This is set by the reconciler according to the call that returned the handle
+	Type OperationType
+}
+
+// OperationPoller can optionally be implemented by a ResourceManager that returns OperationHandles.
+// If it is implemented, while an operation is in progress it is polled instead of calling Verify
+// in the Verifying, Recreating and Terminating states
+type OperationPoller interface {
+	PollOperation(context.Context, ResourceSpec, OperationHandle) (OperationResponse, error)
+}
+
+// OperationDeleter can optionally be implemented by a ResourceManager whose delete returns an OperationHandle.
+// If it is implemented, it is called instead of Delete
+type OperationDeleter interface {
+	DeleteWithOperation(context.Context, ResourceSpec) (DeleteResponse, error)
+}
+
+// The Result of polling an operation
+type OperationResult string
+
+const (
+	OperationResultInProgress OperationResult = "InProgress"
+	OperationResultSucceeded  OperationResult = "Succeeded"
+	OperationResultFailed     OperationResult = "Failed"
+)
+
+func (r OperationResult) inProgress() bool { return r == OperationResultInProgress }
+func (r OperationResult) succeeded() bool  { return r == OperationResultSucceeded }
+func (r OperationResult) failed() bool     { return r == OperationResultFailed }
+
+// The Result of polling an operation, along with a message describing its progress or failure, if present
+type OperationResponse struct {
+	Result  OperationResult
+	Message string
+}
+
+var (
+	OperationInProgress = OperationResponse{Result: OperationResultInProgress}
+	OperationSucceeded  = OperationResponse{Result: OperationResultSucceeded}
+)
+
+func OperationFailedWithMessage(message string) OperationResponse {
+	return OperationResponse{
+		Result:  OperationResultFailed,
+		Message: message,
+	}
+}
+
+// The Result of a delete operation, along with the handle of the operation, if present
+type DeleteResponse struct {
+	Result    DeleteResult
+	Operation *OperationHandle
+}
+
+func (h *OperationHandle) withType(t OperationType) *OperationHandle {
+	if h == nil {
+		return nil
+	}
+	return &OperationHandle{ID: h.ID, Type: t}
+}
@@ -0,0 +1,117 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+// a ResourceManager with long-running operations, whose polls return the response it is given
+type pollingResourceManager struct {
+	stubResourceManager
+	deleteResponse DeleteResponse
+	poll           OperationResponse
+	pollErr        error
+}
+
+func (m *pollingResourceManager) PollOperation(context.Context, ResourceSpec, OperationHandle) (OperationResponse, error) {
+	return m.poll, m.pollErr
+}
+
+func (m *pollingResourceManager) DeleteWithOperation(context.Context, ResourceSpec) (DeleteResponse, error) {
+	return m.deleteResponse, m.err
+}
+
+var _ = Describe("Long-running operations", func() {
+
+	operation := &OperationHandle{ID: "op-1"}
+
+	It("records the operation returned by a create", func() {
+		resourceManager := &pollingResourceManager{stubResourceManager: stubResourceManager{
+			applyResponse: ApplyResponse{Result: ApplyResultAwaitingVerification, Operation: operation},
+		}}
+		r := stubRunner(resourceManager, Creating)
+		Expect(r.applyExecute(context.Background())).To(Equal(Verifying))
+		applyUpdates(r)
+		Expect(r.status.Operation).To(Equal(&OperationHandle{ID: "op-1", Type: OperationCreate}))
+	})
+
+	It("doesn't record an operation the ResourceManager can't poll", func() {
+		resourceManager := &stubResourceManager{applyResponse: ApplyResponse{Result: ApplyResultAwaitingVerification, Operation: operation}}
+		r := stubRunner(resourceManager, Creating)
+		Expect(r.applyExecute(context.Background())).To(Equal(Verifying))
+		applyUpdates(r)
+		Expect(r.status.Operation).To(BeNil())
+	})
+
+	It("clears an operation the ResourceManager can't poll", func() {
+		r := stubRunner(&stubResourceManager{}, Verifying)
+		r.status.Operation = &OperationHandle{ID: "op-1", Type: OperationCreate}
+		Expect(r.operationPoller()).To(BeNil())
+		applyUpdates(r)
+		Expect(r.status.Operation).To(BeNil())
+	})
+
+	table.DescribeTable("polls the operation in progress",
+		func(poll OperationResponse, pollErr error, nextState ReconcileState, complete bool, cleared bool) {
+			resourceManager := &pollingResourceManager{poll: poll, pollErr: pollErr}
+			r := stubRunner(resourceManager, Verifying)
+			r.status.Operation = &OperationHandle{ID: "op-1", Type: OperationCreate}
+
+			poller := r.operationPoller()
+			Expect(poller).NotTo(BeNil())
+			state, done, _ := r.pollOperation(context.Background(), poller)
+			Expect(state).To(Equal(nextState))
+			Expect(done).To(Equal(complete))
+			applyUpdates(r)
+			if cleared {
+				Expect(r.status.Operation).To(BeNil())
+			} else {
+				Expect(r.status.Operation).NotTo(BeNil())
+			}
+		},
+		table.Entry("in progress", OperationInProgress, nil, Verifying, false, false),
+		table.Entry("succeeded", OperationSucceeded, nil, Verifying, true, true),
+		table.Entry("failed", OperationFailedWithMessage("quota exceeded"), nil, Failed, false, true),
+		table.Entry("transient error", OperationResponse{}, errors.New("connection reset"), Verifying, false, false),
+	)
+
+	It("keeps polling after a transient error, showing it in the status message", func() {
+		resourceManager := &pollingResourceManager{pollErr: errors.New("connection reset")}
+		r := stubRunner(resourceManager, Verifying)
+		r.status.Operation = &OperationHandle{ID: "op-1", Type: OperationCreate}
+		_, _, err := r.pollOperation(context.Background(), resourceManager)
+		Expect(err).NotTo(HaveOccurred())
+		applyUpdates(r)
+		Expect(r.status.Message).To(ContainSubstring("connection reset"))
+	})
+
+	It("deletes with DeleteWithOperation if the ResourceManager implements OperationDeleter", func() {
+		resourceManager := &pollingResourceManager{
+			stubResourceManager: stubResourceManager{deleteResult: DeleteError},
+			deleteResponse:      DeleteResponse{Result: DeleteAwaitingVerification, Operation: operation},
+		}
+		r := stubRunner(resourceManager, Verifying)
+		response, err := r.deleteExternal(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response).To(Equal(resourceManager.deleteResponse))
+	})
+
+	table.DescribeTable("polls the delete operation in the finalizer",
+		func(poll OperationResponse, pollErr error, removeFinalizer bool, requeue bool) {
+			resourceManager := &pollingResourceManager{poll: poll, pollErr: pollErr}
+			r := &reconcileFinalizer{reconcileRunner: *stubRunner(resourceManager, Terminating)}
+			r.status.Operation = &OperationHandle{ID: "op-1", Type: OperationDelete}
+			remove, again := r.pollDeleteOperation(context.Background(), resourceManager)
+			Expect(remove).To(Equal(removeFinalizer))
+			Expect(again).To(Equal(requeue))
+		},
+		table.Entry("in progress", OperationInProgress, nil, false, true),
+		table.Entry("succeeded", OperationSucceeded, nil, true, false),
+		table.Entry("failed", OperationFailedWithMessage("locked"), nil, true, false),
+		table.Entry("transient error", OperationResponse{}, errors.New("connection reset"), false, true),
+	)
+})
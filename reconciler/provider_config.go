@@ -0,0 +1,234 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// ProviderConfig is the account an external resource is managed in, such as the endpoint and credentials of an API.
+// It is resolved from the provider config referenced by the spec.providerConfigRef of the resource, which is one of the
+// ProviderConfigKinds of the controller, with a spec.endpoint and a spec.credentialsSecretRef naming a Secret
+type ProviderConfig struct {
+	Kind string
+	// The namespace is empty for a cluster-scoped provider config
+	types.NamespacedName
+	Endpoint string
+	// The spec of the provider config, for settings such as a region
+	Spec map[string]interface{}
+	// The data of the Secret referenced by the provider config, if any
+	Credentials map[string][]byte
+	// changes whenever the provider config or its Secret changes
+	version string
+}
+
+// the ResourceManagers created for each provider config, and the circuit breakers guarding calls with each provider config
+type providerResourceManagers struct {
+	lock            sync.Mutex
+	managers        map[string]providerResourceManager
+	circuitBreakers map[string]*CircuitBreaker
+}
+
+type providerResourceManager struct {
+	version         string
+	resourceManager ResourceManager
+}
+
+// resolves the provider config referenced by the resource, and the ResourceManager to use for it.
+// returns whether the reconcile is done with, because the provider config can't be resolved
+func (r *reconcileRunner) useProviderConfig(ctx context.Context) (bool, ctrl.Result, error) {
+	providerConfig, err := r.resolveProviderConfig(ctx)
+	if err == nil && providerConfig != nil && r.ProviderResourceManagerCreator != nil {
+		r.ResourceManager, err = r.providerResourceManager(providerConfig)
+	}
+	if err == nil && providerConfig != nil {
+		r.CircuitBreaker = r.providerCircuitBreaker(providerConfig)
+	}
+	if err != nil {
+		r.log.Info(fmt.Sprintf("Unable to resolve provider config: %v", err))
+		if !r.objectMeta.GetDeletionTimestamp().IsZero() {
+			r.Recorder.Event(r.instance, corev1.EventTypeWarning, "ProviderConfig", err.Error())
+			return true, ctrl.Result{Requeue: true, RequeueAfter: r.getRequeueAfter(Pending)}, nil
+		}
+		result, err := r.applyTransition(ctx, "ProviderConfig", Pending, err)
+		return true, result, err
+	}
+	r.providerConfig = providerConfig
+	return false, ctrl.Result{}, nil
+}
+
+// returns the provider config referenced by the resource, or nil if it doesn't reference one
+func (r *reconcileRunner) resolveProviderConfig(ctx context.Context) (*ProviderConfig, error) {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(r.instance)
+	if err != nil {
+		return nil, err
+	}
+	name, _, _ := unstructured.NestedString(object, "spec", "providerConfigRef", "name")
+	if name == "" {
+		return nil, nil
+	}
+	group, _, _ := unstructured.NestedString(object, "spec", "providerConfigRef", "apiGroup")
+	kind, _, _ := unstructured.NestedString(object, "spec", "providerConfigRef", "kind")
+	prototype, err := r.providerConfigKind(group, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	// a namespaced provider config is in the namespace of the resource, and a cluster-scoped one is read without a namespace
+	namespace := r.Namespace
+	clusterScoped, err := r.isClusterScoped(prototype)
+	if err != nil {
+		return nil, err
+	}
+	if clusterScoped {
+		namespace = ""
+	}
+	config := prototype.DeepCopyObject()
+	if err := r.KubeClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, config); err != nil {
+		return nil, err
+	}
+	meta, err := apimeta.Accessor(config)
+	if err != nil {
+		return nil, err
+	}
+	configObject, err := runtime.DefaultUnstructuredConverter.ToUnstructured(config)
+	if err != nil {
+		return nil, err
+	}
+	spec, _, _ := unstructured.NestedMap(configObject, "spec")
+	endpoint, _, _ := unstructured.NestedString(spec, "endpoint")
+	providerConfig := &ProviderConfig{
+		Kind:           kind,
+		NamespacedName: types.NamespacedName{Namespace: meta.GetNamespace(), Name: meta.GetName()},
+		Endpoint:       endpoint,
+		Spec:           spec,
+		version:        meta.GetResourceVersion(),
+	}
+
+	secretName, _, _ := unstructured.NestedString(spec, "credentialsSecretRef", "name")
+	if secretName == "" {
+		return providerConfig, nil
+	}
+	// a namespaced provider config can only use a Secret in its own namespace, so it can't send the credentials of another namespace
+	// to its endpoint. Only a cluster-scoped provider config, which only cluster administrators can create, names the namespace
+	secretNamespace, _, _ := unstructured.NestedString(spec, "credentialsSecretRef", "namespace")
+	if !clusterScoped {
+		if secretNamespace != "" && secretNamespace != meta.GetNamespace() {
+			return nil, fmt.Errorf("the credentials Secret of %s %s must be in its namespace %s, not %s", kind, name, meta.GetNamespace(), secretNamespace)
+		}
+		secretNamespace = meta.GetNamespace()
+	}
+	if secretNamespace == "" {
+		return nil, fmt.Errorf("no namespace given for the credentials Secret of %s %s", kind, name)
+	}
+	// Secrets are read from the API server, as caching them would need a cluster-wide watch of every Secret
+	var reader client.Reader = r.KubeClient
+	if r.APIReader != nil {
+		reader = r.APIReader
+	}
+	secret := &corev1.Secret{}
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: secretNamespace, Name: secretName}, secret); err != nil {
+		return nil, err
+	}
+	providerConfig.Credentials = secret.Data
+	providerConfig.version += "/" + secret.ResourceVersion
+	return providerConfig, nil
+}
+
+// returns the prototype of the ProviderConfigKinds with the kind, and the group if one is given
+func (gc *GenericController) providerConfigKind(group string, kind string) (runtime.Object, error) {
+	for _, prototype := range gc.ProviderConfigKinds {
+		gvk, err := apiutil.GVKForObject(prototype, gc.Scheme)
+		if err != nil {
+			return nil, err
+		}
+		if gvk.Kind == kind && (group == "" || gvk.Group == group) {
+			return prototype, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a provider config kind of %s", kind, gc.ResourceKind)
+}
+
+// returns whether the provider config kind is cluster-scoped, according to the RESTMapper.
+// Without a RESTMapper every provider config kind is taken to be namespaced
+func (gc *GenericController) isClusterScoped(prototype runtime.Object) (bool, error) {
+	if gc.RESTMapper == nil {
+		return false, nil
+	}
+	gvk, err := apiutil.GVKForObject(prototype, gc.Scheme)
+	if err != nil {
+		return false, err
+	}
+	mapping, err := gc.RESTMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, err
+	}
+	return mapping.Scope.Name() == apimeta.RESTScopeNameRoot, nil
+}
+
+// returns the circuit breaker guarding the calls made for the resources referencing the provider config,
+// so the errors of one account don't pause the calls made for the others. Returns nil if the circuit breaker is disabled
+func (gc *GenericController) providerCircuitBreaker(providerConfig *ProviderConfig) *CircuitBreaker {
+	if gc.CircuitBreaker == nil {
+		return nil
+	}
+	gc.providerResourceManagers.lock.Lock()
+	defer gc.providerResourceManagers.lock.Unlock()
+	key := providerConfig.key()
+	if circuitBreaker, ok := gc.providerResourceManagers.circuitBreakers[key]; ok {
+		return circuitBreaker
+	}
+	circuitBreaker := gc.Parameters.circuitBreaker()
+	if gc.providerResourceManagers.circuitBreakers == nil {
+		gc.providerResourceManagers.circuitBreakers = map[string]*CircuitBreaker{}
+	}
+	gc.providerResourceManagers.circuitBreakers[key] = circuitBreaker
+	return circuitBreaker
+}
+
+func (providerConfig *ProviderConfig) key() string {
+	return providerConfig.Kind + "/" + providerConfig.NamespacedName.String()
+}
+
+// returns the ResourceManager created for the provider config, creating it again if the provider config or its Secret changed
+func (gc *GenericController) providerResourceManager(providerConfig *ProviderConfig) (ResourceManager, error) {
+	gc.providerResourceManagers.lock.Lock()
+	defer gc.providerResourceManagers.lock.Unlock()
+	key := providerConfig.key()
+	if existing, ok := gc.providerResourceManagers.managers[key]; ok && existing.version == providerConfig.version {
+		return existing.resourceManager, nil
+	}
+	resourceManager, err := gc.ProviderResourceManagerCreator(providerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create ResourceManager for %s %s: %v", providerConfig.Kind, providerConfig.NamespacedName, err)
+	}
+	if gc.providerResourceManagers.managers == nil {
+		gc.providerResourceManagers.managers = map[string]providerResourceManager{}
+	}
+	gc.providerResourceManagers.managers[key] = providerResourceManager{version: providerConfig.version, resourceManager: resourceManager}
+	return resourceManager, nil
+}
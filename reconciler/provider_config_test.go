@@ -0,0 +1,138 @@
+package reconciler
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Provider configs", func() {
+
+	clusterKind := schema.GroupVersionKind{Group: "test.example.com", Version: "v1", Kind: "ClusterProviderConfig"}
+	namespacedKind := schema.GroupVersionKind{Group: "test.example.com", Version: "v1", Kind: "ProviderConfig"}
+
+	prototype := func(gvk schema.GroupVersionKind) runtime.Object {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(gvk)
+		return u
+	}
+
+	providerConfig := func(gvk schema.GroupVersionKind, namespace string, name string, endpoint string) runtime.Object {
+		u := prototype(gvk).(*unstructured.Unstructured)
+		u.SetNamespace(namespace)
+		u.SetName(name)
+		u.Object["spec"] = map[string]interface{}{
+			"endpoint":             endpoint,
+			"credentialsSecretRef": map[string]interface{}{"name": name, "namespace": "default"},
+		}
+		return u
+	}
+
+	secret := func(name string) runtime.Object {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Data:       map[string][]byte{"token": []byte(name)},
+		}
+	}
+
+	// a runner for a resource in the default namespace referencing the provider config
+	runner := func(gc *GenericController, kind string, name string) *reconcileRunner {
+		instance := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{"providerConfigRef": map[string]interface{}{"kind": kind, "name": name}},
+		}}
+		return &reconcileRunner{
+			GenericController: gc,
+			NamespacedName:    types.NamespacedName{Namespace: "default", Name: "resource"},
+			instance:          instance,
+		}
+	}
+
+	var gc *GenericController
+
+	BeforeEach(func() {
+		mapper := apimeta.NewDefaultRESTMapper(nil)
+		mapper.Add(clusterKind, apimeta.RESTScopeRoot)
+		mapper.Add(namespacedKind, apimeta.RESTScopeNamespace)
+		gc = &GenericController{
+			ResourceKind:        "Resource",
+			Scheme:              scheme.Scheme,
+			RESTMapper:          mapper,
+			ProviderConfigKinds: []runtime.Object{prototype(namespacedKind), prototype(clusterKind)},
+			Parameters:          ReconcileParameters{CircuitBreakerThreshold: 1},
+		}
+		gc.CircuitBreaker = gc.Parameters.circuitBreaker()
+	})
+
+	It("reads a cluster-scoped provider config without the namespace of the resource", func() {
+		gc.KubeClient = fake.NewFakeClientWithScheme(scheme.Scheme, providerConfig(clusterKind, "", "cluster", "https://cluster.example.com"))
+		gc.APIReader = fake.NewFakeClientWithScheme(scheme.Scheme, secret("cluster"))
+
+		resolved, err := runner(gc, "ClusterProviderConfig", "cluster").resolveProviderConfig(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resolved.NamespacedName).To(Equal(types.NamespacedName{Name: "cluster"}))
+		Expect(resolved.Endpoint).To(Equal("https://cluster.example.com"))
+		Expect(resolved.Credentials).To(HaveKeyWithValue("token", []byte("cluster")))
+	})
+
+	It("reads a namespaced provider config in the namespace of the resource", func() {
+		gc.KubeClient = fake.NewFakeClientWithScheme(scheme.Scheme,
+			providerConfig(namespacedKind, "default", "account", "https://default.example.com"),
+			providerConfig(namespacedKind, "other", "account", "https://other.example.com"))
+		gc.APIReader = fake.NewFakeClientWithScheme(scheme.Scheme, secret("account"))
+
+		resolved, err := runner(gc, "ProviderConfig", "account").resolveProviderConfig(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resolved.Endpoint).To(Equal("https://default.example.com"))
+	})
+
+	It("rejects the credentials Secret of a namespaced provider config in another namespace", func() {
+		config := providerConfig(namespacedKind, "tenant", "account", "https://attacker.example.com").(*unstructured.Unstructured)
+		gc.KubeClient = fake.NewFakeClientWithScheme(scheme.Scheme, config)
+		gc.APIReader = fake.NewFakeClientWithScheme(scheme.Scheme, secret("account"))
+		r := runner(gc, "ProviderConfig", "account")
+		r.NamespacedName.Namespace = "tenant"
+
+		_, err := r.resolveProviderConfig(context.Background())
+		Expect(err).To(MatchError(ContainSubstring("must be in its namespace tenant")))
+
+		// without a namespace, the Secret is read from the namespace of the provider config
+		Expect(unstructured.SetNestedField(config.Object, "", "spec", "credentialsSecretRef", "namespace")).To(Succeed())
+		gc.KubeClient = fake.NewFakeClientWithScheme(scheme.Scheme, config)
+		_, err = r.resolveProviderConfig(context.Background())
+		Expect(err).To(MatchError(ContainSubstring("not found")))
+	})
+
+	It("reads the credentials Secret with the APIReader rather than the cache", func() {
+		gc.KubeClient = fake.NewFakeClientWithScheme(scheme.Scheme, providerConfig(clusterKind, "", "cluster", "https://cluster.example.com"), secret("cluster"))
+		gc.APIReader = fake.NewFakeClientWithScheme(scheme.Scheme)
+
+		_, err := runner(gc, "ClusterProviderConfig", "cluster").resolveProviderConfig(context.Background())
+		Expect(err).To(MatchError(ContainSubstring("not found")))
+	})
+
+	It("has a circuit breaker for each provider config", func() {
+		first := &ProviderConfig{Kind: "ClusterProviderConfig", NamespacedName: types.NamespacedName{Name: "first"}}
+		second := &ProviderConfig{Kind: "ClusterProviderConfig", NamespacedName: types.NamespacedName{Name: "second"}}
+
+		breaker := gc.providerCircuitBreaker(first)
+		Expect(breaker).NotTo(BeIdenticalTo(gc.CircuitBreaker))
+		Expect(gc.providerCircuitBreaker(first)).To(BeIdenticalTo(breaker))
+		Expect(breaker.acquire()).To(BeTrue())
+		breaker.record(context.DeadlineExceeded)
+		Expect(breaker.State()).To(Equal(CircuitOpen))
+
+		Expect(gc.providerCircuitBreaker(second).State()).To(Equal(CircuitClosed))
+		Expect(gc.CircuitBreaker.State()).To(Equal(CircuitClosed))
+		Expect(gc.openProviderCircuits()).To(Equal([]string{"ClusterProviderConfig//first"}))
+	})
+})
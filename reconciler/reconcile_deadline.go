@@ -0,0 +1,133 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Annotations to override the state deadlines in ReconcileParameters for a single resource.
+// Values are durations such as "90s" or "1h"
+const (
+	VerifyingTimeoutAnnotation      = "/verifying-timeout"
+	RecreatingTimeoutAnnotation     = "/recreating-timeout"
+	TerminatingTimeoutAnnotation    = "/terminating-timeout"
+	TerminationEscalationAnnotation = "/termination-escalation"
+)
+
+// What the finalizer does once the Terminating deadline has passed
+type TerminationEscalation string
+
+const (
+	// keep waiting for the external resource to be deleted, flagging the timeout in the status message and an event
+	TerminationEscalationWait TerminationEscalation = "Wait"
+	// call Delete again and restart the deadline, or remove the finalizer if delete permission is turned off
+	TerminationEscalationRetryDelete TerminationEscalation = "RetryDelete"
+	// remove the finalizer without confirming that the external resource has been deleted
+	TerminationEscalationRemoveFinalizer TerminationEscalation = "RemoveFinalizer"
+)
+
+// returns the maximum time the resource may stay in the state, or 0 if there is no limit
+func (r *reconcileRunner) getStateTimeout(state ReconcileState) time.Duration {
+	var annotation string
+	var millis int
+	switch state {
+	case Verifying:
+		annotation, millis = VerifyingTimeoutAnnotation, r.Parameters.VerifyingTimeout
+	case Recreating:
+		annotation, millis = RecreatingTimeoutAnnotation, r.Parameters.RecreatingTimeout
+	case Terminating:
+		annotation, millis = TerminatingTimeoutAnnotation, r.Parameters.TerminatingTimeout
+	default:
+		return 0
+	}
+	if value := r.getAnnotation(annotation); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err == nil {
+			return timeout
+		}
+		r.log.Info(fmt.Sprintf("Invalid duration '%s' in annotation %s, using default", value, annotation))
+	}
+	return time.Duration(millis) * time.Millisecond
+}
+
+// returns whether the resource has been in its current state for longer than the state's deadline
+func (r *reconcileRunner) stateTimedOut() (bool, time.Duration) {
+	timeout := r.getStateTimeout(r.status.State)
+	if timeout == 0 || r.status.LastTransitionTime == nil {
+		return false, timeout
+	}
+	return time.Since(r.status.LastTransitionTime.Time) > timeout, timeout
+}
+
+func (r *reconcileRunner) getTerminationEscalation() TerminationEscalation {
+	escalation := TerminationEscalation(r.getAnnotation(TerminationEscalationAnnotation))
+	if escalation == "" {
+		escalation = r.Parameters.TerminationEscalation
+	}
+	if escalation == "" {
+		escalation = TerminationEscalationWait
+	}
+	return escalation
+}
+
+// applies the configured escalation if the Terminating deadline has passed.
+// returns whether the escalation replaces the usual finalizer step, and if so whether the finalizer can be removed
+func (r *reconcileFinalizer) escalateTermination(ctx context.Context) (bool, bool) {
+	if !r.status.IsTerminating() {
+		return false, false
+	}
+	timedOut, timeout := r.stateTimedOut()
+	if !timedOut {
+		return false, false
+	}
+
+	escalation := r.getTerminationEscalation()
+	message := fmt.Sprintf("%s %s not deleted after %s.", r.ResourceKind, r.Name, timeout)
+	switch escalation {
+	case TerminationEscalationRemoveFinalizer:
+		r.log.Info("Termination timed out, removing finalizer without confirming deletion of external resource")
+		r.Recorder.Event(r.instance, corev1.EventTypeWarning, "Timeout", message+" Removing finalizer.")
+		return true, true
+	case TerminationEscalationRetryDelete:
+		if !r.getAccessPermissions().delete() {
+			// as when first deleting, the external resource is only deleted if delete permission is turned on
+			r.log.Info("Termination timed out, but resource is not managed by operator, bypassing delete of external resource")
+			r.Recorder.Event(r.instance, corev1.EventTypeWarning, "Timeout", message+" Removing finalizer.")
+			return true, true
+		}
+		r.log.Info("Termination timed out, deleting resource externally again")
+		r.Recorder.Event(r.instance, corev1.EventTypeWarning, "Timeout", message+" Retrying delete.")
+		r.instanceUpdater.restartTransitionTime()
+		deleteResponse, err := r.deleteExternal(ctx)
+		if err != nil || deleteResponse.Result.error() {
+			r.log.Info("An error occurred retrying delete of managed object in finalizer. Requeuing.")
+			return true, false
+		}
+		r.trackOperation(deleteResponse.Operation, OperationDelete)
+		return true, deleteResponse.Result.alreadyDeleted() || deleteResponse.Result.succeeded()
+	default:
+		if r.status.Message != message {
+			r.instanceUpdater.setReconcileState(Terminating, message)
+			r.Recorder.Event(r.instance, corev1.EventTypeWarning, "Timeout", message+" Still waiting.")
+		}
+		return false, false
+	}
+}
@@ -45,49 +45,71 @@ func (r *reconcileFinalizer) handle() (ctrl.Result, error) {
 	isTerminating := r.status.IsTerminating()
 
 	if r.isDefined() {
-		// Even before we cal ResourceManager.Delete, we verify the state of the resource
-		// If it has not been created, we don't need to delete anything.
-		verifyResponse, err := r.ResourceManager.Verify(ctx, r.resourceSpec())
-		verifyResult := verifyResponse.Result
-
-		if verifyResult.missing() {
-			removeFinalizer = true
-		} else if verifyResult.deleting() {
-			requeue = true
-		} else if !isTerminating { // and one of verifyResult.ready() || verifyResult.recreateRequired() || verifyResult.updateRequired() || verifyResult.error()
-			if verifyResult.error() || err != nil {
-				r.log.Info("An error occurred verifying state of managed object in finalizer. Cannot confirm that managed object can be deleted. Continuing deletion of kubernetes object anyway.")
-				// TODO: maybe should rather retry a certain number of times before failing
+		if paused, result, err := r.pauseIfBackendUnavailable(ctx); paused {
+			return result, err
+		}
+		if escalated, remove := r.escalateTermination(ctx); escalated {
+			removeFinalizer, requeue = remove, !remove
+		} else if poller := r.operationPoller(); isTerminating && poller != nil && r.status.Operation.Type == OperationDelete {
+			removeFinalizer, requeue = r.pollDeleteOperation(ctx, poller)
+		} else {
+			// Even before we cal ResourceManager.Delete, we verify the state of the resource
+			// If it has not been created, we don't need to delete anything.
+			var verifyResponse VerifyResponse
+			err := r.callBackend(func() (err error) {
+				verifyResponse, err = r.ResourceManager.Verify(ctx, r.resourceSpec())
+				return err
+			})
+			if err == errBackendUnavailable {
+				return r.pauseForBackend(ctx)
 			}
-			permissions := r.getAccessPermissions()
-			if !permissions.delete() {
-				// if delete permission is turned off, just finalize, but don't delete external resource
-				r.log.Info("Resource is not managed by operator, bypassing delete of external resource")
+			verifyResult := verifyResponse.Result
+
+			if verifyResult.missing() {
 				removeFinalizer = true
-			} else {
-				// This block of code should only ever get called once.
-				r.log.Info("Deleting resource externally")
-				deleteResult, err := r.ResourceManager.Delete(ctx, r.resourceSpec())
-				if err != nil || deleteResult.error() {
-					r.log.Info("An error occurred attempting to delete managed object in finalizer. Cannot confirm that managed object has been deleted. Continuing deletion of kubernetes object anyway.")
-					removeFinalizer = true
-				} else if deleteResult.alreadyDeleted() || deleteResult.succeeded() {
+			} else if verifyResult.deleting() {
+				requeue = true
+			} else if !isTerminating { // and one of verifyResult.ready() || verifyResult.recreateRequired() || verifyResult.updateRequired() || verifyResult.error()
+				if verifyResult.error() || err != nil {
+					r.log.Info("An error occurred verifying state of managed object in finalizer. Cannot confirm that managed object can be deleted. Continuing deletion of kubernetes object anyway.")
+					// TODO: maybe should rather retry a certain number of times before failing
+				}
+				permissions := r.getAccessPermissions()
+				if !permissions.delete() {
+					// if delete permission is turned off, just finalize, but don't delete external resource
+					r.log.Info("Resource is not managed by operator, bypassing delete of external resource")
 					removeFinalizer = true
-				} else if deleteResult.awaitingVerification() {
-					requeue = true
 				} else {
-					// assert no more cases
-					removeFinalizer = true
+					// This block of code should only ever get called once.
+					r.log.Info("Deleting resource externally")
+					deleteResponse, err := r.deleteExternal(ctx)
+					deleteResult := deleteResponse.Result
+					if err == errBackendUnavailable {
+						// the delete is retried once calls are allowed again
+						requeue = true
+					} else if err != nil || deleteResult.error() {
+						r.log.Info("An error occurred attempting to delete managed object in finalizer. Cannot confirm that managed object has been deleted. Continuing deletion of kubernetes object anyway.")
+						removeFinalizer = true
+					} else if deleteResult.alreadyDeleted() || deleteResult.succeeded() {
+						removeFinalizer = true
+					} else if deleteResult.awaitingVerification() {
+						r.trackOperation(deleteResponse.Operation, OperationDelete)
+						requeue = true
+					} else {
+						// assert no more cases
+						removeFinalizer = true
+					}
 				}
+			} else {
+				// this should never be called, as the first time r.ResourceManager.Delete is called isTerminating should be false
+				// this implies that r.ResourceManager.Delete didn't throw an error, but didn't do anything either
+				removeFinalizer = true
 			}
-		} else {
-			// this should never be called, as the first time r.ResourceManager.Delete is called isTerminating should be false
-			// this implies that r.ResourceManager.Delete didn't throw an error, but didn't do anything either
-			removeFinalizer = true
 		}
 	}
 
 	if !isTerminating {
+		r.recordTransition("Finalizer", Terminating, "Setting state to terminating for "+r.Name)
 		updater.setReconcileState(Terminating, "")
 	}
 	if removeFinalizer {
@@ -95,7 +117,7 @@ func (r *reconcileFinalizer) handle() (ctrl.Result, error) {
 	}
 
 	requeueAfter := r.getRequeueAfter(Terminating)
-	if removeFinalizer || !isTerminating {
+	if updater.hasUpdates() {
 		if err := r.updateInstance(ctx); err != nil {
 			// if we can't update we have to requeue and hopefully it will remove the finalizer next time
 			return ctrl.Result{Requeue: true, RequeueAfter: requeueAfter}, fmt.Errorf("Error removing finalizer: %v", err)
@@ -115,3 +137,16 @@ func (r *reconcileFinalizer) handle() (ctrl.Result, error) {
 		return ctrl.Result{}, nil
 	}
 }
+
+// polls the delete operation in progress, returning whether the finalizer can be removed, or the reconcile requeued
+func (r *reconcileFinalizer) pollDeleteOperation(ctx context.Context, poller OperationPoller) (bool, bool) {
+	nextState, complete, err := r.pollOperation(ctx, poller)
+	if complete {
+		return true, false
+	}
+	if nextState == Failed {
+		r.log.Info(fmt.Sprintf("Delete operation failed in finalizer: %v. Continuing deletion of kubernetes object anyway.", err))
+		return true, false
+	}
+	return false, true
+}
@@ -18,13 +18,17 @@ package reconciler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 
 	"github.com/go-logr/logr"
 
@@ -34,6 +38,7 @@ import (
 )
 
 const LastAppliedAnnotation = "/last-applied-spec"
+const OperationTokenAnnotation = "/operation-token"
 
 // Contains all the state involved in running a single reconcile event in the reconcile loo[
 type reconcileRunner struct {
@@ -49,6 +54,11 @@ type reconcileRunner struct {
 	instanceUpdater *instanceUpdater
 	owner           runtime.Object
 	dependencies    map[types.NamespacedName]runtime.Object
+	providerConfig  *ProviderConfig
+	// the ResourceManager created for the provider config of the resource, or else the ResourceManager of the controller
+	ResourceManager ResourceManager
+	// the circuit breaker of the provider config of the resource, or else the circuit breaker of the controller
+	CircuitBreaker *CircuitBreaker
 }
 
 type reconcileFinalizer struct {
@@ -143,6 +153,15 @@ func (r *reconcileRunner) run(ctx context.Context) (ctrl.Result, error) {
 
 func (r *reconcileRunner) setOwner(ctx context.Context, owner runtime.Object) (ctrl.Result, error) {
 	//set owner reference if it exists
+	// typed objects read through the client may not have their GroupVersionKind set, so it is found from the scheme
+	if owner.GetObjectKind().GroupVersionKind().Empty() && r.Scheme != nil {
+		gvk, err := apiutil.GVKForObject(owner, r.Scheme)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		owner = owner.DeepCopyObject()
+		owner.GetObjectKind().SetGroupVersionKind(gvk)
+	}
 	r.instanceUpdater.setOwnerReferences([]runtime.Object{owner})
 	if err := r.updateAndLog(ctx, corev1.EventTypeNormal, "OwnerReferences", "setting OwnerReferences for "+r.Name); err != nil {
 		return ctrl.Result{}, err
@@ -151,7 +170,26 @@ func (r *reconcileRunner) setOwner(ctx context.Context, owner runtime.Object) (c
 }
 
 func (r *reconcileRunner) verify(ctx context.Context) (ctrl.Result, error) {
+	if paused, result, err := r.pauseIfBackendUnavailable(ctx); paused {
+		return result, err
+	}
+	if timedOut, timeout := r.stateTimedOut(); timedOut {
+		r.instanceUpdater.setOperation(nil)
+		return r.applyTransition(ctx, "Timeout", Failed, fmt.Errorf("%s %s timed out after %s in state %s", r.ResourceKind, r.Name, timeout, r.status.State))
+	}
+	// while a long-running operation is in progress, poll it rather than verifying the resource
+	if poller := r.operationPoller(); poller != nil && (r.status.IsVerifying() || r.status.IsRecreating()) {
+		if nextState, complete, err := r.pollOperation(ctx, poller); !complete {
+			if err == errBackendUnavailable {
+				return r.pauseForBackend(ctx)
+			}
+			return r.applyTransition(ctx, "Operation", nextState, err)
+		}
+	}
 	nextState, ensureErr := r.verifyExecute(ctx)
+	if ensureErr == errBackendUnavailable {
+		return r.pauseForBackend(ctx)
+	}
 	return r.applyTransition(ctx, "Verify", nextState, ensureErr)
 }
 
@@ -164,7 +202,11 @@ func (r *reconcileRunner) verifyExecute(ctx context.Context) (ReconcileState, er
 	currentState := status.State
 
 	r.log.Info("Verifying state of external resource")
-	verifyResponse, err := r.ResourceManager.Verify(ctx, r.resourceSpec())
+	var verifyResponse VerifyResponse
+	err := r.callBackend(func() (err error) {
+		verifyResponse, err = r.ResourceManager.Verify(ctx, r.resourceSpec())
+		return err
+	})
 	verifyResult := verifyResponse.Result
 	permissions := r.getAccessPermissions()
 
@@ -183,6 +225,21 @@ func (r *reconcileRunner) verifyExecute(ctx context.Context) (ReconcileState, er
 		return currentState, nil
 	}
 
+	// **** Immutable fields changed
+	// the spec can't be applied to the external resource, so it is either recreated or the change is rejected
+	if verifyResult.ready() || verifyResult.updateRequired() {
+		if changed := r.changedImmutableFields(); len(changed) > 0 {
+			if r.rejectImmutableFieldChange() {
+				return Failed, immutableFieldsError(changed)
+			}
+			if !r.recreateApproved() {
+				return Failed, r.awaitingRecreateApprovalError(changed)
+			}
+			r.log.Info(fmt.Sprintf("Immutable fields changed, recreating external resource: %s", strings.Join(changed, ", ")))
+			verifyResult = VerifyResultRecreateRequired
+		}
+	}
+
 	// **** Ready
 	// The resource is finished creating or updating, completion step can take place if necessary
 	if verifyResult.ready() {
@@ -229,13 +286,15 @@ func (r *reconcileRunner) verifyExecute(ctx context.Context) (ReconcileState, er
 			// fail if permission to delete is not present
 			return Failed, fmt.Errorf(rejectDeleteManagedResource)
 		}
-		deleteResult, err := r.ResourceManager.Delete(ctx, r.resourceSpec())
+		deleteResponse, err := r.deleteExternal(ctx)
+		deleteResult := deleteResponse.Result
 		if err != nil || deleteResult == DeleteError {
 			return Failed, err
 		}
 
 		// set it back to pending and let it go through the whole process again
 		if deleteResult.awaitingVerification() {
+			r.trackOperation(deleteResponse.Operation, OperationDelete)
 			return Recreating, err
 		}
 
@@ -251,8 +310,19 @@ func (r *reconcileRunner) verifyExecute(ctx context.Context) (ReconcileState, er
 }
 
 func (r *reconcileRunner) apply(ctx context.Context) (ctrl.Result, error) {
+	if paused, result, err := r.pauseIfBackendUnavailable(ctx); paused {
+		return result, err
+	}
+	// the operation token must be persisted before calling the ResourceManager, so that
+	// if the process dies before the next state is saved, the retry reuses the same token
+	if r.getOperationToken() == "" {
+		return r.persistOperationToken(ctx)
+	}
 	r.log.Info("Ready to create or update external resource")
 	nextState, ensureErr := r.applyExecute(ctx)
+	if ensureErr == errBackendUnavailable {
+		return r.pauseForBackend(ctx)
+	}
 	return r.applyTransition(ctx, "Ensure", nextState, ensureErr)
 }
 
@@ -267,19 +337,33 @@ func (r *reconcileRunner) applyExecute(ctx context.Context) (ReconcileState, err
 	// apply that the resource is created or updated externally (though it won't necessarily be ready, it still needs to be verified)
 	var err error
 	var applyResponse ApplyResponse
+	operationType := OperationUpdate
 	if status.IsCreating() {
+		operationType = OperationCreate
 		if !permissions.create() {
 			// this should never be the case - this is more of an assertion (as the state Verify or Create should never have been set in the first place)
 			return Failed, fmt.Errorf(rejectCreateManagedResource)
 		}
-		applyResponse, err = r.ResourceManager.Create(ctx, r.resourceSpec())
+		err = r.callBackend(func() (err error) {
+			applyResponse, err = r.ResourceManager.Create(ctx, r.applySpec())
+			return err
+		})
 	} else {
 		if !permissions.update() {
 			// this should never be the case - this is more of an assertion (as the state Verify or Create should never have been set in the first place)
 			return Failed, fmt.Errorf(rejectCreateManagedResource)
 		}
-		applyResponse, err = r.ResourceManager.Update(ctx, r.resourceSpec())
+		err = r.callBackend(func() (err error) {
+			applyResponse, err = r.ResourceManager.Update(ctx, r.applySpec())
+			return err
+		})
+	}
+	if err == errBackendUnavailable {
+		return r.status.State, err
 	}
+	// the call has been made, so whatever its outcome the runner leaves Creating or Updating,
+	// and the next create or update is a new operation with a new token
+	r.instanceUpdater.setAnnotation(r.AnnotationBaseName+OperationTokenAnnotation, "")
 	applyResult := applyResponse.Result
 	if applyResult == "" || err != nil || applyResult.failed() {
 		// clear last update annotation
@@ -296,9 +380,10 @@ func (r *reconcileRunner) applyExecute(ctx context.Context) (ReconcileState, err
 	// save the last updated spec as a metadata annotation
 	r.instanceUpdater.setAnnotation(lastAppliedAnnotation, r.getJsonSpec())
 
-	// set it to succeeded, completing (if there is a CompletionRunner), or await verification
+	// set it to succeeded, completing (if there is a CompletionRunner), or await verification.
 	if applyResult.awaitingVerification() {
 		r.instanceUpdater.setStatusPayload(applyResponse.Status)
+		r.trackOperation(applyResponse.Operation, operationType)
 		return Verifying, nil
 	} else if applyResult.succeeded() {
 		r.instanceUpdater.setStatusPayload(applyResponse.Status)
@@ -308,6 +393,86 @@ func (r *reconcileRunner) applyExecute(ctx context.Context) (ReconcileState, err
 	}
 }
 
+// records the operation returned by the ResourceManager in the Status, or clears the operation if there is none.
+// an operation is only recorded if the ResourceManager implements OperationPoller, as otherwise nothing would complete it
+func (r *reconcileRunner) trackOperation(operation *OperationHandle, operationType OperationType) {
+	if _, ok := r.ResourceManager.(OperationPoller); operation != nil && !ok {
+		r.log.Info(fmt.Sprintf("Ignoring %s operation %s, as the ResourceManager doesn't implement OperationPoller", operationType, operation.ID))
+		operation = nil
+	}
+	r.instanceUpdater.setOperation(operation.withType(operationType))
+}
+
+// returns the OperationPoller if the ResourceManager implements it and an operation is in progress.
+// an operation the ResourceManager can't poll, such as one recorded by another ResourceManager, is cleared
+func (r *reconcileRunner) operationPoller() OperationPoller {
+	if r.status.Operation == nil {
+		return nil
+	}
+	poller, ok := r.ResourceManager.(OperationPoller)
+	if !ok {
+		r.log.Info(fmt.Sprintf("Clearing %s operation %s, as the ResourceManager doesn't implement OperationPoller", r.status.Operation.Type, r.status.Operation.ID))
+		r.instanceUpdater.setOperation(nil)
+	}
+	return poller
+}
+
+// polls the operation in progress, returning true once it has succeeded and the resource can be verified
+func (r *reconcileRunner) pollOperation(ctx context.Context, poller OperationPoller) (ReconcileState, bool, error) {
+	operation := *r.status.Operation
+	log := r.log.WithValues("Operation", operation.ID)
+	var response OperationResponse
+	err := r.callBackend(func() (err error) {
+		response, err = poller.PollOperation(ctx, r.resourceSpec(), operation)
+		return err
+	})
+	if err == errBackendUnavailable {
+		return r.status.State, false, err
+	}
+	if err != nil {
+		// the operation may still be in progress, so it is polled again rather than failing the resource
+		log.Info(fmt.Sprintf("Unable to poll %s operation, requeuing reconcile loop: %v", operation.Type, err))
+		r.instanceUpdater.setReconcileState(r.status.State, fmt.Sprintf("Unable to poll %s operation %s: %v", operation.Type, operation.ID, err))
+		return r.status.State, false, nil
+	}
+
+	switch {
+	case response.Result.inProgress():
+		log.Info(fmt.Sprintf("%s operation in progress, requeuing reconcile loop", operation.Type))
+		if response.Message != "" && response.Message != r.status.Message {
+			r.instanceUpdater.setReconcileState(r.status.State, response.Message)
+		}
+		return r.status.State, false, nil
+	case response.Result.succeeded():
+		log.Info(fmt.Sprintf("%s operation succeeded", operation.Type))
+		r.instanceUpdater.setOperation(nil)
+		return r.status.State, true, nil
+	case response.Result.failed():
+		r.instanceUpdater.setOperation(nil)
+		message := response.Message
+		if message == "" {
+			message = fmt.Sprintf("%s operation %s failed", operation.Type, operation.ID)
+		}
+		return Failed, false, errors.New(message)
+	}
+	r.instanceUpdater.setOperation(nil)
+	return Failed, false, fmt.Errorf("invalid OperationResult for %s %s", r.ResourceKind, r.Name)
+}
+
+// deletes the external resource, using OperationDeleter if the ResourceManager implements it
+func (r *reconcileRunner) deleteExternal(ctx context.Context) (DeleteResponse, error) {
+	var deleteResponse DeleteResponse
+	err := r.callBackend(func() (err error) {
+		if deleter, ok := r.ResourceManager.(OperationDeleter); ok {
+			deleteResponse, err = deleter.DeleteWithOperation(ctx, r.resourceSpec())
+			return err
+		}
+		deleteResponse.Result, err = r.ResourceManager.Delete(ctx, r.resourceSpec())
+		return err
+	})
+	return deleteResponse, err
+}
+
 func (r *reconcileRunner) succeedOrComplete() ReconcileState {
 	if r.CompletionRunner == nil || r.status.IsSucceeded() {
 		return Succeeded
@@ -359,6 +524,7 @@ func (r *reconcileRunner) tryUpdateInstance(ctx context.Context, count int) erro
 		r.instanceUpdater.clear()
 		return err
 	}
+	r.migrateLegacyAnnotations(instance)
 	err = r.KubeClient.Update(ctx, instance)
 	if err != nil {
 		if count == 0 {
@@ -427,10 +593,17 @@ func (r *reconcileRunner) applyTransition(ctx context.Context, reason string, ne
 	if transitionErr != nil {
 		errorMsg = transitionErr.Error()
 	}
+	result, transitionMsg := r.getTransitionDetails(nextState)
 	if nextState != r.status.State {
+		historyMsg := errorMsg
+		if historyMsg == "" {
+			historyMsg = transitionMsg
+		}
+		r.recordTransition(reason, nextState, historyMsg)
+	}
+	if nextState != r.status.State || r.status.Message == backendUnavailableMessage {
 		r.instanceUpdater.setReconcileState(nextState, errorMsg)
 	}
-	result, transitionMsg := r.getTransitionDetails(nextState)
 	updateErr := r.updateAndLog(ctx, eventType, reason, transitionMsg)
 	if transitionErr != nil {
 		if updateErr != nil {
@@ -447,6 +620,24 @@ func (r *reconcileRunner) applyTransition(ctx context.Context, reason string, ne
 	return result, nil
 }
 
+// adds the transition from the current state to the history in the Status
+func (r *reconcileRunner) recordTransition(reason string, nextState ReconcileState, message string) {
+	maxTransitions := r.Parameters.TransitionHistorySize
+	if maxTransitions == 0 {
+		maxTransitions = 10
+	}
+	if maxTransitions < 0 {
+		return
+	}
+	r.instanceUpdater.addTransition(Transition{
+		To:         nextState,
+		Reason:     reason,
+		Message:    message,
+		Time:       metav1.Now(),
+		Generation: r.objectMeta.GetGeneration(),
+	}, maxTransitions)
+}
+
 func (r *reconcileRunner) getRequeueAfter(transitionState ReconcileState) time.Duration {
 	parameters := r.Parameters
 	requeueAfterDuration := func(requeueSeconds int) time.Duration {
@@ -499,10 +690,86 @@ func (r *reconcileRunner) getJsonSpec() string {
 }
 
 func (r *reconcileRunner) resourceSpec() ResourceSpec {
-	return ResourceSpec{Instance: r.instance, Dependencies: r.dependencies}
+	return ResourceSpec{Instance: r.instance, Dependencies: r.dependencies, ProviderConfig: r.providerConfig}
+}
+
+// returns the ResourceSpec for a Create or Update, with the token of the operation
+func (r *reconcileRunner) applySpec() ResourceSpec {
+	spec := r.resourceSpec()
+	spec.OperationToken = r.getOperationToken()
+	return spec
+}
+
+func (r *reconcileRunner) getOperationToken() string {
+	return r.getAnnotation(OperationTokenAnnotation)
+}
+
+func (r *reconcileRunner) persistOperationToken(ctx context.Context) (ctrl.Result, error) {
+	r.instanceUpdater.setAnnotation(r.AnnotationBaseName+OperationTokenAnnotation, string(uuid.NewUUID()))
+	if err := r.updateInstance(ctx); err != nil {
+		r.log.Info(fmt.Sprintf("Unable to persist operation token: %v", err))
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+const backendUnavailableMessage = BackendUnavailable + ": calls to the external resource are paused after repeated errors"
+
+// errBackendUnavailable is returned instead of calling the ResourceManager when the circuit breaker doesn't allow the call
+var errBackendUnavailable = errors.New(backendUnavailableMessage)
+
+// if the circuit breaker is open, the resource is kept in its current state and the reconcile is requeued
+// without calling the ResourceManager
+func (r *reconcileRunner) pauseIfBackendUnavailable(ctx context.Context) (bool, ctrl.Result, error) {
+	if allowed, _ := r.CircuitBreaker.allow(); allowed {
+		return false, ctrl.Result{}, nil
+	}
+	result, err := r.pauseForBackend(ctx)
+	return true, result, err
+}
+
+// keeps the resource in its current state with the BackendUnavailable condition, requeuing the reconcile for when calls may be retried
+func (r *reconcileRunner) pauseForBackend(ctx context.Context) (ctrl.Result, error) {
+	_, retryAfter := r.CircuitBreaker.allow()
+	if retryAfter == 0 {
+		retryAfter = r.CircuitBreaker.ResetAfter
+	}
+	r.log.Info("Circuit breaker is open, not calling ResourceManager. Requeuing reconcile loop")
+	if r.status.Message != backendUnavailableMessage {
+		r.instanceUpdater.setReconcileState(r.status.State, backendUnavailableMessage)
+	}
+	if condition := r.status.GetCondition(BackendUnavailable); condition == nil || condition.Status != corev1.ConditionTrue {
+		r.instanceUpdater.setCondition(BackendUnavailable, corev1.ConditionTrue, "CircuitOpen", backendUnavailableMessage)
+	}
+	message := fmt.Sprintf("%s %s paused, external backend unavailable.", r.ResourceKind, r.Name)
+	if err := r.updateAndLog(ctx, corev1.EventTypeWarning, BackendUnavailable, message); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{Requeue: true, RequeueAfter: retryAfter}, nil
+}
+
+// makes the call to the ResourceManager if the circuit breaker allows it, recording its outcome.
+// The probe call of a half-open circuit is only taken here, so that it is always released once the call returns
+func (r *reconcileRunner) callBackend(call func() error) error {
+	if !r.CircuitBreaker.acquire() {
+		return errBackendUnavailable
+	}
+	err := call()
+	r.recordBackendResult(err)
+	if err == nil {
+		if condition := r.status.GetCondition(BackendUnavailable); condition != nil && condition.Status == corev1.ConditionTrue {
+			r.instanceUpdater.setCondition(BackendUnavailable, corev1.ConditionFalse, "CircuitClosed", "")
+		}
+	}
+	return err
+}
+
+func (r *reconcileRunner) recordBackendResult(err error) {
+	if r.CircuitBreaker.record(err) {
+		r.log.Info(fmt.Sprintf("Circuit breaker opened for %s after repeated errors from the ResourceManager", r.ResourceKind))
+	}
 }
 
 func (r *reconcileRunner) getAccessPermissions() AccessPermissions {
-	annotations := r.objectMeta.GetAnnotations()
-	return AccessPermissions(annotations[r.AnnotationBaseName+AccessPermissionAnnotation])
+	return AccessPermissions(r.getAnnotation(AccessPermissionAnnotation))
 }
@@ -0,0 +1,159 @@
+package reconciler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// a ResourceManager returning the responses it is given
+type stubResourceManager struct {
+	applyResponse ApplyResponse
+	verify        VerifyResponse
+	deleteResult  DeleteResult
+	err           error
+	// the spec of the last call
+	spec ResourceSpec
+	// the number of calls to Delete
+	deletes int
+}
+
+func (m *stubResourceManager) Create(_ context.Context, spec ResourceSpec) (ApplyResponse, error) {
+	m.spec = spec
+	return m.applyResponse, m.err
+}
+
+func (m *stubResourceManager) Update(_ context.Context, spec ResourceSpec) (ApplyResponse, error) {
+	m.spec = spec
+	return m.applyResponse, m.err
+}
+
+func (m *stubResourceManager) Verify(_ context.Context, spec ResourceSpec) (VerifyResponse, error) {
+	m.spec = spec
+	return m.verify, m.err
+}
+
+func (m *stubResourceManager) Delete(context.Context, ResourceSpec) (DeleteResult, error) {
+	m.deletes++
+	return m.deleteResult, m.err
+}
+
+// returns a runner for a resource in the state, managed by the ResourceManager, without a cluster
+func stubRunner(resourceManager ResourceManager, state ReconcileState) *reconcileRunner {
+	instance := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{"data": "value"}}}
+	instance.SetNamespace("default")
+	instance.SetName("resource")
+	gc := &GenericController{
+		ResourceKind:       "Resource",
+		AnnotationBaseName: "test.example.com",
+		Recorder:           record.NewFakeRecorder(100),
+		ResourceManager:    resourceManager,
+	}
+	return &reconcileRunner{
+		GenericController: gc,
+		NamespacedName:    types.NamespacedName{Namespace: "default", Name: "resource"},
+		instance:          instance,
+		objectMeta:        instance,
+		status:            &Status{State: state},
+		log:               ctrl.Log,
+		instanceUpdater:   &instanceUpdater{},
+		ResourceManager:   resourceManager,
+	}
+}
+
+// applies the updates made by the runner to the instance and its status, as updating the instance does
+func applyUpdates(r *reconcileRunner) {
+	for _, update := range r.instanceUpdater.metaUpdates {
+		update(r.objectMeta)
+	}
+	for _, update := range r.instanceUpdater.statusUpdates {
+		update(r.status)
+	}
+	r.instanceUpdater.metaUpdates = nil
+	r.instanceUpdater.statusUpdates = nil
+}
+
+var _ = Describe("Operation tokens", func() {
+
+	table.DescribeTable("are cleared once a create has been made, whatever its outcome",
+		func(response ApplyResponse, err error, nextState ReconcileState) {
+			resourceManager := &stubResourceManager{applyResponse: response, err: err}
+			r := stubRunner(resourceManager, Creating)
+			r.objectMeta.SetAnnotations(map[string]string{r.AnnotationBaseName + OperationTokenAnnotation: "token"})
+
+			state, _ := r.applyExecute(context.Background())
+			Expect(state).To(Equal(nextState))
+			Expect(resourceManager.spec.OperationToken).To(Equal("token"))
+			applyUpdates(r)
+			Expect(r.getOperationToken()).To(BeEmpty())
+		},
+		table.Entry("succeeded", ApplySucceeded, nil, Succeeded),
+		table.Entry("awaiting verification", ApplyAwaitingVerification, nil, Verifying),
+		table.Entry("error", ApplyError, errors.New("timeout"), Failed),
+		table.Entry("failed without an error", ApplyError, nil, Failed),
+	)
+
+	It("are kept if the backend isn't called", func() {
+		r := stubRunner(&stubResourceManager{}, Creating)
+		r.objectMeta.SetAnnotations(map[string]string{r.AnnotationBaseName + OperationTokenAnnotation: "token"})
+		r.CircuitBreaker = CreateCircuitBreaker(1, time.Hour)
+		Expect(r.CircuitBreaker.acquire()).To(BeTrue())
+		r.CircuitBreaker.record(errors.New("timeout"))
+
+		_, err := r.applyExecute(context.Background())
+		Expect(err).To(Equal(errBackendUnavailable))
+		applyUpdates(r)
+		Expect(r.getOperationToken()).To(Equal("token"))
+	})
+
+	It("are only passed to Create and Update", func() {
+		resourceManager := &stubResourceManager{verify: VerifyResponse{Result: VerifyResultReady}}
+		r := stubRunner(resourceManager, Verifying)
+		r.objectMeta.SetAnnotations(map[string]string{r.AnnotationBaseName + OperationTokenAnnotation: "token"})
+
+		_, err := r.verifyExecute(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resourceManager.spec.Instance).NotTo(BeNil())
+		Expect(resourceManager.spec.OperationToken).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Retrying a delete once the Terminating deadline has passed", func() {
+
+	terminatingRunner := func(resourceManager ResourceManager, permissions string) *reconcileFinalizer {
+		r := stubRunner(resourceManager, Terminating)
+		r.Parameters.TerminatingTimeout = 1000
+		r.Parameters.TerminationEscalation = TerminationEscalationRetryDelete
+		started := metav1.NewTime(time.Now().Add(-time.Minute))
+		r.status.LastTransitionTime = &started
+		if permissions != "" {
+			r.objectMeta.SetAnnotations(map[string]string{r.AnnotationBaseName + AccessPermissionAnnotation: permissions})
+		}
+		return &reconcileFinalizer{*r}
+	}
+
+	It("deletes the external resource again", func() {
+		resourceManager := &stubResourceManager{deleteResult: DeleteSucceeded}
+		escalated, remove := terminatingRunner(resourceManager, "").escalateTermination(context.Background())
+		Expect(escalated).To(BeTrue())
+		Expect(remove).To(BeTrue())
+		Expect(resourceManager.deletes).To(Equal(1))
+	})
+
+	It("doesn't delete the external resource without delete permission", func() {
+		resourceManager := &stubResourceManager{deleteResult: DeleteSucceeded}
+		escalated, remove := terminatingRunner(resourceManager, "cru").escalateTermination(context.Background())
+		Expect(escalated).To(BeTrue())
+		Expect(remove).To(BeTrue())
+		Expect(resourceManager.deletes).To(BeZero())
+	})
+})
@@ -15,6 +15,11 @@ limitations under the License.
 
 package reconciler
 
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
 type ReconcileState string
 
 const (
@@ -35,7 +40,7 @@ func (s *Status) IsUpdating() bool    { return s.State == Updating }
 func (s *Status) IsVerifying() bool   { return s.State == Verifying }
 func (s *Status) IsCompleting() bool  { return s.State == Completing }
 func (s *Status) IsSucceeded() bool   { return s.State == Succeeded }
-func (s *Status) IsRecreating() bool  { return s.State == Terminating }
+func (s *Status) IsRecreating() bool  { return s.State == Recreating }
 func (s *Status) IsFailed() bool      { return s.State == Failed }
 func (s *Status) IsTerminating() bool { return s.State == Terminating }
 
@@ -43,4 +48,41 @@ type Status struct {
 	State         ReconcileState
 	Message       string
 	StatusPayload interface{}
+	// The time the State last changed. Kinds must persist this for state deadlines to be enforced
+	LastTransitionTime *metav1.Time
+	// The long-running operation in progress, if any. Kinds that support OperationPoller must persist this
+	Operation *OperationHandle
+	// The most recent state transitions, oldest first. Kinds can opt in to keeping a history by persisting this
+	Transitions []Transition
+	// Observations of the resource that aren't captured by its State, such as BackendUnavailable. Kinds can opt in by persisting this
+	Conditions []Condition
+}
+
+// A record of a change of ReconcileState
+type Transition struct {
+	From       ReconcileState
+	To         ReconcileState
+	Reason     string
+	Message    string
+	Time       metav1.Time
+	Generation int64
+}
+
+// An observation of the resource, such as whether calls to its backend are paused
+type Condition struct {
+	Type               string
+	Status             corev1.ConditionStatus
+	Reason             string
+	Message            string
+	LastTransitionTime *metav1.Time
+}
+
+// returns the condition of the type, or nil if it isn't set
+func (s *Status) GetCondition(conditionType string) *Condition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
 }
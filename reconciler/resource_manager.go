@@ -26,6 +26,12 @@ import (
 type ResourceSpec struct {
 	Instance     runtime.Object
 	Dependencies map[types.NamespacedName]runtime.Object
+	// A token identifying the current Create or Update operation. It is persisted before the call is made,
+	// so a call repeated because the operator stopped before saving its outcome has the same token, and can be deduplicated.
+	// Once the call is made the token is cleared, so the next Create or Update has a new one. This is only set for Create and Update
+	OperationToken string
+	// The provider config referenced by the resource, holding the endpoint and credentials to use. This is nil if there is none
+	ProviderConfig *ProviderConfig
 }
 
 // ResourceManager is a common abstraction for the controller to interact with external resources
@@ -42,6 +48,28 @@ type ResourceManager interface {
 	Delete(context.Context, ResourceSpec) (DeleteResult, error)
 }
 
+// ResourceManagerWrapper can be implemented by a ResourceManager that decorates another, such as one recording its calls.
+// The Validator, Defaulter and HealthChecker of the wrapped ResourceManager are used unless the decorator implements them itself.
+// OperationPoller and OperationDeleter are called on the decorator, so it must implement them itself if the wrapped ResourceManager does
+type ResourceManagerWrapper interface {
+	Unwrap() ResourceManager
+}
+
+// returns the first of the ResourceManager and the ResourceManagers it wraps that implements the optional interface
+func findImplementation(resourceManager ResourceManager, implements func(ResourceManager) bool) ResourceManager {
+	for resourceManager != nil {
+		if implements(resourceManager) {
+			return resourceManager
+		}
+		wrapper, ok := resourceManager.(ResourceManagerWrapper)
+		if !ok {
+			return nil
+		}
+		resourceManager = wrapper.Unwrap()
+	}
+	return nil
+}
+
 // The Result of a create or update operation
 type ApplyResult string
 
@@ -96,6 +124,8 @@ func (r DeleteResult) awaitingVerification() bool { return r == DeleteAwaitingVe
 type ApplyResponse struct {
 	Result ApplyResult
 	Status interface{}
+	// The handle of a long-running operation, if the external API returned one. See OperationPoller
+	Operation *OperationHandle
 }
 
 var (
@@ -0,0 +1,53 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// returns the selector of the LabelSelector of the parameters, or nil if there is none
+func (parameters ReconcileParameters) labelSelector() (labels.Selector, error) {
+	if parameters.LabelSelector == "" {
+		return nil, nil
+	}
+	return labels.Parse(parameters.LabelSelector)
+}
+
+// returns whether the resource is in one of the Namespaces, matches the LabelSelector of the parameters of the controller
+// and belongs to the class of the controller
+func (gc *GenericController) inScope(meta metav1.Object) bool {
+	if len(gc.Parameters.Namespaces) > 0 && !containsString(gc.Parameters.Namespaces, meta.GetNamespace()) {
+		return false
+	}
+	if !gc.inClass(meta) {
+		return false
+	}
+	return gc.selector == nil || gc.selector.Matches(labels.Set(meta.GetLabels()))
+}
+
+// filters out the events of resources outside the scope of the controller, so they are never queued
+func (gc *GenericController) scopePredicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return gc.inScope(e.Meta) },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return gc.inScope(e.MetaNew) },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return gc.inScope(e.Meta) },
+		GenericFunc: func(e event.GenericEvent) bool { return gc.inScope(e.Meta) },
+	}
+}
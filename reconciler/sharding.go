@@ -0,0 +1,339 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	shardGroupLabel = "operatify.io/shard-group"
+	shardRoleLabel  = "operatify.io/shard-role"
+	shardRoleMember = "member"
+	shardRoleShard  = "shard"
+)
+
+// ShardingConfig configures the sharding of resources across the replicas of an operator
+type ShardingConfig struct {
+	// The namespace of the Leases, usually that of the operator
+	Namespace string
+	// The prefix of the names of the Leases. Replicas with the same Name share the shards
+	Name string
+	// The number of shards, which must be the same for every replica. This is the maximum number of replicas that share the work
+	Shards int
+	// Identifies the replica, for example its pod name
+	Identity string
+	// The number of milliseconds after which the Leases of a replica that has stopped renewing them expire (defaults to 15000)
+	LeaseDuration int
+	// The number of milliseconds after its last renewal that the replica stops reconciling the resources of a shard,
+	// which must be shorter than LeaseDuration so that it stops before another replica can claim the shard (defaults to two thirds of LeaseDuration)
+	RenewDeadline int
+	// The number of milliseconds between renewals of the Leases, which must be shorter than RenewDeadline (defaults to a third of LeaseDuration)
+	RenewPeriod int
+}
+
+// ShardManager claims shards for a replica using Leases, so that each resource is reconciled by one replica.
+// A resource is in the shard given by the hash of its namespace and name.
+// Each replica renews a member Lease, and the live members divide the shards between them,
+// so shards are released and claimed again as replicas come and go
+type ShardManager struct {
+	Config     ShardingConfig
+	KubeClient client.Client
+	Log        logr.Logger
+	mutex      sync.RWMutex
+	// the time each shard held by the replica was last renewed
+	owned     map[int]time.Time
+	listeners []func(acquired map[int]bool)
+}
+
+// CreateShardManager creates the ShardManager, which must be added to the manager so that it runs on every replica.
+// The KubeClient should read directly from the API server rather than from a cache
+func CreateShardManager(config ShardingConfig, kubeClient client.Client, logger logr.Logger) (*ShardManager, error) {
+	if config.Shards <= 0 {
+		return nil, fmt.Errorf("the number of shards must be positive")
+	}
+	if config.Namespace == "" || config.Name == "" || config.Identity == "" {
+		return nil, fmt.Errorf("the namespace, name and identity of the shard Leases must be set")
+	}
+	if config.LeaseDuration == 0 {
+		config.LeaseDuration = 15000
+	}
+	if config.RenewDeadline == 0 {
+		config.RenewDeadline = config.LeaseDuration * 2 / 3
+	}
+	if config.RenewPeriod == 0 {
+		config.RenewPeriod = config.LeaseDuration / 3
+	}
+	if config.RenewDeadline >= config.LeaseDuration {
+		return nil, fmt.Errorf("the renew deadline must be shorter than the lease duration")
+	}
+	if config.RenewPeriod >= config.RenewDeadline {
+		return nil, fmt.Errorf("the renew period must be shorter than the renew deadline")
+	}
+	return &ShardManager{
+		Config:     config,
+		KubeClient: kubeClient,
+		Log:        logger,
+		owned:      map[int]time.Time{},
+	}, nil
+}
+
+// ShardOf returns the shard of the resource
+func ShardOf(name types.NamespacedName, shards int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name.Namespace + "/" + name.Name))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// Owns returns whether the resource is in one of the shards held by the replica.
+// A shard stops being held if its Lease hasn't been renewed within the RenewDeadline,
+// which leaves the replica time to stop before the Lease expires and another replica may claim the shard
+func (s *ShardManager) Owns(name types.NamespacedName) bool {
+	shard := ShardOf(name, s.Config.Shards)
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	renewed, ok := s.owned[shard]
+	return ok && time.Since(renewed) < s.renewDeadline()
+}
+
+// OwnedShards returns the shards held by the replica, in order
+func (s *ShardManager) OwnedShards() []int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var shards []int
+	for shard, renewed := range s.owned {
+		if time.Since(renewed) < s.renewDeadline() {
+			shards = append(shards, shard)
+		}
+	}
+	sort.Ints(shards)
+	return shards
+}
+
+// AddListener registers a function called with the shards the replica claims, so that their resources can be reconciled
+func (s *ShardManager) AddListener(listener func(acquired map[int]bool)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// NeedLeaderElection returns false, as the ShardManager runs on every replica
+func (s *ShardManager) NeedLeaderElection() bool {
+	return false
+}
+
+// Start claims and renews shards until the channel is closed, then releases them
+func (s *ShardManager) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(time.Duration(s.Config.RenewPeriod) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		s.rebalance(context.Background())
+		select {
+		case <-stop:
+			s.release(context.Background())
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// renews the member Lease of the replica, then claims the shards assigned to it and releases any others it holds
+func (s *ShardManager) rebalance(ctx context.Context) {
+	if err := s.renewLease(ctx, s.memberLeaseName(s.Config.Identity), shardRoleMember, true); err != nil {
+		s.Log.Info(fmt.Sprintf("Unable to renew member Lease: %v", err))
+		return
+	}
+	members, err := s.liveMembers(ctx)
+	if err != nil {
+		s.Log.Info(fmt.Sprintf("Unable to list member Leases: %v", err))
+		return
+	}
+	index := sort.SearchStrings(members, s.Config.Identity)
+
+	acquired := map[int]bool{}
+	for shard := 0; shard < s.Config.Shards; shard++ {
+		assigned := shard%len(members) == index
+		// the Lease is renewed at the time of the request at the latest, so the deadline runs from then
+		renewed := time.Now()
+		held, err := s.claimOrRelease(ctx, shard, assigned)
+		if err != nil {
+			s.Log.Info(fmt.Sprintf("Unable to update Lease of shard %d: %v", shard, err))
+			continue
+		}
+		s.mutex.Lock()
+		if held {
+			if _, ok := s.owned[shard]; !ok {
+				acquired[shard] = true
+			}
+			s.owned[shard] = renewed
+		} else {
+			delete(s.owned, shard)
+		}
+		s.mutex.Unlock()
+	}
+
+	if len(acquired) > 0 {
+		s.Log.Info(fmt.Sprintf("Claimed shards, now holding %v", s.OwnedShards()))
+		s.mutex.RLock()
+		listeners := s.listeners
+		s.mutex.RUnlock()
+		for _, listener := range listeners {
+			listener(acquired)
+		}
+	}
+}
+
+// returns the identities of the replicas whose member Leases haven't expired, in order
+func (s *ShardManager) liveMembers(ctx context.Context) ([]string, error) {
+	leases := &coordinationv1.LeaseList{}
+	if err := s.KubeClient.List(ctx, leases, client.InNamespace(s.Config.Namespace),
+		client.MatchingLabels{shardGroupLabel: s.Config.Name, shardRoleLabel: shardRoleMember}); err != nil {
+		return nil, err
+	}
+	members := []string{s.Config.Identity}
+	for _, lease := range leases.Items {
+		holder := leaseHolder(&lease)
+		if holder != "" && holder != s.Config.Identity && !leaseExpired(&lease) {
+			members = append(members, holder)
+		}
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+// claims the shard if it is assigned to the replica and isn't held by another live replica, or releases it if it isn't assigned.
+// returns whether the replica holds the shard
+func (s *ShardManager) claimOrRelease(ctx context.Context, shard int, assigned bool) (bool, error) {
+	lease := &coordinationv1.Lease{}
+	name := s.shardLeaseName(shard)
+	err := s.KubeClient.Get(ctx, types.NamespacedName{Namespace: s.Config.Namespace, Name: name}, lease)
+	if apierrors.IsNotFound(err) {
+		if !assigned {
+			return false, nil
+		}
+		return true, s.renewLease(ctx, name, shardRoleShard, false)
+	}
+	if err != nil {
+		return false, err
+	}
+	holder := leaseHolder(lease)
+	switch {
+	case assigned && (holder == s.Config.Identity || holder == "" || leaseExpired(lease)):
+		s.setHolder(lease, s.Config.Identity)
+		return true, s.KubeClient.Update(ctx, lease)
+	case !assigned && holder == s.Config.Identity:
+		s.setHolder(lease, "")
+		return false, s.KubeClient.Update(ctx, lease)
+	}
+	return false, nil
+}
+
+// renews the Lease held by the replica, creating it if it doesn't exist
+func (s *ShardManager) renewLease(ctx context.Context, name string, role string, update bool) error {
+	lease := &coordinationv1.Lease{}
+	err := s.KubeClient.Get(ctx, types.NamespacedName{Namespace: s.Config.Namespace, Name: name}, lease)
+	if apierrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{
+			Namespace: s.Config.Namespace,
+			Name:      name,
+			Labels:    map[string]string{shardGroupLabel: s.Config.Name, shardRoleLabel: role},
+		}}
+		s.setHolder(lease, s.Config.Identity)
+		return s.KubeClient.Create(ctx, lease)
+	}
+	if err != nil {
+		return err
+	}
+	if !update {
+		return fmt.Errorf("lease %s already exists", name)
+	}
+	s.setHolder(lease, s.Config.Identity)
+	return s.KubeClient.Update(ctx, lease)
+}
+
+// releases the shards held by the replica and deletes its member Lease, so the other replicas can claim them straight away
+func (s *ShardManager) release(ctx context.Context) {
+	for _, shard := range s.OwnedShards() {
+		if _, err := s.claimOrRelease(ctx, shard, false); err != nil {
+			s.Log.Info(fmt.Sprintf("Unable to release shard %d: %v", shard, err))
+		}
+	}
+	s.mutex.Lock()
+	s.owned = map[int]time.Time{}
+	s.mutex.Unlock()
+	member := &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: s.Config.Namespace, Name: s.memberLeaseName(s.Config.Identity)}}
+	if err := s.KubeClient.Delete(ctx, member); err != nil && !apierrors.IsNotFound(err) {
+		s.Log.Info(fmt.Sprintf("Unable to delete member Lease: %v", err))
+	}
+}
+
+func (s *ShardManager) setHolder(lease *coordinationv1.Lease, holder string) {
+	now := metav1.NowMicro()
+	durationSeconds := int32((s.leaseDuration() + time.Second - 1) / time.Second)
+	if leaseHolder(lease) != holder {
+		transitions := int32(0)
+		if lease.Spec.LeaseTransitions != nil {
+			transitions = *lease.Spec.LeaseTransitions + 1
+		}
+		lease.Spec.LeaseTransitions = &transitions
+		lease.Spec.AcquireTime = &now
+	}
+	lease.Spec.HolderIdentity = &holder
+	lease.Spec.LeaseDurationSeconds = &durationSeconds
+	lease.Spec.RenewTime = &now
+}
+
+func (s *ShardManager) leaseDuration() time.Duration {
+	return time.Duration(s.Config.LeaseDuration) * time.Millisecond
+}
+
+func (s *ShardManager) renewDeadline() time.Duration {
+	return time.Duration(s.Config.RenewDeadline) * time.Millisecond
+}
+
+func (s *ShardManager) memberLeaseName(identity string) string {
+	return fmt.Sprintf("%s-member-%s", s.Config.Name, identity)
+}
+
+func (s *ShardManager) shardLeaseName(shard int) string {
+	return fmt.Sprintf("%s-shard-%d", s.Config.Name, shard)
+}
+
+func leaseHolder(lease *coordinationv1.Lease) string {
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+func leaseExpired(lease *coordinationv1.Lease) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	return time.Since(lease.Spec.RenewTime.Time) > time.Duration(*lease.Spec.LeaseDurationSeconds)*time.Second
+}
@@ -0,0 +1,101 @@
+package reconciler
+
+import (
+	"sort"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Sharding", func() {
+
+	const shards = 8
+	const timeout = 5 * time.Second
+	const interval = 50 * time.Millisecond
+
+	createShardManager := func(kubeClient client.Client, identity string) *ShardManager {
+		shardManager, err := CreateShardManager(ShardingConfig{
+			Namespace:     "default",
+			Name:          "test",
+			Shards:        shards,
+			Identity:      identity,
+			LeaseDuration: 1000,
+			RenewPeriod:   100,
+		}, kubeClient, ctrl.Log)
+		Expect(err).NotTo(HaveOccurred())
+		return shardManager
+	}
+
+	allShards := func(shardManagers ...*ShardManager) []int {
+		var owned []int
+		for _, shardManager := range shardManagers {
+			owned = append(owned, shardManager.OwnedShards()...)
+		}
+		sort.Ints(owned)
+		return owned
+	}
+
+	It("divides the shards between the replicas, and rebalances when one stops", func() {
+		kubeClient := fake.NewFakeClientWithScheme(scheme.Scheme)
+		first := createShardManager(kubeClient, "first")
+		second := createShardManager(kubeClient, "second")
+
+		stopFirst, stopSecond := make(chan struct{}), make(chan struct{})
+		defer close(stopFirst)
+		go func() { _ = first.Start(stopFirst) }()
+		Eventually(first.OwnedShards, timeout, interval).Should(HaveLen(shards))
+
+		go func() { _ = second.Start(stopSecond) }()
+		Eventually(second.OwnedShards, timeout, interval).Should(HaveLen(shards / 2))
+		Eventually(first.OwnedShards, timeout, interval).Should(HaveLen(shards / 2))
+		Expect(allShards(first, second)).To(Equal([]int{0, 1, 2, 3, 4, 5, 6, 7}))
+
+		close(stopSecond)
+		Eventually(first.OwnedShards, timeout, interval).Should(HaveLen(shards))
+	})
+
+	It("only owns the resources in its shards", func() {
+		kubeClient := fake.NewFakeClientWithScheme(scheme.Scheme)
+		shardManager := createShardManager(kubeClient, "only")
+		name := types.NamespacedName{Namespace: "default", Name: "a-test"}
+		Expect(shardManager.Owns(name)).To(BeFalse())
+
+		stop := make(chan struct{})
+		defer close(stop)
+		go func() { _ = shardManager.Start(stop) }()
+		Eventually(func() bool { return shardManager.Owns(name) }, timeout, interval).Should(BeTrue())
+		Expect(shardManager.OwnedShards()).To(ContainElement(ShardOf(name, shards)))
+	})
+
+	It("stops owning a shard at the renew deadline, before its Lease expires", func() {
+		shardManager := createShardManager(fake.NewFakeClientWithScheme(scheme.Scheme), "only")
+		Expect(shardManager.Config.RenewDeadline).To(Equal(666))
+		name := types.NamespacedName{Namespace: "default", Name: "a-test"}
+		shard := ShardOf(name, shards)
+
+		shardManager.owned[shard] = time.Now().Add(-500 * time.Millisecond)
+		Expect(shardManager.Owns(name)).To(BeTrue())
+
+		shardManager.owned[shard] = time.Now().Add(-700 * time.Millisecond)
+		Expect(shardManager.Owns(name)).To(BeFalse())
+		Expect(shardManager.OwnedShards()).To(BeEmpty())
+	})
+
+	It("rejects a renew deadline that isn't shorter than the lease duration", func() {
+		config := ShardingConfig{Namespace: "default", Name: "test", Shards: shards, Identity: "only", LeaseDuration: 1000}
+		config.RenewDeadline = 1000
+		_, err := CreateShardManager(config, nil, ctrl.Log)
+		Expect(err).To(HaveOccurred())
+
+		config.RenewDeadline = 500
+		config.RenewPeriod = 500
+		_, err = CreateShardManager(config, nil, ctrl.Log)
+		Expect(err).To(HaveOccurred())
+	})
+})
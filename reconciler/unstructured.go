@@ -0,0 +1,437 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/yaml"
+)
+
+// UnstructuredConfig declares kinds that are reconciled as unstructured.Unstructured, without Go types.
+// It is loaded from a YAML or JSON file by LoadUnstructuredConfig
+type UnstructuredConfig struct {
+	Kinds []UnstructuredKindConfig `json:"kinds"`
+}
+
+// UnstructuredKindConfig declares the GroupVersionKind of a kind, where its status is persisted and how its dependencies are referenced.
+// Paths are dot separated field paths into the object, such as "status.state" or "spec.aTestRef"
+type UnstructuredKindConfig struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+	// Defaults to <lowercase kind>.finalizers.<group>
+	FinalizerName string                         `json:"finalizerName,omitempty"`
+	Status        UnstructuredStatusPaths        `json:"status,omitempty"`
+	Dependencies  []UnstructuredDependencyConfig `json:"dependencies,omitempty"`
+	// The paths of the spec fields that can't be changed on the external resource once it is applied
+	ImmutableFields []string `json:"immutableFields,omitempty"`
+}
+
+// UnstructuredStatusPaths are the paths the fields of the Status are persisted to.
+// Apart from StatusPayload, which is only persisted if a path is given, these default to the fields of the shared status type
+// (status.state, status.message, status.lastTransitionTime, status.operation, status.transitions and status.conditions)
+type UnstructuredStatusPaths struct {
+	State              string `json:"state,omitempty"`
+	Message            string `json:"message,omitempty"`
+	StatusPayload      string `json:"statusPayload,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+	Operation          string `json:"operation,omitempty"`
+	Transitions        string `json:"transitions,omitempty"`
+	Conditions         string `json:"conditions,omitempty"`
+}
+
+// UnstructuredDependencyConfig declares a dependency on a resource of another kind, named by a field of the resource
+type UnstructuredDependencyConfig struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+	// The path of the field holding the name of the dependency. There is no dependency if the field is empty
+	NamePath string `json:"namePath"`
+	// The path of the field holding the namespace of the dependency. Defaults to the namespace of the resource
+	NamespacePath string `json:"namespacePath,omitempty"`
+	// The path of the state of the dependency, which has succeeded once this is Succeeded. Defaults to status.state
+	StatePath string `json:"statePath,omitempty"`
+	// Whether the dependency is the owner of the resource. Only one dependency can be the owner
+	Owner bool `json:"owner,omitempty"`
+}
+
+// LoadUnstructuredConfig reads an UnstructuredConfig from a YAML or JSON file and validates it
+func LoadUnstructuredConfig(path string) (*UnstructuredConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &UnstructuredConfig{}
+	if err := yaml.UnmarshalStrict(b, config); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", path, err)
+	}
+	for _, kind := range config.Kinds {
+		if err := kind.validate(); err != nil {
+			return nil, fmt.Errorf("invalid kind in %s: %v", path, err)
+		}
+	}
+	return config, nil
+}
+
+func (c *UnstructuredKindConfig) GroupVersionKind() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: c.Group, Version: c.Version, Kind: c.Kind}
+}
+
+func (c *UnstructuredDependencyConfig) GroupVersionKind() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: c.Group, Version: c.Version, Kind: c.Kind}
+}
+
+func (c *UnstructuredKindConfig) validate() error {
+	if c.Version == "" || c.Kind == "" {
+		return fmt.Errorf("version and kind must be defined, got '%s'", c.GroupVersionKind())
+	}
+	owners := 0
+	for _, dep := range c.Dependencies {
+		if dep.Owner {
+			owners++
+		}
+		if owners > 1 {
+			return fmt.Errorf("more than one dependency of %s is declared as its owner", c.Kind)
+		}
+		if dep.Version == "" || dep.Kind == "" {
+			return fmt.Errorf("version and kind must be defined for dependencies of %s, got '%s'", c.Kind, dep.GroupVersionKind())
+		}
+		if dep.NamePath == "" {
+			return fmt.Errorf("no namePath defined for dependency %s of %s", dep.Kind, c.Kind)
+		}
+	}
+	return nil
+}
+
+func (c *UnstructuredKindConfig) getFinalizerName() string {
+	if c.FinalizerName != "" {
+		return c.FinalizerName
+	}
+	return strings.ToLower(c.Kind) + ".finalizers." + c.Group
+}
+
+// UnstructuredDefinitionManager is a DefinitionManager for a kind declared by an UnstructuredKindConfig
+type UnstructuredDefinitionManager struct {
+	Config UnstructuredKindConfig
+}
+
+func CreateUnstructuredDefinitionManager(config UnstructuredKindConfig) (*UnstructuredDefinitionManager, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &UnstructuredDefinitionManager{Config: config}, nil
+}
+
+func (dm *UnstructuredDefinitionManager) GetDefinition(ctx context.Context, namespacedName types.NamespacedName) *ResourceDefinition {
+	return &ResourceDefinition{
+		InitialInstance: dm.NewInstance(),
+		StatusAccessor:  dm.GetStatus,
+		StatusUpdater:   dm.UpdateStatus,
+	}
+}
+
+func (dm *UnstructuredDefinitionManager) GetDependencies(ctx context.Context, thisInstance runtime.Object) (*DependencyDefinitions, error) {
+	u, err := dm.convertInstance(thisInstance)
+	if err != nil {
+		return nil, err
+	}
+
+	defs := &DependencyDefinitions{Dependencies: []*Dependency{}}
+	for _, depConfig := range dm.Config.Dependencies {
+		name, _, _ := unstructured.NestedString(u.Object, fieldPath(depConfig.NamePath)...)
+		if name == "" {
+			continue
+		}
+		namespace := u.GetNamespace()
+		if depConfig.NamespacePath != "" {
+			if ns, _, _ := unstructured.NestedString(u.Object, fieldPath(depConfig.NamespacePath)...); ns != "" {
+				namespace = ns
+			}
+		}
+		dep := &Dependency{
+			InitialInstance:   newUnstructured(depConfig.GroupVersionKind()),
+			NamespacedName:    types.NamespacedName{Namespace: namespace, Name: name},
+			SucceededAccessor: dependencySucceededAccessor(depConfig.StatePath),
+		}
+		if depConfig.Owner {
+			defs.Owner = dep
+		} else {
+			defs.Dependencies = append(defs.Dependencies, dep)
+		}
+	}
+	return defs, nil
+}
+
+// NewInstance returns a new empty instance of the kind
+func (dm *UnstructuredDefinitionManager) NewInstance() runtime.Object {
+	return newUnstructured(dm.Config.GroupVersionKind())
+}
+
+func (dm *UnstructuredDefinitionManager) GetStatus(instance runtime.Object) (*Status, error) {
+	u, err := dm.convertInstance(instance)
+	if err != nil {
+		return nil, err
+	}
+	paths := dm.statusPaths()
+	status := &Status{}
+	state, _, _ := unstructured.NestedString(u.Object, fieldPath(paths.State)...)
+	status.State = ReconcileState(state)
+	status.Message, _, _ = unstructured.NestedString(u.Object, fieldPath(paths.Message)...)
+	if paths.StatusPayload != "" {
+		status.StatusPayload, _, _ = unstructured.NestedFieldCopy(u.Object, fieldPath(paths.StatusPayload)...)
+	}
+
+	var lastTransitionTime *metav1.Time
+	if err := readField(u, paths.LastTransitionTime, &lastTransitionTime); err != nil {
+		return nil, err
+	}
+	status.LastTransitionTime = lastTransitionTime
+	var operation *unstructuredOperation
+	if err := readField(u, paths.Operation, &operation); err != nil {
+		return nil, err
+	}
+	if operation != nil {
+		status.Operation = &OperationHandle{ID: operation.Id, Type: OperationType(operation.Type)}
+	}
+	var transitions []unstructuredTransition
+	if err := readField(u, paths.Transitions, &transitions); err != nil {
+		return nil, err
+	}
+	for _, t := range transitions {
+		status.Transitions = append(status.Transitions, Transition{
+			From:       ReconcileState(t.From),
+			To:         ReconcileState(t.To),
+			Reason:     t.Reason,
+			Message:    t.Message,
+			Time:       t.Time,
+			Generation: t.Generation,
+		})
+	}
+	var conditions []unstructuredCondition
+	if err := readField(u, paths.Conditions, &conditions); err != nil {
+		return nil, err
+	}
+	for _, c := range conditions {
+		status.Conditions = append(status.Conditions, Condition(c))
+	}
+	return status, nil
+}
+
+func (dm *UnstructuredDefinitionManager) UpdateStatus(instance runtime.Object, status *Status) error {
+	u, err := dm.convertInstance(instance)
+	if err != nil {
+		return err
+	}
+	paths := dm.statusPaths()
+	if err := writeField(u, paths.State, string(status.State)); err != nil {
+		return err
+	}
+	if err := writeField(u, paths.Message, status.Message); err != nil {
+		return err
+	}
+	if paths.StatusPayload != "" {
+		if err := writeField(u, paths.StatusPayload, status.StatusPayload); err != nil {
+			return err
+		}
+	}
+	if err := writeField(u, paths.LastTransitionTime, status.LastTransitionTime); err != nil {
+		return err
+	}
+
+	var operation *unstructuredOperation
+	if status.Operation != nil {
+		operation = &unstructuredOperation{Id: status.Operation.ID, Type: string(status.Operation.Type)}
+	}
+	if err := writeField(u, paths.Operation, operation); err != nil {
+		return err
+	}
+	var transitions []unstructuredTransition
+	for _, t := range status.Transitions {
+		transitions = append(transitions, unstructuredTransition{
+			From:       string(t.From),
+			To:         string(t.To),
+			Reason:     t.Reason,
+			Message:    t.Message,
+			Time:       t.Time,
+			Generation: t.Generation,
+		})
+	}
+	if err := writeField(u, paths.Transitions, transitions); err != nil {
+		return err
+	}
+	var conditions []unstructuredCondition
+	for _, c := range status.Conditions {
+		conditions = append(conditions, unstructuredCondition(c))
+	}
+	return writeField(u, paths.Conditions, conditions)
+}
+
+// GetSpec returns the spec of the instance as a map
+func (dm *UnstructuredDefinitionManager) GetSpec(instance runtime.Object) (interface{}, error) {
+	u, err := dm.convertInstance(instance)
+	if err != nil {
+		return nil, err
+	}
+	spec, _, err := unstructured.NestedMap(u.Object, "spec")
+	return spec, err
+}
+
+func (dm *UnstructuredDefinitionManager) convertInstance(instance runtime.Object) (*unstructured.Unstructured, error) {
+	u, ok := instance.(*unstructured.Unstructured)
+	if !ok || u.GroupVersionKind() != dm.Config.GroupVersionKind() {
+		return nil, fmt.Errorf("failed type assertion on kind: %s", dm.Config.Kind)
+	}
+	return u, nil
+}
+
+func (dm *UnstructuredDefinitionManager) statusPaths() UnstructuredStatusPaths {
+	paths := dm.Config.Status
+	setDefault := func(path *string, defaultPath string) {
+		if *path == "" {
+			*path = defaultPath
+		}
+	}
+	setDefault(&paths.State, "status.state")
+	setDefault(&paths.Message, "status.message")
+	setDefault(&paths.LastTransitionTime, "status.lastTransitionTime")
+	setDefault(&paths.Operation, "status.operation")
+	setDefault(&paths.Transitions, "status.transitions")
+	setDefault(&paths.Conditions, "status.conditions")
+	return paths
+}
+
+// CreateUnstructuredControllerFactory creates a ControllerFactory for a kind declared by an UnstructuredKindConfig.
+// The resources passed to the ResourceManager are *unstructured.Unstructured
+func CreateUnstructuredControllerFactory(
+	config UnstructuredKindConfig,
+	resourceManagerCreator func(logr.Logger, record.EventRecorder) ResourceManager,
+	annotationBaseName string) (*ControllerFactory, error) {
+	definitionManager, err := CreateUnstructuredDefinitionManager(config)
+	if err != nil {
+		return nil, err
+	}
+	var dependencyKinds []runtime.Object
+	for _, dep := range config.Dependencies {
+		dependencyKinds = append(dependencyKinds, newUnstructured(dep.GroupVersionKind()))
+	}
+	return &ControllerFactory{
+		Prototype:              definitionManager.NewInstance(),
+		ResourceKind:           config.Kind,
+		ResourceManagerCreator: resourceManagerCreator,
+		DefinitionManager:      definitionManager,
+		FinalizerName:          config.getFinalizerName(),
+		AnnotationBaseName:     annotationBaseName,
+		DependencyKinds:        dependencyKinds,
+		ImmutableFields:        config.ImmutableFields,
+	}, nil
+}
+
+// the persisted forms of OperationHandle and Transition, matching the shared status type
+type unstructuredOperation struct {
+	Id   string `json:"id"`
+	Type string `json:"type"`
+}
+
+type unstructuredTransition struct {
+	From       string      `json:"from,omitempty"`
+	To         string      `json:"to"`
+	Reason     string      `json:"reason,omitempty"`
+	Message    string      `json:"message,omitempty"`
+	Time       metav1.Time `json:"time"`
+	Generation int64       `json:"generation,omitempty"`
+}
+
+type unstructuredCondition struct {
+	Type               string                 `json:"type"`
+	Status             corev1.ConditionStatus `json:"status"`
+	Reason             string                 `json:"reason,omitempty"`
+	Message            string                 `json:"message,omitempty"`
+	LastTransitionTime *metav1.Time           `json:"lastTransitionTime,omitempty"`
+}
+
+func newUnstructured(gvk schema.GroupVersionKind) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(gvk)
+	return u
+}
+
+func fieldPath(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "."), ".")
+}
+
+func dependencySucceededAccessor(statePath string) SucceededAccessor {
+	if statePath == "" {
+		statePath = "status.state"
+	}
+	return func(instance runtime.Object) (bool, error) {
+		u, ok := instance.(*unstructured.Unstructured)
+		if !ok {
+			return false, fmt.Errorf("dependency %T is not unstructured", instance)
+		}
+		state, _, err := unstructured.NestedString(u.Object, fieldPath(statePath)...)
+		return ReconcileState(state) == Succeeded, err
+	}
+}
+
+// reads the field at the path into target by way of its JSON representation
+func readField(u *unstructured.Unstructured, path string, target interface{}) error {
+	value, found, err := unstructured.NestedFieldNoCopy(u.Object, fieldPath(path)...)
+	if err != nil || !found {
+		return err
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, target); err != nil {
+		return fmt.Errorf("unable to read %s: %v", path, err)
+	}
+	return nil
+}
+
+// writes the JSON representation of value to the field at the path, removing the field if the value is empty
+func writeField(u *unstructured.Unstructured, path string, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var converted interface{}
+	if err := json.Unmarshal(b, &converted); err != nil {
+		return err
+	}
+	if converted == nil || converted == "" {
+		unstructured.RemoveNestedField(u.Object, fieldPath(path)...)
+		return nil
+	}
+	if list, ok := converted.([]interface{}); ok && len(list) == 0 {
+		unstructured.RemoveNestedField(u.Object, fieldPath(path)...)
+		return nil
+	}
+	return unstructured.SetNestedField(u.Object, converted, fieldPath(path)...)
+}
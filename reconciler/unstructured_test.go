@@ -0,0 +1,180 @@
+package reconciler
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("UnstructuredDefinitionManager", func() {
+
+	ctx := context.Background()
+	// times are persisted to the second
+	now := metav1.NewTime(time.Unix(time.Now().Unix(), 0))
+
+	config := UnstructuredKindConfig{
+		Group:   "test.example.com",
+		Version: "v1",
+		Kind:    "Thing",
+		Dependencies: []UnstructuredDependencyConfig{
+			{Group: "test.example.com", Version: "v1", Kind: "Owner", NamePath: "spec.ownerRef", Owner: true},
+			{Group: "test.example.com", Version: "v1", Kind: "Other", NamePath: "spec.otherRef.name", NamespacePath: "spec.otherRef.namespace", StatePath: "status.phase"},
+		},
+	}
+
+	status := &Status{
+		State:              Succeeded,
+		Message:            "done",
+		StatusPayload:      map[string]interface{}{"id": "thing-1", "ready": true},
+		LastTransitionTime: &now,
+		Operation:          &OperationHandle{ID: "op-1", Type: OperationUpdate},
+		Transitions: []Transition{
+			{From: Creating, To: Verifying, Time: now, Generation: 1},
+			{From: Verifying, To: Succeeded, Reason: "Ready", Message: "done", Time: now, Generation: 1},
+		},
+		Conditions: []Condition{
+			{Type: "BackendUnavailable", Status: corev1.ConditionFalse, Reason: "Closed", LastTransitionTime: &now},
+		},
+	}
+
+	newInstance := func(dm *UnstructuredDefinitionManager) *unstructured.Unstructured {
+		u := dm.NewInstance().(*unstructured.Unstructured)
+		u.SetNamespace("default")
+		u.SetName("thing")
+		return u
+	}
+
+	It("round-trips the Status through the default paths", func() {
+		dm, err := CreateUnstructuredDefinitionManager(config)
+		Expect(err).NotTo(HaveOccurred())
+		u := newInstance(dm)
+
+		Expect(dm.UpdateStatus(u, status)).To(Succeed())
+		state, _, _ := unstructured.NestedString(u.Object, "status", "state")
+		Expect(state).To(Equal("Succeeded"))
+		operationID, _, _ := unstructured.NestedString(u.Object, "status", "operation", "id")
+		Expect(operationID).To(Equal("op-1"))
+		transitions, _, _ := unstructured.NestedSlice(u.Object, "status", "transitions")
+		Expect(transitions).To(HaveLen(2))
+
+		read, err := dm.GetStatus(u)
+		Expect(err).NotTo(HaveOccurred())
+		// the payload is only persisted if a path is given for it
+		expected := *status
+		expected.StatusPayload = nil
+		Expect(read).To(Equal(&expected))
+	})
+
+	It("round-trips the Status through configured paths", func() {
+		c := config
+		c.Status = UnstructuredStatusPaths{
+			State:         "status.phase",
+			Message:       "status.detail.message",
+			StatusPayload: "status.payload",
+			Transitions:   "status.history",
+		}
+		dm, err := CreateUnstructuredDefinitionManager(c)
+		Expect(err).NotTo(HaveOccurred())
+		u := newInstance(dm)
+
+		Expect(dm.UpdateStatus(u, status)).To(Succeed())
+		phase, _, _ := unstructured.NestedString(u.Object, "status", "phase")
+		Expect(phase).To(Equal("Succeeded"))
+		message, _, _ := unstructured.NestedString(u.Object, "status", "detail", "message")
+		Expect(message).To(Equal("done"))
+		_, found, _ := unstructured.NestedFieldNoCopy(u.Object, "status", "state")
+		Expect(found).To(BeFalse())
+
+		read, err := dm.GetStatus(u)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read).To(Equal(status))
+	})
+
+	It("removes the fields of an empty Status", func() {
+		dm, err := CreateUnstructuredDefinitionManager(config)
+		Expect(err).NotTo(HaveOccurred())
+		u := newInstance(dm)
+		Expect(dm.UpdateStatus(u, status)).To(Succeed())
+
+		Expect(dm.UpdateStatus(u, &Status{State: Pending})).To(Succeed())
+		Expect(u.Object["status"]).To(Equal(map[string]interface{}{"state": "Pending"}))
+		read, err := dm.GetStatus(u)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read).To(Equal(&Status{State: Pending}))
+	})
+
+	It("rejects instances of other kinds", func() {
+		dm, err := CreateUnstructuredDefinitionManager(config)
+		Expect(err).NotTo(HaveOccurred())
+		other := newUnstructured(config.Dependencies[0].GroupVersionKind())
+
+		_, err = dm.GetStatus(other)
+		Expect(err).To(HaveOccurred())
+		Expect(dm.UpdateStatus(other, status)).NotTo(Succeed())
+		_, err = dm.GetDependencies(ctx, other)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("gets the owner and dependencies named by the fields of the resource", func() {
+		dm, err := CreateUnstructuredDefinitionManager(config)
+		Expect(err).NotTo(HaveOccurred())
+		u := newInstance(dm)
+		Expect(unstructured.SetNestedField(u.Object, "owner", "spec", "ownerRef")).To(Succeed())
+		Expect(unstructured.SetNestedField(u.Object, "other", "spec", "otherRef", "name")).To(Succeed())
+		Expect(unstructured.SetNestedField(u.Object, "elsewhere", "spec", "otherRef", "namespace")).To(Succeed())
+
+		defs, err := dm.GetDependencies(ctx, u)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(defs.Owner.NamespacedName).To(Equal(types.NamespacedName{Namespace: "default", Name: "owner"}))
+		Expect(defs.Owner.InitialInstance.GetObjectKind().GroupVersionKind().Kind).To(Equal("Owner"))
+		Expect(defs.Dependencies).To(HaveLen(1))
+		other := defs.Dependencies[0]
+		Expect(other.NamespacedName).To(Equal(types.NamespacedName{Namespace: "elsewhere", Name: "other"}))
+
+		// the dependency has succeeded once the field at its StatePath is Succeeded
+		dependency := newUnstructured(config.Dependencies[1].GroupVersionKind())
+		Expect(other.SucceededAccessor(dependency)).To(BeFalse())
+		Expect(unstructured.SetNestedField(dependency.Object, "Succeeded", "status", "phase")).To(Succeed())
+		Expect(other.SucceededAccessor(dependency)).To(BeTrue())
+	})
+
+	It("rejects kinds with more than one owner", func() {
+		owners := config
+		owners.Dependencies = []UnstructuredDependencyConfig{config.Dependencies[0], config.Dependencies[0]}
+		_, err := CreateUnstructuredDefinitionManager(owners)
+		Expect(err).To(MatchError("more than one dependency of Thing is declared as its owner"))
+	})
+
+	It("references the owner with its group and version", func() {
+		owner := newUnstructured(config.Dependencies[0].GroupVersionKind())
+		owner.SetName("owner")
+		updater := &instanceUpdater{}
+		updater.setOwnerReferences([]runtime.Object{owner})
+
+		u := &unstructured.Unstructured{}
+		for _, update := range updater.metaUpdates {
+			update(u)
+		}
+		Expect(u.GetOwnerReferences()).To(HaveLen(1))
+		Expect(u.GetOwnerReferences()[0].APIVersion).To(Equal("test.example.com/v1"))
+		Expect(u.GetOwnerReferences()[0].Kind).To(Equal("Owner"))
+		Expect(u.GetOwnerReferences()[0].Name).To(Equal("owner"))
+	})
+
+	It("has no dependencies for empty names", func() {
+		dm, err := CreateUnstructuredDefinitionManager(config)
+		Expect(err).NotTo(HaveOccurred())
+
+		defs, err := dm.GetDependencies(ctx, newInstance(dm))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(defs.Owner).To(BeNil())
+		Expect(defs.Dependencies).To(BeEmpty())
+	})
+})
@@ -0,0 +1,132 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"k8s.io/api/admission/v1beta1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Validator can optionally be implemented by the DefinitionManager or the ResourceManager of a kind,
+// so that invalid resources are rejected when they are created or updated rather than when the ResourceManager fails to apply them.
+// Validation is only called for updates that change the spec, and not for resources that are being deleted
+type Validator interface {
+	// returns an error describing why the new resource is invalid
+	ValidateCreate(ctx context.Context, instance runtime.Object) error
+	// returns an error describing why the updated resource is invalid, for example because it changes an immutable field of oldInstance
+	ValidateUpdate(ctx context.Context, oldInstance runtime.Object, instance runtime.Object) error
+}
+
+// ValidatingWebhook is an admission handler that calls the Validator of a kind
+type ValidatingWebhook struct {
+	Validator         Validator
+	DefinitionManager DefinitionManager
+	decoder           *admission.Decoder
+}
+
+func CreateValidatingWebhook(validator Validator, definitionManager DefinitionManager, scheme *runtime.Scheme) (*ValidatingWebhook, error) {
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		return nil, err
+	}
+	return &ValidatingWebhook{
+		Validator:         validator,
+		DefinitionManager: definitionManager,
+		decoder:           decoder,
+	}, nil
+}
+
+// InjectDecoder is called by the webhook server to set the decoder
+func (w *ValidatingWebhook) InjectDecoder(decoder *admission.Decoder) error {
+	w.decoder = decoder
+	return nil
+}
+
+func (w *ValidatingWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	nn := types.NamespacedName{Namespace: req.Namespace, Name: req.Name}
+	instance := w.DefinitionManager.GetDefinition(ctx, nn).InitialInstance
+	if err := w.decoder.Decode(req, instance); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	switch req.Operation {
+	case v1beta1.Create:
+		if err := w.Validator.ValidateCreate(ctx, instance); err != nil {
+			return admission.Denied(err.Error())
+		}
+	case v1beta1.Update:
+		if metaObject, err := apimeta.Accessor(instance); err == nil && !metaObject.GetDeletionTimestamp().IsZero() {
+			return admission.Allowed("resource is being deleted")
+		}
+		if specUnchanged(req) {
+			return admission.Allowed("spec is unchanged")
+		}
+		oldInstance := w.DefinitionManager.GetDefinition(ctx, nn).InitialInstance
+		if err := w.decoder.DecodeRaw(req.OldObject, oldInstance); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if err := w.Validator.ValidateUpdate(ctx, oldInstance, instance); err != nil {
+			return admission.Denied(err.Error())
+		}
+	}
+	return admission.Allowed("")
+}
+
+// returns the path the validating webhook of the kind is served on, following the Kubebuilder convention
+func validatingWebhookPath(gvk schema.GroupVersionKind) string {
+	return "/validate-" + strings.Replace(gvk.Group, ".", "-", -1) + "-" + gvk.Version + "-" + strings.ToLower(gvk.Kind)
+}
+
+// returns whether an update leaves the spec unchanged, as it does for the updates of the reconciler
+func specUnchanged(req admission.Request) bool {
+	var object, oldObject struct {
+		Spec interface{} `json:"spec"`
+	}
+	if err := json.Unmarshal(req.Object.Raw, &object); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(req.OldObject.Raw, &oldObject); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(object.Spec, oldObject.Spec)
+}
+
+// CheckImmutableFields returns an error naming the fields at the paths (such as spec.location) that differ between the instances.
+// It is intended for implementations of Validator.ValidateUpdate
+func CheckImmutableFields(oldInstance runtime.Object, instance runtime.Object, paths ...string) error {
+	oldObject, err := runtime.DefaultUnstructuredConverter.ToUnstructured(oldInstance)
+	if err != nil {
+		return err
+	}
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(instance)
+	if err != nil {
+		return err
+	}
+	if changed := changedFields(oldObject, object, paths); len(changed) > 0 {
+		return immutableFieldsError(changed)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package reconciler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/operatify/operatify/api/v1alpha1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// a DefinitionManager of ATest, which is its own Validator and Defaulter: the id is required, immutable and defaults to <namespace>/<name>
+type aTestDefinitionManager struct{}
+
+func (aTestDefinitionManager) GetDefinition(ctx context.Context, namespacedName types.NamespacedName) *ResourceDefinition {
+	return &ResourceDefinition{InitialInstance: &v1alpha1.ATest{}}
+}
+
+func (aTestDefinitionManager) GetDependencies(ctx context.Context, thisInstance runtime.Object) (*DependencyDefinitions, error) {
+	return &NoDependencies, nil
+}
+
+func (aTestDefinitionManager) ValidateCreate(ctx context.Context, instance runtime.Object) error {
+	if instance.(*v1alpha1.ATest).Spec.Id == "" {
+		return fmt.Errorf("spec.id is required")
+	}
+	return nil
+}
+
+func (m aTestDefinitionManager) ValidateUpdate(ctx context.Context, oldInstance runtime.Object, instance runtime.Object) error {
+	if err := m.ValidateCreate(ctx, instance); err != nil {
+		return err
+	}
+	return CheckImmutableFields(oldInstance, instance, "spec.id")
+}
+
+func (aTestDefinitionManager) Default(ctx context.Context, instance runtime.Object) error {
+	a := instance.(*v1alpha1.ATest)
+	if a.Spec.Id == "" {
+		a.Spec.Id = a.Namespace + "/" + a.Name
+	}
+	return nil
+}
+
+var _ = Describe("Webhooks", func() {
+
+	scheme := runtime.NewScheme()
+	Expect(v1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	definitionManager := aTestDefinitionManager{}
+
+	aTest := func(id string) *v1alpha1.ATest {
+		return &v1alpha1.ATest{
+			ObjectMeta: metav1.ObjectMeta{Name: "a-test", Namespace: "default"},
+			Spec:       v1alpha1.ASpec{Spec: v1alpha1.Spec{Id: id}},
+		}
+	}
+
+	raw := func(object runtime.Object) runtime.RawExtension {
+		b, err := json.Marshal(object)
+		Expect(err).NotTo(HaveOccurred())
+		return runtime.RawExtension{Raw: b}
+	}
+
+	request := func(operation admissionv1beta1.Operation, object *v1alpha1.ATest, oldObject *v1alpha1.ATest) admission.Request {
+		req := admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+			Operation: operation,
+			Name:      object.Name,
+			Namespace: object.Namespace,
+			Object:    raw(object),
+		}}
+		if oldObject != nil {
+			req.OldObject = raw(oldObject)
+		}
+		return req
+	}
+
+	Context("Default", func() {
+
+		It("patches the defaults of the resource", func() {
+			webhook, err := CreateDefaultingWebhook(definitionManager, definitionManager, scheme)
+			Expect(err).NotTo(HaveOccurred())
+
+			response := webhook.Handle(context.Background(), request(admissionv1beta1.Create, aTest(""), nil))
+			Expect(response.Allowed).To(BeTrue())
+			Expect(response.Patches).To(HaveLen(1))
+			Expect(response.Patches[0].Path).To(Equal("/spec/id"))
+			Expect(response.Patches[0].Value).To(Equal("default/a-test"))
+		})
+
+		It("is served on the path Kubebuilder generates", func() {
+			Expect(defaultingWebhookPath(v1alpha1.GroupVersion.WithKind("ATest"))).To(Equal("/mutate-test-stephenzoio-com-v1alpha1-atest"))
+		})
+	})
+
+	Context("Validate", func() {
+
+		var webhook *ValidatingWebhook
+
+		BeforeEach(func() {
+			var err error
+			webhook, err = CreateValidatingWebhook(definitionManager, definitionManager, scheme)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("rejects creating an invalid resource", func() {
+			response := webhook.Handle(context.Background(), request(admissionv1beta1.Create, aTest(""), nil))
+			Expect(response.Allowed).To(BeFalse())
+			Expect(string(response.Result.Reason)).To(ContainSubstring("spec.id is required"))
+		})
+
+		It("allows creating a valid resource", func() {
+			response := webhook.Handle(context.Background(), request(admissionv1beta1.Create, aTest("a-1"), nil))
+			Expect(response.Allowed).To(BeTrue())
+		})
+
+		It("rejects changing an immutable field", func() {
+			old := aTest("a-1")
+			updated := aTest("a-2")
+
+			response := webhook.Handle(context.Background(), request(admissionv1beta1.Update, updated, old))
+			Expect(response.Allowed).To(BeFalse())
+			Expect(string(response.Result.Reason)).To(ContainSubstring("spec.id"))
+		})
+
+		It("allows updates that leave the spec unchanged", func() {
+			// an invalid resource created before the webhook was enabled can still have its status updated
+			old := aTest("")
+			updated := old.DeepCopy()
+			updated.Status.State = string(Succeeded)
+
+			response := webhook.Handle(context.Background(), request(admissionv1beta1.Update, updated, old))
+			Expect(response.Allowed).To(BeTrue())
+		})
+
+		It("allows updates of resources being deleted", func() {
+			old := aTest("a-1")
+			updated := aTest("a-2")
+			now := metav1.Now()
+			updated.DeletionTimestamp = &now
+
+			response := webhook.Handle(context.Background(), request(admissionv1beta1.Update, updated, old))
+			Expect(response.Allowed).To(BeTrue())
+		})
+
+		It("is served on the path Kubebuilder generates", func() {
+			Expect(validatingWebhookPath(v1alpha1.GroupVersion.WithKind("ATest"))).To(Equal("/validate-test-stephenzoio-com-v1alpha1-atest"))
+		})
+	})
+})
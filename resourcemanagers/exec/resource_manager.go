@@ -0,0 +1,277 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package exec provides a ResourceManager that runs an executable for each operation,
+// passing the resource as JSON on stdin and reading the result as JSON from stdout
+package exec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/operatify/operatify/reconciler"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// the maximum number of bytes of stderr recorded in an event
+const maxEventOutput = 1024
+
+// the Error results of each operation share a name
+const errorResult = string(reconciler.ApplyResultError)
+
+// Config declares the command run for each operation
+type Config struct {
+	Create Command `json:"create"`
+	Update Command `json:"update"`
+	Verify Command `json:"verify"`
+	Delete Command `json:"delete"`
+	// The maximum number of milliseconds a command may run for, unless the Command sets its own (defaults to 30000)
+	Timeout int `json:"timeout,omitempty"`
+	// Added to the environment of the operator for each command, in the form NAME=value
+	Env []string `json:"env,omitempty"`
+}
+
+type Command struct {
+	Path string   `json:"path"`
+	Args []string `json:"args,omitempty"`
+	// The maximum number of milliseconds the command may run for
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// Request is the JSON document written to the stdin of the command
+type Request struct {
+	// One of Create, Update, Verify and Delete, so a single script can handle every operation
+	Operation      string                 `json:"operation"`
+	Instance       map[string]interface{} `json:"instance"`
+	Dependencies   []Dependency           `json:"dependencies,omitempty"`
+	OperationToken string                 `json:"operationToken,omitempty"`
+}
+
+type Dependency struct {
+	Namespace string                 `json:"namespace"`
+	Name      string                 `json:"name"`
+	Object    map[string]interface{} `json:"object"`
+}
+
+// Result is the JSON document the command writes to stdout
+type Result struct {
+	// The ApplyResult, VerifyResult or DeleteResult of the operation
+	Result string      `json:"result"`
+	Status interface{} `json:"status,omitempty"`
+	// An error message. The operation fails with this error, whatever the result
+	Error string `json:"error,omitempty"`
+}
+
+// ResourceManager is a reconciler.ResourceManager that runs the commands of a Config
+type ResourceManager struct {
+	Config   Config
+	Logger   logr.Logger
+	Recorder record.EventRecorder
+}
+
+func CreateResourceManager(config Config, logger logr.Logger, recorder record.EventRecorder) (*ResourceManager, error) {
+	for name, command := range map[string]Command{
+		"create": config.Create,
+		"update": config.Update,
+		"verify": config.Verify,
+		"delete": config.Delete,
+	} {
+		if command.Path == "" {
+			return nil, fmt.Errorf("no path defined for the %s command", name)
+		}
+	}
+	return &ResourceManager{
+		Config:   config,
+		Logger:   logger,
+		Recorder: recorder,
+	}, nil
+}
+
+func (r *ResourceManager) Create(ctx context.Context, s reconciler.ResourceSpec) (reconciler.ApplyResponse, error) {
+	result, err := r.run(ctx, "Create", r.Config.Create, s)
+	return reconciler.ApplyResponse{
+		Result: reconciler.ApplyResult(result.Result),
+		Status: result.Status,
+	}, err
+}
+
+func (r *ResourceManager) Update(ctx context.Context, s reconciler.ResourceSpec) (reconciler.ApplyResponse, error) {
+	result, err := r.run(ctx, "Update", r.Config.Update, s)
+	return reconciler.ApplyResponse{
+		Result: reconciler.ApplyResult(result.Result),
+		Status: result.Status,
+	}, err
+}
+
+func (r *ResourceManager) Verify(ctx context.Context, s reconciler.ResourceSpec) (reconciler.VerifyResponse, error) {
+	result, err := r.run(ctx, "Verify", r.Config.Verify, s)
+	return reconciler.VerifyResponse{
+		Result: reconciler.VerifyResult(result.Result),
+		Status: result.Status,
+	}, err
+}
+
+func (r *ResourceManager) Delete(ctx context.Context, s reconciler.ResourceSpec) (reconciler.DeleteResult, error) {
+	result, err := r.run(ctx, "Delete", r.Config.Delete, s)
+	return reconciler.DeleteResult(result.Result), err
+}
+
+// runs the command, returning an Error result if it fails, times out or writes an invalid result
+func (r *ResourceManager) run(ctx context.Context, operation string, command Command, s reconciler.ResourceSpec) (*Result, error) {
+	failed := &Result{Result: errorResult}
+	request, err := encodeRequest(operation, s)
+	if err != nil {
+		return failed, err
+	}
+
+	cmd := osexec.Command(command.Path, command.Args...)
+	cmd.Env = append(os.Environ(), r.Config.Env...)
+	cmd.Stdin = bytes.NewReader(request)
+	var stdout, stderr syncBuffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	setProcessGroup(cmd)
+
+	r.Logger.Info(fmt.Sprintf("Running %s for %s", command.Path, operation))
+	result, err := r.runCommand(ctx, operation, command, cmd, &stdout)
+	r.reportStderr(s.Instance, operation, stderr.String(), err != nil)
+	if result == nil {
+		return failed, err
+	}
+	return result, err
+}
+
+// runs the command and reads its result. returns a nil result if the command fails or writes an invalid result
+func (r *ResourceManager) runCommand(ctx context.Context, operation string, command Command, cmd *osexec.Cmd, stdout *syncBuffer) (*Result, error) {
+	if err := r.runWithTimeout(ctx, cmd, r.getTimeout(command)); err != nil {
+		return nil, fmt.Errorf("%s of resource failed: %v", operation, err)
+	}
+	result := &Result{}
+	if err := json.Unmarshal(stdout.Bytes(), result); err != nil {
+		return nil, fmt.Errorf("invalid result from %s: %v", command.Path, err)
+	}
+	if result.Error != "" {
+		return result, errors.New(result.Error)
+	}
+	return result, nil
+}
+
+// runs the command, killing its process group once the timeout has passed or the context is done.
+// this doesn't wait for the output of the command to be closed after killing it, as processes that left its group may still hold it open
+func (r *ResourceManager) runWithTimeout(ctx context.Context, cmd *osexec.Cmd, timeout time.Duration) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		_ = killProcessGroup(cmd)
+		return fmt.Errorf("%s timed out after %s", cmd.Path, timeout)
+	case <-ctx.Done():
+		_ = killProcessGroup(cmd)
+		return ctx.Err()
+	}
+}
+
+func (r *ResourceManager) getTimeout(command Command) time.Duration {
+	millis := command.Timeout
+	if millis == 0 {
+		millis = r.Config.Timeout
+	}
+	if millis == 0 {
+		millis = 30000
+	}
+	return time.Duration(millis) * time.Millisecond
+}
+
+// reports the output of the command on stderr, if there is any. If the operation failed it is recorded as a warning event on the resource,
+// and otherwise it is only logged, so that diagnostics written by a command that succeeds don't add an event on every Verify
+func (r *ResourceManager) reportStderr(instance runtime.Object, operation string, stderr string, failed bool) {
+	stderr = strings.TrimSpace(stderr)
+	if stderr == "" {
+		return
+	}
+	if len(stderr) > maxEventOutput {
+		stderr = "..." + stderr[len(stderr)-maxEventOutput:]
+	}
+	if !failed || r.Recorder == nil {
+		r.Logger.Info(fmt.Sprintf("%s wrote to stderr: %s", operation, stderr))
+		return
+	}
+	r.Recorder.Event(instance, corev1.EventTypeWarning, operation, stderr)
+}
+
+func encodeRequest(operation string, s reconciler.ResourceSpec) ([]byte, error) {
+	instance, err := runtime.DefaultUnstructuredConverter.ToUnstructured(s.Instance)
+	if err != nil {
+		return nil, err
+	}
+	request := Request{
+		Operation:      operation,
+		Instance:       instance,
+		OperationToken: s.OperationToken,
+	}
+	for name, dep := range s.Dependencies {
+		object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(dep)
+		if err != nil {
+			return nil, err
+		}
+		request.Dependencies = append(request.Dependencies, Dependency{
+			Namespace: name.Namespace,
+			Name:      name.Name,
+			Object:    object,
+		})
+	}
+	return json.Marshal(request)
+}
+
+// a bytes.Buffer that can be read while a killed command may still be writing to it
+type syncBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]byte{}, b.buffer.Bytes()...)
+}
+
+func (b *syncBuffer) String() string {
+	return string(b.Bytes())
+}
@@ -0,0 +1,152 @@
+package exec
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	api "github.com/operatify/operatify/api/v1alpha1"
+	"github.com/operatify/operatify/reconciler"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Exec ResourceManager", func() {
+
+	var dir string
+	var recorder *record.FakeRecorder
+	ctx := context.Background()
+
+	resourceSpec := reconciler.ResourceSpec{
+		Instance: &api.ATest{
+			ObjectMeta: v1.ObjectMeta{Name: "a", Namespace: "default"},
+			Spec:       api.ASpec{Spec: api.Spec{Id: "a-id"}},
+		},
+		OperationToken: "token",
+	}
+
+	// writes a shell script to the temporary directory, returning a Command running it
+	script := func(name string, body string) Command {
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755)).To(Succeed())
+		return Command{Path: path}
+	}
+
+	createResourceManager := func(command Command, timeout int) *ResourceManager {
+		r, err := CreateResourceManager(Config{
+			Create:  command,
+			Update:  command,
+			Verify:  command,
+			Delete:  command,
+			Timeout: timeout,
+		}, ctrl.Log, recorder)
+		Expect(err).NotTo(HaveOccurred())
+		return r
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "exec")
+		Expect(err).NotTo(HaveOccurred())
+		recorder = record.NewFakeRecorder(10)
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	It("passes the resource spec on stdin", func() {
+		requestFile := filepath.Join(dir, "request.json")
+		r := createResourceManager(script("create", `cat > `+requestFile+`
+echo '{"result": "AwaitingVerification"}'`), 0)
+
+		applyResponse, err := r.Create(ctx, resourceSpec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applyResponse.Result).To(Equal(reconciler.ApplyResultAwaitingVerification))
+
+		b, err := ioutil.ReadFile(requestFile)
+		Expect(err).NotTo(HaveOccurred())
+		request := Request{}
+		Expect(json.Unmarshal(b, &request)).To(Succeed())
+		Expect(request.Operation).To(Equal("Create"))
+		Expect(request.OperationToken).To(Equal("token"))
+		Expect(request.Instance["spec"]).To(HaveKeyWithValue("id", "a-id"))
+	})
+
+	It("returns the result and status payload written to stdout", func() {
+		r := createResourceManager(script("verify", `echo '{"result": "Ready", "status": {"endpoint": "https://a"}}'`), 0)
+
+		verifyResponse, err := r.Verify(ctx, resourceSpec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(verifyResponse.Result).To(Equal(reconciler.VerifyResultReady))
+		Expect(verifyResponse.Status).To(Equal(map[string]interface{}{"endpoint": "https://a"}))
+	})
+
+	It("returns the error written to stdout", func() {
+		r := createResourceManager(script("delete", `echo '{"result": "Error", "error": "quota exceeded"}'`), 0)
+
+		deleteResult, err := r.Delete(ctx, resourceSpec)
+		Expect(err).To(MatchError("quota exceeded"))
+		Expect(deleteResult).To(Equal(reconciler.DeleteError))
+	})
+
+	It("records stderr of a failed command in a warning event", func() {
+		r := createResourceManager(script("update", `echo 'permission denied' >&2
+exit 1`), 0)
+
+		applyResponse, err := r.Update(ctx, resourceSpec)
+		Expect(err).To(HaveOccurred())
+		Expect(applyResponse.Result).To(Equal(reconciler.ApplyResultError))
+		Expect(recorder.Events).To(Receive(Equal("Warning Update permission denied")))
+	})
+
+	It("only logs stderr of a command that succeeds", func() {
+		r := createResourceManager(script("verify", `echo 'checking' >&2
+echo '{"result": "Ready"}'`), 0)
+
+		verifyResponse, err := r.Verify(ctx, resourceSpec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(verifyResponse.Result).To(Equal(reconciler.VerifyResultReady))
+		Expect(recorder.Events).NotTo(Receive())
+	})
+
+	It("records stderr of a command that writes an invalid result", func() {
+		r := createResourceManager(script("create", `echo 'no result' >&2`), 0)
+
+		_, err := r.Create(ctx, resourceSpec)
+		Expect(err).To(HaveOccurred())
+		Expect(recorder.Events).To(Receive(Equal("Warning Create no result")))
+	})
+
+	It("kills the processes started by commands that time out", func() {
+		aliveFile := filepath.Join(dir, "alive")
+		r := createResourceManager(script("delete", `(sleep 1; touch `+aliveFile+`) &
+sleep 10`), 200)
+
+		_, err := r.Delete(ctx, resourceSpec)
+		Expect(err).To(HaveOccurred())
+		Consistently(func() bool {
+			_, err := os.Stat(aliveFile)
+			return os.IsNotExist(err)
+		}, 2*time.Second, 100*time.Millisecond).Should(BeTrue())
+	})
+
+	It("kills commands that time out", func() {
+		r := createResourceManager(script("verify", `echo 'waiting' >&2
+sleep 10`), 200)
+
+		start := time.Now()
+		verifyResponse, err := r.Verify(ctx, resourceSpec)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("timed out"))
+		Expect(verifyResponse.Result).To(Equal(reconciler.VerifyResultError))
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+		Expect(recorder.Events).To(Receive(Equal("Warning Verify waiting")))
+	})
+})
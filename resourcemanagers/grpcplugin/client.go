@@ -0,0 +1,215 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package grpcplugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/operatify/operatify/reconciler"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const unixPrefix = "unix:"
+
+// the Error results of each operation share a name
+const errorResult = string(reconciler.ApplyResultError)
+
+// the time in milliseconds a call to the plugin may take, if no Timeout is set
+const defaultTimeout = 30000
+
+// ResourceManager is a reconciler.ResourceManager that calls a plugin over gRPC.
+// It implements OperationPoller and OperationDeleter, calling Delete instead of DeleteWithOperation
+// if the plugin doesn't implement it
+type ResourceManager struct {
+	// Used to set the apiVersion and kind of typed resources, which are often missing from objects read through a client
+	Scheme *runtime.Scheme
+	// The time in milliseconds a call to the plugin may take. Defaults to 30 seconds
+	Timeout int
+	conn    *grpc.ClientConn
+}
+
+// CreateResourceManager connects to the plugin at the address, either host:port or unix:<path> for a Unix socket.
+// The connection is insecure unless DialOptions are given
+func CreateResourceManager(address string, scheme *runtime.Scheme, opts ...grpc.DialOption) (*ResourceManager, error) {
+	if len(opts) == 0 {
+		opts = []grpc.DialOption{grpc.WithInsecure()}
+	}
+	if strings.HasPrefix(address, unixPrefix) {
+		address = strings.TrimPrefix(strings.TrimPrefix(address, unixPrefix), "//")
+		opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, path string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		}))
+	}
+	conn, err := grpc.Dial(address, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &ResourceManager{Scheme: scheme, conn: conn}, nil
+}
+
+// Close closes the connection to the plugin
+func (r *ResourceManager) Close() error {
+	return r.conn.Close()
+}
+
+func (r *ResourceManager) Create(ctx context.Context, s reconciler.ResourceSpec) (reconciler.ApplyResponse, error) {
+	response, err := r.invoke(ctx, methodCreate, s)
+	return reconciler.ApplyResponse{
+		Result:    reconciler.ApplyResult(response.Result),
+		Status:    response.Status,
+		Operation: response.operationHandle(),
+	}, err
+}
+
+func (r *ResourceManager) Update(ctx context.Context, s reconciler.ResourceSpec) (reconciler.ApplyResponse, error) {
+	response, err := r.invoke(ctx, methodUpdate, s)
+	return reconciler.ApplyResponse{
+		Result:    reconciler.ApplyResult(response.Result),
+		Status:    response.Status,
+		Operation: response.operationHandle(),
+	}, err
+}
+
+func (r *ResourceManager) Verify(ctx context.Context, s reconciler.ResourceSpec) (reconciler.VerifyResponse, error) {
+	response, err := r.invoke(ctx, methodVerify, s)
+	return reconciler.VerifyResponse{
+		Result: reconciler.VerifyResult(response.Result),
+		Status: response.Status,
+	}, err
+}
+
+func (r *ResourceManager) Delete(ctx context.Context, s reconciler.ResourceSpec) (reconciler.DeleteResult, error) {
+	response, err := r.invoke(ctx, methodDelete, s)
+	return reconciler.DeleteResult(response.Result), err
+}
+
+func (r *ResourceManager) PollOperation(ctx context.Context, s reconciler.ResourceSpec, handle reconciler.OperationHandle) (reconciler.OperationResponse, error) {
+	response, err := r.invokeWithOperation(ctx, methodPollOperation, s, &Operation{Id: handle.ID, Type: string(handle.Type)})
+	return reconciler.OperationResponse{
+		Result:  reconciler.OperationResult(response.Result),
+		Message: response.Message,
+	}, err
+}
+
+func (r *ResourceManager) DeleteWithOperation(ctx context.Context, s reconciler.ResourceSpec) (reconciler.DeleteResponse, error) {
+	response, err := r.invoke(ctx, methodDeleteWithOperation, s)
+	if status.Code(err) == codes.Unimplemented {
+		deleteResult, err := r.Delete(ctx, s)
+		return reconciler.DeleteResponse{Result: deleteResult}, err
+	}
+	return reconciler.DeleteResponse{
+		Result:    reconciler.DeleteResult(response.Result),
+		Operation: response.operationHandle(),
+	}, err
+}
+
+// calls the method of the plugin. failures to make the call are returned as an Error result
+func (r *ResourceManager) invoke(ctx context.Context, method string, s reconciler.ResourceSpec) (*Response, error) {
+	return r.invokeWithOperation(ctx, method, s, nil)
+}
+
+func (r *ResourceManager) invokeWithOperation(ctx context.Context, method string, s reconciler.ResourceSpec, operation *Operation) (*Response, error) {
+	failed := &Response{Result: errorResult}
+	request, err := r.encodeRequest(s, operation)
+	if err != nil {
+		return failed, err
+	}
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Millisecond)
+	defer cancel()
+	out := &wrappers.BytesValue{}
+	if err := r.conn.Invoke(ctx, fullMethodName(method), &wrappers.BytesValue{Value: request}, out); err != nil {
+		return failed, err
+	}
+	response := &Response{}
+	if err := json.Unmarshal(out.Value, response); err != nil {
+		return failed, err
+	}
+	if response.Error != "" {
+		return response, errors.New(response.Error)
+	}
+	return response, nil
+}
+
+func (r *ResourceManager) encodeRequest(s reconciler.ResourceSpec, operation *Operation) ([]byte, error) {
+	instance, err := r.encodeObject(s.Instance)
+	if err != nil {
+		return nil, err
+	}
+	request := Request{
+		Instance:       instance,
+		OperationToken: s.OperationToken,
+		Operation:      operation,
+	}
+	if config := s.ProviderConfig; config != nil {
+		request.ProviderConfig = &ProviderConfig{
+			Kind:        config.Kind,
+			Namespace:   config.Namespace,
+			Name:        config.Name,
+			Endpoint:    config.Endpoint,
+			Spec:        config.Spec,
+			Credentials: config.Credentials,
+		}
+	}
+	for name, dep := range s.Dependencies {
+		object, err := r.encodeObject(dep)
+		if err != nil {
+			return nil, err
+		}
+		request.Dependencies = append(request.Dependencies, Dependency{
+			Namespace: name.Namespace,
+			Name:      name.Name,
+			Object:    object,
+		})
+	}
+	return json.Marshal(request)
+}
+
+func (r *ResourceManager) encodeObject(object runtime.Object) (map[string]interface{}, error) {
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	if err != nil {
+		return nil, err
+	}
+	if m["kind"] == nil && r.Scheme != nil {
+		if gvk, err := apiutil.GVKForObject(object, r.Scheme); err == nil {
+			m["apiVersion"], m["kind"] = gvk.GroupVersion().String(), gvk.Kind
+		}
+	}
+	return m, nil
+}
+
+func (response *Response) operationHandle() *reconciler.OperationHandle {
+	if response.Operation == nil {
+		return nil
+	}
+	return &reconciler.OperationHandle{
+		ID:   response.Operation.Id,
+		Type: reconciler.OperationType(response.Operation.Type),
+	}
+}
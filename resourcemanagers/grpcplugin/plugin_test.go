@@ -0,0 +1,256 @@
+package grpcplugin
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	api "github.com/operatify/operatify/api/v1alpha1"
+	"github.com/operatify/operatify/reconciler"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// records the specs it is called with, and returns the configured responses
+type recordingResourceManager struct {
+	specs          []reconciler.ResourceSpec
+	applyResponse  reconciler.ApplyResponse
+	verifyResponse reconciler.VerifyResponse
+	deleteResult   reconciler.DeleteResult
+	err            error
+	// if set, Verify blocks until the context is done
+	block bool
+}
+
+func (r *recordingResourceManager) Create(ctx context.Context, s reconciler.ResourceSpec) (reconciler.ApplyResponse, error) {
+	r.specs = append(r.specs, s)
+	return r.applyResponse, r.err
+}
+
+func (r *recordingResourceManager) Update(ctx context.Context, s reconciler.ResourceSpec) (reconciler.ApplyResponse, error) {
+	r.specs = append(r.specs, s)
+	return r.applyResponse, r.err
+}
+
+func (r *recordingResourceManager) Verify(ctx context.Context, s reconciler.ResourceSpec) (reconciler.VerifyResponse, error) {
+	r.specs = append(r.specs, s)
+	if r.block {
+		<-ctx.Done()
+		return reconciler.VerifyError, ctx.Err()
+	}
+	return r.verifyResponse, r.err
+}
+
+func (r *recordingResourceManager) Delete(ctx context.Context, s reconciler.ResourceSpec) (reconciler.DeleteResult, error) {
+	r.specs = append(r.specs, s)
+	return r.deleteResult, r.err
+}
+
+// a plugin that polls and deletes with operations
+type operationResourceManager struct {
+	recordingResourceManager
+	handles []reconciler.OperationHandle
+}
+
+func (r *operationResourceManager) PollOperation(ctx context.Context, s reconciler.ResourceSpec, handle reconciler.OperationHandle) (reconciler.OperationResponse, error) {
+	r.handles = append(r.handles, handle)
+	return reconciler.OperationFailedWithMessage("quota exceeded"), nil
+}
+
+func (r *operationResourceManager) DeleteWithOperation(ctx context.Context, s reconciler.ResourceSpec) (reconciler.DeleteResponse, error) {
+	r.specs = append(r.specs, s)
+	return reconciler.DeleteResponse{
+		Result:    reconciler.DeleteAwaitingVerification,
+		Operation: &reconciler.OperationHandle{ID: "op", Type: reconciler.OperationDelete},
+	}, nil
+}
+
+var _ = Describe("gRPC plugin", func() {
+
+	var dir string
+	var socket string
+	var plugin *recordingResourceManager
+	var server *Server
+	var client *ResourceManager
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	_ = api.AddToScheme(scheme)
+
+	aTest := &api.ATest{
+		ObjectMeta: v1.ObjectMeta{Name: "a", Namespace: "default"},
+		Spec:       api.ASpec{Spec: api.Spec{Id: "a-id"}},
+	}
+	dependency := &unstructured.Unstructured{}
+	dependency.SetAPIVersion("example.com/v1")
+	dependency.SetKind("Other")
+	dependency.SetName("other")
+
+	resourceSpec := reconciler.ResourceSpec{
+		Instance: aTest,
+		Dependencies: map[types.NamespacedName]runtime.Object{
+			{Namespace: "default", Name: "other"}: dependency,
+		},
+		OperationToken: "token",
+		ProviderConfig: &reconciler.ProviderConfig{
+			Kind:           "ClusterProviderConfig",
+			NamespacedName: types.NamespacedName{Name: "west"},
+			Endpoint:       "https://west.example.com",
+			Spec:           map[string]interface{}{"region": "west"},
+			Credentials:    map[string][]byte{"token": []byte("secret")},
+		},
+	}
+
+	// serves the plugin on another socket, with only the given methods
+	serveMethods := func(resourceManager reconciler.ResourceManager, methods ...string) (*grpc.Server, *ResourceManager) {
+		desc := serviceDesc()
+		desc.Methods = nil
+		for _, method := range methods {
+			desc.Methods = append(desc.Methods, grpc.MethodDesc{MethodName: method, Handler: unaryHandler(method)})
+		}
+		grpcServer := grpc.NewServer()
+		grpcServer.RegisterService(&desc, CreateServer(resourceManager, scheme))
+		socket := filepath.Join(dir, "methods.sock")
+		listener, err := net.Listen("unix", socket)
+		Expect(err).NotTo(HaveOccurred())
+		go func() { _ = grpcServer.Serve(listener) }()
+		methodsClient, err := CreateResourceManager("unix:"+socket, scheme)
+		Expect(err).NotTo(HaveOccurred())
+		return grpcServer, methodsClient
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "grpcplugin")
+		Expect(err).NotTo(HaveOccurred())
+		socket = filepath.Join(dir, "plugin.sock")
+		listener, err := net.Listen("unix", socket)
+		Expect(err).NotTo(HaveOccurred())
+
+		plugin = &recordingResourceManager{}
+		server = CreateServer(plugin, scheme)
+		go func() { _ = server.Serve(listener) }()
+
+		client, err = CreateResourceManager("unix:"+socket, scheme)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		_ = client.Close()
+		server.Stop()
+		_ = os.RemoveAll(dir)
+	})
+
+	It("passes the resource spec to the plugin", func() {
+		plugin.applyResponse = reconciler.ApplyAwaitingVerification
+
+		applyResponse, err := client.Create(ctx, resourceSpec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applyResponse.Result).To(Equal(reconciler.ApplyResultAwaitingVerification))
+
+		Expect(plugin.specs).To(HaveLen(1))
+		received := plugin.specs[0]
+		Expect(received.OperationToken).To(Equal("token"))
+		instance, ok := received.Instance.(*api.ATest)
+		Expect(ok).To(BeTrue())
+		Expect(instance.Name).To(Equal("a"))
+		Expect(instance.Spec.Id).To(Equal("a-id"))
+		other, ok := received.Dependencies[types.NamespacedName{Namespace: "default", Name: "other"}].(*unstructured.Unstructured)
+		Expect(ok).To(BeTrue())
+		Expect(other.GetKind()).To(Equal("Other"))
+		Expect(received.ProviderConfig).To(Equal(resourceSpec.ProviderConfig))
+	})
+
+	It("returns the results, status payloads and operations of the plugin", func() {
+		plugin.applyResponse = reconciler.ApplyResponse{
+			Result:    reconciler.ApplyResultAwaitingVerification,
+			Operation: &reconciler.OperationHandle{ID: "op", Type: reconciler.OperationUpdate},
+		}
+		applyResponse, err := client.Update(ctx, resourceSpec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applyResponse.Operation).To(Equal(&reconciler.OperationHandle{ID: "op", Type: reconciler.OperationUpdate}))
+
+		plugin.verifyResponse = reconciler.VerifyReadyWithStatus(map[string]string{"endpoint": "https://a"})
+		verifyResponse, err := client.Verify(ctx, resourceSpec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(verifyResponse.Result).To(Equal(reconciler.VerifyResultReady))
+		Expect(verifyResponse.Status).To(Equal(map[string]interface{}{"endpoint": "https://a"}))
+
+		plugin.deleteResult = reconciler.DeleteAlreadyDeleted
+		deleteResult, err := client.Delete(ctx, resourceSpec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deleteResult).To(Equal(reconciler.DeleteAlreadyDeleted))
+	})
+
+	It("returns the errors of the plugin", func() {
+		plugin.verifyResponse = reconciler.VerifyError
+		plugin.err = errors.New("backend unavailable")
+
+		verifyResponse, err := client.Verify(ctx, resourceSpec)
+		Expect(err).To(MatchError("backend unavailable"))
+		Expect(verifyResponse.Result).To(Equal(reconciler.VerifyResultError))
+	})
+
+	It("polls and deletes with the operations of the plugin", func() {
+		operationPlugin := &operationResourceManager{}
+		server.Stop()
+		server = CreateServer(operationPlugin, scheme)
+		Expect(os.Remove(socket)).To(Or(Succeed(), WithTransform(os.IsNotExist, BeTrue())))
+		listener, err := net.Listen("unix", socket)
+		Expect(err).NotTo(HaveOccurred())
+		go func() { _ = server.Serve(listener) }()
+
+		deleteResponse, err := client.DeleteWithOperation(ctx, resourceSpec)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deleteResponse).To(Equal(reconciler.DeleteResponse{
+			Result:    reconciler.DeleteAwaitingVerification,
+			Operation: &reconciler.OperationHandle{ID: "op", Type: reconciler.OperationDelete},
+		}))
+
+		handle := reconciler.OperationHandle{ID: "op", Type: reconciler.OperationDelete}
+		Expect(client.PollOperation(ctx, resourceSpec, handle)).To(Equal(reconciler.OperationFailedWithMessage("quota exceeded")))
+		Expect(operationPlugin.handles).To(Equal([]reconciler.OperationHandle{handle}))
+	})
+
+	It("deletes without an operation if the plugin doesn't implement DeleteWithOperation", func() {
+		plugin.deleteResult = reconciler.DeleteAlreadyDeleted
+		Expect(client.DeleteWithOperation(ctx, resourceSpec)).To(Equal(reconciler.DeleteResponse{Result: reconciler.DeleteAlreadyDeleted}))
+
+		_, err := client.PollOperation(ctx, resourceSpec, reconciler.OperationHandle{ID: "op"})
+		Expect(status.Code(err)).To(Equal(codes.Unimplemented))
+
+		// a plugin that doesn't serve the method at all
+		grpcServer, methodsClient := serveMethods(plugin, methodCreate, methodUpdate, methodVerify, methodDelete)
+		defer grpcServer.Stop()
+		defer methodsClient.Close()
+		Expect(methodsClient.DeleteWithOperation(ctx, resourceSpec)).To(Equal(reconciler.DeleteResponse{Result: reconciler.DeleteAlreadyDeleted}))
+		Expect(plugin.specs).To(HaveLen(2))
+	})
+
+	It("times out calls to the plugin", func() {
+		plugin.block = true
+		client.Timeout = 50
+
+		verifyResponse, err := client.Verify(ctx, resourceSpec)
+		Expect(status.Code(err)).To(Equal(codes.DeadlineExceeded))
+		Expect(verifyResponse.Result).To(Equal(reconciler.VerifyResultError))
+	})
+
+	It("returns an Error result if the plugin cannot be reached", func() {
+		server.Stop()
+
+		deleteResult, err := client.Delete(ctx, resourceSpec)
+		Expect(err).To(HaveOccurred())
+		Expect(deleteResult).To(Equal(reconciler.DeleteError))
+	})
+})
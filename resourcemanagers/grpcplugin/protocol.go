@@ -0,0 +1,125 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package grpcplugin runs ResourceManagers out of process, as gRPC services implementing the protocol in resource_manager.proto.
+// The ResourceManager in this package is the client used by the operator, and Server serves a Go ResourceManager as a plugin
+package grpcplugin
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"google.golang.org/grpc"
+)
+
+const serviceName = "operatify.plugin.v1.ResourceManager"
+
+// The names of the methods of the service, each mirroring the method of the ResourceManager with the same name
+const (
+	methodCreate = "Create"
+	methodUpdate = "Update"
+	methodVerify = "Verify"
+	methodDelete = "Delete"
+	// the methods of the optional OperationPoller and OperationDeleter interfaces
+	methodPollOperation       = "PollOperation"
+	methodDeleteWithOperation = "DeleteWithOperation"
+)
+
+// Request is the JSON document sent to each method
+type Request struct {
+	Instance       map[string]interface{} `json:"instance"`
+	Dependencies   []Dependency           `json:"dependencies,omitempty"`
+	OperationToken string                 `json:"operationToken,omitempty"`
+	ProviderConfig *ProviderConfig        `json:"providerConfig,omitempty"`
+	// The operation to poll, for PollOperation
+	Operation *Operation `json:"operation,omitempty"`
+}
+
+type Dependency struct {
+	Namespace string                 `json:"namespace"`
+	Name      string                 `json:"name"`
+	Object    map[string]interface{} `json:"object"`
+}
+
+type ProviderConfig struct {
+	Kind        string                 `json:"kind"`
+	Namespace   string                 `json:"namespace,omitempty"`
+	Name        string                 `json:"name"`
+	Endpoint    string                 `json:"endpoint,omitempty"`
+	Spec        map[string]interface{} `json:"spec,omitempty"`
+	Credentials map[string][]byte      `json:"credentials,omitempty"`
+}
+
+// Response is the JSON document returned by each method
+type Response struct {
+	Result    string      `json:"result"`
+	Status    interface{} `json:"status,omitempty"`
+	Operation *Operation  `json:"operation,omitempty"`
+	// The message of the OperationResult returned by PollOperation
+	Message string `json:"message,omitempty"`
+	// The message of the error returned by the ResourceManager
+	Error string `json:"error,omitempty"`
+}
+
+type Operation struct {
+	Id   string `json:"id"`
+	Type string `json:"type"`
+}
+
+// the handler of a method, which is passed the JSON request and returns the JSON response
+type methodHandler = func(ctx context.Context, request []byte) ([]byte, error)
+
+// the equivalent of the service descriptor protoc would generate from resource_manager.proto
+func serviceDesc() grpc.ServiceDesc {
+	var methods []grpc.MethodDesc
+	for _, name := range []string{methodCreate, methodUpdate, methodVerify, methodDelete, methodPollOperation, methodDeleteWithOperation} {
+		methods = append(methods, grpc.MethodDesc{MethodName: name, Handler: unaryHandler(name)})
+	}
+	return grpc.ServiceDesc{
+		ServiceName: serviceName,
+		HandlerType: (*interface{})(nil),
+		Methods:     methods,
+		Streams:     []grpc.StreamDesc{},
+		Metadata:    "resource_manager.proto",
+	}
+}
+
+func unaryHandler(method string) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		in := &wrappers.BytesValue{}
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			out, err := srv.(*Server).handlers[method](ctx, req.(*wrappers.BytesValue).Value)
+			if err != nil {
+				return nil, err
+			}
+			return &wrappers.BytesValue{Value: out}, nil
+		}
+		if interceptor == nil {
+			return handler(ctx, in)
+		}
+		info := &grpc.UnaryServerInfo{
+			Server:     srv,
+			FullMethod: fullMethodName(method),
+		}
+		return interceptor(ctx, in, info, handler)
+	}
+}
+
+func fullMethodName(method string) string {
+	return "/" + serviceName + "/" + method
+}
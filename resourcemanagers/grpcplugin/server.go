@@ -0,0 +1,205 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package grpcplugin
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+
+	"github.com/operatify/operatify/reconciler"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Server serves a ResourceManager written in Go as a plugin.
+// PollOperation is unimplemented unless the ResourceManager implements OperationPoller,
+// and DeleteWithOperation calls Delete unless it implements OperationDeleter
+type Server struct {
+	ResourceManager reconciler.ResourceManager
+	// Resources of kinds registered in the Scheme are passed to the ResourceManager as typed objects,
+	// and any others as *unstructured.Unstructured. The Scheme may be nil
+	Scheme     *runtime.Scheme
+	grpcServer *grpc.Server
+	handlers   map[string]methodHandler
+}
+
+func CreateServer(resourceManager reconciler.ResourceManager, scheme *runtime.Scheme, opts ...grpc.ServerOption) *Server {
+	s := &Server{
+		ResourceManager: resourceManager,
+		Scheme:          scheme,
+		grpcServer:      grpc.NewServer(opts...),
+	}
+	s.handlers = map[string]methodHandler{
+		methodCreate: s.create,
+		methodUpdate: s.update,
+		methodVerify: s.verify,
+		methodDelete: s.delete,
+
+		methodPollOperation:       s.pollOperation,
+		methodDeleteWithOperation: s.deleteWithOperation,
+	}
+	desc := serviceDesc()
+	s.grpcServer.RegisterService(&desc, s)
+	return s
+}
+
+// Serve serves requests on the listener until Stop is called
+func (s *Server) Serve(listener net.Listener) error {
+	return s.grpcServer.Serve(listener)
+}
+
+// Stop stops the server once the requests in progress have completed
+func (s *Server) Stop() {
+	s.grpcServer.GracefulStop()
+}
+
+func (s *Server) create(ctx context.Context, request []byte) ([]byte, error) {
+	spec, _, err := s.decodeRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	applyResponse, err := s.ResourceManager.Create(ctx, spec)
+	return encodeResponse(string(applyResponse.Result), applyResponse.Status, applyResponse.Operation, err)
+}
+
+func (s *Server) update(ctx context.Context, request []byte) ([]byte, error) {
+	spec, _, err := s.decodeRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	applyResponse, err := s.ResourceManager.Update(ctx, spec)
+	return encodeResponse(string(applyResponse.Result), applyResponse.Status, applyResponse.Operation, err)
+}
+
+func (s *Server) verify(ctx context.Context, request []byte) ([]byte, error) {
+	spec, _, err := s.decodeRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	verifyResponse, err := s.ResourceManager.Verify(ctx, spec)
+	return encodeResponse(string(verifyResponse.Result), verifyResponse.Status, nil, err)
+}
+
+func (s *Server) delete(ctx context.Context, request []byte) ([]byte, error) {
+	spec, _, err := s.decodeRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	deleteResult, err := s.ResourceManager.Delete(ctx, spec)
+	return encodeResponse(string(deleteResult), nil, nil, err)
+}
+
+func (s *Server) pollOperation(ctx context.Context, request []byte) ([]byte, error) {
+	poller, ok := s.ResourceManager.(reconciler.OperationPoller)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, "the ResourceManager doesn't implement OperationPoller")
+	}
+	spec, operation, err := s.decodeRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	if operation == nil {
+		return nil, status.Error(codes.InvalidArgument, "no operation to poll")
+	}
+	handle := reconciler.OperationHandle{ID: operation.Id, Type: reconciler.OperationType(operation.Type)}
+	operationResponse, err := poller.PollOperation(ctx, spec, handle)
+	response := Response{Result: string(operationResponse.Result), Message: operationResponse.Message}
+	if err != nil {
+		response.Error = err.Error()
+	}
+	return json.Marshal(response)
+}
+
+func (s *Server) deleteWithOperation(ctx context.Context, request []byte) ([]byte, error) {
+	deleter, ok := s.ResourceManager.(reconciler.OperationDeleter)
+	if !ok {
+		return s.delete(ctx, request)
+	}
+	spec, _, err := s.decodeRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	deleteResponse, err := deleter.DeleteWithOperation(ctx, spec)
+	return encodeResponse(string(deleteResponse.Result), nil, deleteResponse.Operation, err)
+}
+
+// decodes the ResourceSpec of the request, and the operation it is for, if any
+func (s *Server) decodeRequest(b []byte) (reconciler.ResourceSpec, *Operation, error) {
+	request := Request{}
+	if err := json.Unmarshal(b, &request); err != nil {
+		return reconciler.ResourceSpec{}, nil, err
+	}
+	instance, err := s.decodeObject(request.Instance)
+	if err != nil {
+		return reconciler.ResourceSpec{}, nil, err
+	}
+	spec := reconciler.ResourceSpec{
+		Instance:       instance,
+		Dependencies:   map[types.NamespacedName]runtime.Object{},
+		OperationToken: request.OperationToken,
+	}
+	for _, dep := range request.Dependencies {
+		object, err := s.decodeObject(dep.Object)
+		if err != nil {
+			return reconciler.ResourceSpec{}, nil, err
+		}
+		spec.Dependencies[types.NamespacedName{Namespace: dep.Namespace, Name: dep.Name}] = object
+	}
+	if config := request.ProviderConfig; config != nil {
+		spec.ProviderConfig = &reconciler.ProviderConfig{
+			Kind:           config.Kind,
+			NamespacedName: types.NamespacedName{Namespace: config.Namespace, Name: config.Name},
+			Endpoint:       config.Endpoint,
+			Spec:           config.Spec,
+			Credentials:    config.Credentials,
+		}
+	}
+	return spec, request.Operation, nil
+}
+
+func (s *Server) decodeObject(object map[string]interface{}) (runtime.Object, error) {
+	u := &unstructured.Unstructured{Object: object}
+	if s.Scheme == nil {
+		return u, nil
+	}
+	typed, err := s.Scheme.New(u.GroupVersionKind())
+	if err != nil {
+		return u, nil
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(object, typed); err != nil {
+		return nil, err
+	}
+	return typed, nil
+}
+
+func encodeResponse(result string, status interface{}, operation *reconciler.OperationHandle, err error) ([]byte, error) {
+	response := Response{
+		Result: result,
+		Status: status,
+	}
+	if operation != nil {
+		response.Operation = &Operation{Id: operation.ID, Type: string(operation.Type)}
+	}
+	if err != nil {
+		response.Error = err.Error()
+	}
+	return json.Marshal(response)
+}
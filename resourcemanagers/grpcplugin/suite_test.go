@@ -0,0 +1,13 @@
+package grpcplugin
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestGRPCPlugin(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "gRPC Plugin Suite")
+}
@@ -0,0 +1,182 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/operatify/operatify/reconciler"
+)
+
+// Recorder is a reconciler.ResourceManager that records every call made to its ResourceManager, appending it to a recording.
+// Failing to record a call is logged, and doesn't change its response.
+// It is a reconciler.ResourceManagerWrapper, so the Validator, Defaulter and HealthChecker of its ResourceManager are still used
+type Recorder struct {
+	ResourceManager reconciler.ResourceManager
+	Logger          logr.Logger
+	lock            sync.Mutex
+	file            *os.File
+}
+
+// a Recorder of a ResourceManager implementing reconciler.OperationPoller
+type pollingRecorder struct{ *Recorder }
+
+// a Recorder of a ResourceManager implementing reconciler.OperationDeleter
+type deletingRecorder struct{ *Recorder }
+
+// a Recorder of a ResourceManager implementing both reconciler.OperationPoller and reconciler.OperationDeleter
+type pollingDeletingRecorder struct{ *Recorder }
+
+// CreateRecorder creates a Recorder of the ResourceManager, appending to the recording at the path.
+// The Recorder returned implements reconciler.OperationPoller and reconciler.OperationDeleter only if the ResourceManager does,
+// and is an io.Closer closing the recording
+func CreateRecorder(resourceManager reconciler.ResourceManager, path string, logger logr.Logger) (reconciler.ResourceManager, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open recording %s: %v", path, err)
+	}
+	r := &Recorder{
+		ResourceManager: resourceManager,
+		Logger:          logger,
+		file:            file,
+	}
+	_, polls := resourceManager.(reconciler.OperationPoller)
+	_, deletes := resourceManager.(reconciler.OperationDeleter)
+	switch {
+	case polls && deletes:
+		return pollingDeletingRecorder{r}, nil
+	case polls:
+		return pollingRecorder{r}, nil
+	case deletes:
+		return deletingRecorder{r}, nil
+	}
+	return r, nil
+}
+
+// Close closes the recording
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.file.Close()
+}
+
+// Unwrap returns the ResourceManager whose calls are recorded
+func (r *Recorder) Unwrap() reconciler.ResourceManager {
+	return r.ResourceManager
+}
+
+func (r *Recorder) Create(ctx context.Context, s reconciler.ResourceSpec) (reconciler.ApplyResponse, error) {
+	started := time.Now()
+	response, err := r.ResourceManager.Create(ctx, s)
+	r.record(OperationCreate, s, nil, applyResponse(response), err, started)
+	return response, err
+}
+
+func (r *Recorder) Update(ctx context.Context, s reconciler.ResourceSpec) (reconciler.ApplyResponse, error) {
+	started := time.Now()
+	response, err := r.ResourceManager.Update(ctx, s)
+	r.record(OperationUpdate, s, nil, applyResponse(response), err, started)
+	return response, err
+}
+
+func (r *Recorder) Verify(ctx context.Context, s reconciler.ResourceSpec) (reconciler.VerifyResponse, error) {
+	started := time.Now()
+	response, err := r.ResourceManager.Verify(ctx, s)
+	r.record(OperationVerify, s, nil, Response{Result: string(response.Result), Status: response.Status}, err, started)
+	return response, err
+}
+
+func (r *Recorder) Delete(ctx context.Context, s reconciler.ResourceSpec) (reconciler.DeleteResult, error) {
+	started := time.Now()
+	result, err := r.ResourceManager.Delete(ctx, s)
+	r.record(OperationDelete, s, nil, Response{Result: string(result)}, err, started)
+	return result, err
+}
+
+func (r *Recorder) pollOperation(ctx context.Context, s reconciler.ResourceSpec, handle reconciler.OperationHandle) (reconciler.OperationResponse, error) {
+	started := time.Now()
+	response, err := r.ResourceManager.(reconciler.OperationPoller).PollOperation(ctx, s, handle)
+	r.record(OperationPoll, s, &handle, Response{Result: string(response.Result), Message: response.Message}, err, started)
+	return response, err
+}
+
+// records the delete as a Delete, with the handle of the operation it returned
+func (r *Recorder) deleteWithOperation(ctx context.Context, s reconciler.ResourceSpec) (reconciler.DeleteResponse, error) {
+	started := time.Now()
+	response, err := r.ResourceManager.(reconciler.OperationDeleter).DeleteWithOperation(ctx, s)
+	r.record(OperationDelete, s, nil, Response{Result: string(response.Result), Handle: response.Operation}, err, started)
+	return response, err
+}
+
+func (r pollingRecorder) PollOperation(ctx context.Context, s reconciler.ResourceSpec, handle reconciler.OperationHandle) (reconciler.OperationResponse, error) {
+	return r.pollOperation(ctx, s, handle)
+}
+
+func (r deletingRecorder) DeleteWithOperation(ctx context.Context, s reconciler.ResourceSpec) (reconciler.DeleteResponse, error) {
+	return r.deleteWithOperation(ctx, s)
+}
+
+func (r pollingDeletingRecorder) PollOperation(ctx context.Context, s reconciler.ResourceSpec, handle reconciler.OperationHandle) (reconciler.OperationResponse, error) {
+	return r.pollOperation(ctx, s, handle)
+}
+
+func (r pollingDeletingRecorder) DeleteWithOperation(ctx context.Context, s reconciler.ResourceSpec) (reconciler.DeleteResponse, error) {
+	return r.deleteWithOperation(ctx, s)
+}
+
+func applyResponse(response reconciler.ApplyResponse) Response {
+	return Response{
+		Result: string(response.Result),
+		Status: response.Status,
+		Handle: response.Operation,
+	}
+}
+
+func (r *Recorder) record(operation string, s reconciler.ResourceSpec, handle *reconciler.OperationHandle, response Response, err error, started time.Time) {
+	interaction := Interaction{
+		Operation: operation,
+		Response:  response,
+		Started:   started,
+		Duration:  time.Since(started).Milliseconds(),
+	}
+	if err != nil {
+		interaction.Error = err.Error()
+	}
+	request, encodeErr := encodeRequest(s)
+	if encodeErr != nil {
+		r.Logger.Info(fmt.Sprintf("Unable to record %s: %v", operation, encodeErr))
+		return
+	}
+	request.Handle = handle
+	interaction.Request = request
+	line, encodeErr := json.Marshal(interaction)
+	if encodeErr != nil {
+		r.Logger.Info(fmt.Sprintf("Unable to record %s of %s: %v", operation, request.key(), encodeErr))
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, writeErr := r.file.Write(append(line, '\n')); writeErr != nil {
+		r.Logger.Info(fmt.Sprintf("Unable to record %s of %s: %v", operation, request.key(), writeErr))
+	}
+}
@@ -0,0 +1,172 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package replay provides a ResourceManager decorator that records the calls made to a ResourceManager to a file,
+// and a ResourceManager that replays those recordings, so the reconciler can be tested against recorded backend behaviour
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/operatify/operatify/reconciler"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// The operations of the ResourceManager that are recorded
+const (
+	OperationCreate = "Create"
+	OperationUpdate = "Update"
+	OperationVerify = "Verify"
+	// Calls to Delete, and to DeleteWithOperation, whose operation handle is recorded as the Handle of the Response
+	OperationDelete = "Delete"
+	OperationPoll   = "PollOperation"
+)
+
+// Interaction is a recorded call to a ResourceManager. A recording is a file of Interactions, one JSON document per line
+type Interaction struct {
+	Operation string   `json:"operation"`
+	Request   Request  `json:"request"`
+	Response  Response `json:"response"`
+	// The message of the error returned, if any
+	Error   string    `json:"error,omitempty"`
+	Started time.Time `json:"started"`
+	// The number of milliseconds the call took
+	Duration int64 `json:"duration"`
+}
+
+// Request is the recorded ResourceSpec of a call
+type Request struct {
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// The instance, of which only the spec is compared when replaying
+	Instance       map[string]interface{} `json:"instance"`
+	Dependencies   []Dependency           `json:"dependencies,omitempty"`
+	OperationToken string                 `json:"operationToken,omitempty"`
+	// The provider config of the resource. Its credentials are never recorded
+	ProviderConfig *ProviderConfig `json:"providerConfig,omitempty"`
+	// The operation polled, for PollOperation calls
+	Handle *reconciler.OperationHandle `json:"handle,omitempty"`
+}
+
+type Dependency struct {
+	Namespace string                 `json:"namespace"`
+	Name      string                 `json:"name"`
+	Object    map[string]interface{} `json:"object"`
+}
+
+type ProviderConfig struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Endpoint  string `json:"endpoint,omitempty"`
+}
+
+// Response is the recorded response of a call
+type Response struct {
+	// The ApplyResult, VerifyResult, DeleteResult or OperationResult of the call
+	Result string `json:"result"`
+	// The status data passed back, which is replayed as it was serialized to JSON
+	Status interface{} `json:"status,omitempty"`
+	// The handle of a long-running operation returned by Create, Update or DeleteWithOperation
+	Handle *reconciler.OperationHandle `json:"handle,omitempty"`
+	// The message of a PollOperation response
+	Message string `json:"message,omitempty"`
+}
+
+// LoadRecording reads the Interactions of a recording
+func LoadRecording(path string) ([]Interaction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var interactions []Interaction
+	scanner := bufio.NewScanner(f)
+	// instances can be larger than the default limit of a line
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		interaction := Interaction{}
+		if err := json.Unmarshal(scanner.Bytes(), &interaction); err != nil {
+			return nil, fmt.Errorf("invalid interaction on line %d of %s: %v", line, path, err)
+		}
+		interactions = append(interactions, interaction)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %v", path, err)
+	}
+	return interactions, nil
+}
+
+func encodeRequest(s reconciler.ResourceSpec) (Request, error) {
+	request := Request{
+		OperationToken: s.OperationToken,
+	}
+	if s.Instance != nil {
+		instance, err := runtime.DefaultUnstructuredConverter.ToUnstructured(s.Instance)
+		if err != nil {
+			return request, err
+		}
+		request.Instance = instance
+		request.Kind = s.Instance.GetObjectKind().GroupVersionKind().Kind
+		if meta, err := apimeta.Accessor(s.Instance); err == nil {
+			request.Namespace = meta.GetNamespace()
+			request.Name = meta.GetName()
+		}
+	}
+	for name, dep := range s.Dependencies {
+		object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(dep)
+		if err != nil {
+			return request, err
+		}
+		request.Dependencies = append(request.Dependencies, Dependency{
+			Namespace: name.Namespace,
+			Name:      name.Name,
+			Object:    object,
+		})
+	}
+	// the dependencies are a map, so they're sorted to be recorded in the same order each time
+	sort.Slice(request.Dependencies, func(i, j int) bool {
+		return request.Dependencies[i].key() < request.Dependencies[j].key()
+	})
+	if config := s.ProviderConfig; config != nil {
+		request.ProviderConfig = &ProviderConfig{
+			Kind:      config.Kind,
+			Namespace: config.Namespace,
+			Name:      config.Name,
+			Endpoint:  config.Endpoint,
+		}
+	}
+	return request, nil
+}
+
+func (d Dependency) key() string {
+	return d.Namespace + "/" + d.Name
+}
+
+// identifies the resource of the request, as the calls of each resource are replayed separately
+func (r *Request) key() string {
+	return r.Namespace + "/" + r.Name
+}
@@ -0,0 +1,263 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	api "github.com/operatify/operatify/api/v1alpha1"
+	"github.com/operatify/operatify/reconciler"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// a backend that creates resources asynchronously, becoming ready on the second verify
+type backend struct {
+	verifies int
+}
+
+func (b *backend) Create(ctx context.Context, s reconciler.ResourceSpec) (reconciler.ApplyResponse, error) {
+	return reconciler.ApplyResponse{
+		Result:    reconciler.ApplyResultAwaitingVerification,
+		Operation: &reconciler.OperationHandle{ID: "op-1"},
+	}, nil
+}
+
+func (b *backend) Update(ctx context.Context, s reconciler.ResourceSpec) (reconciler.ApplyResponse, error) {
+	return reconciler.ApplyError, fmt.Errorf("updates aren't supported")
+}
+
+func (b *backend) Verify(ctx context.Context, s reconciler.ResourceSpec) (reconciler.VerifyResponse, error) {
+	b.verifies++
+	if b.verifies == 1 {
+		return reconciler.VerifyInProgress, nil
+	}
+	return reconciler.VerifyReadyWithStatus(map[string]string{"endpoint": "https://example.com"}), nil
+}
+
+func (b *backend) Delete(ctx context.Context, s reconciler.ResourceSpec) (reconciler.DeleteResult, error) {
+	return reconciler.DeleteSucceeded, nil
+}
+
+func (b *backend) PollOperation(ctx context.Context, s reconciler.ResourceSpec, handle reconciler.OperationHandle) (reconciler.OperationResponse, error) {
+	return reconciler.OperationResponse{Result: reconciler.OperationResultSucceeded, Message: "done " + handle.ID}, nil
+}
+
+// a backend whose deletes are long-running operations
+type deletingBackend struct {
+	backend
+}
+
+func (b *deletingBackend) DeleteWithOperation(ctx context.Context, s reconciler.ResourceSpec) (reconciler.DeleteResponse, error) {
+	return reconciler.DeleteResponse{Result: reconciler.DeleteAwaitingVerification, Operation: &reconciler.OperationHandle{ID: "op-2"}}, nil
+}
+
+// a backend without long-running operations
+type plainBackend struct {
+	backend
+}
+
+// hides the PollOperation of the backend
+func (b *plainBackend) PollOperation() {}
+
+var _ = Describe("Replay ResourceManager", func() {
+
+	var dir string
+	var path string
+	ctx := context.Background()
+
+	resourceSpec := func(intData int) reconciler.ResourceSpec {
+		return reconciler.ResourceSpec{
+			Instance: &api.ATest{
+				ObjectMeta: v1.ObjectMeta{Name: "a", Namespace: "default", ResourceVersion: fmt.Sprint(intData)},
+				Spec:       api.ASpec{Spec: api.Spec{Id: "a-id", IntData: intData}},
+			},
+			Dependencies: map[types.NamespacedName]runtime.Object{
+				{Namespace: "default", Name: "b"}: &api.ATest{},
+			},
+			OperationToken: randomToken(),
+		}
+	}
+
+	record := func() {
+		recorder, err := CreateRecorder(&backend{}, path, ctrl.Log)
+		Expect(err).NotTo(HaveOccurred())
+		defer recorder.(io.Closer).Close()
+		s := resourceSpec(1)
+		_, _ = recorder.Create(ctx, s)
+		_, _ = recorder.(reconciler.OperationPoller).PollOperation(ctx, s, reconciler.OperationHandle{ID: "op-1"})
+		_, _ = recorder.Verify(ctx, s)
+		_, _ = recorder.Verify(ctx, s)
+		_, _ = recorder.Update(ctx, s)
+		_, _ = recorder.Delete(ctx, s)
+	}
+
+	createReplayer := func() *Replayer {
+		record()
+		r, err := CreateReplayer(path, ctrl.Log)
+		Expect(err).NotTo(HaveOccurred())
+		return r
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "replay")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "recording.jsonl")
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	It("records every call", func() {
+		record()
+		interactions, err := LoadRecording(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(interactions).To(HaveLen(6))
+
+		create := interactions[0]
+		Expect(create.Operation).To(Equal(OperationCreate))
+		Expect(create.Request.Namespace).To(Equal("default"))
+		Expect(create.Request.Name).To(Equal("a"))
+		Expect(create.Request.Instance["spec"]).To(HaveKeyWithValue("id", "a-id"))
+		Expect(create.Request.Dependencies).To(HaveLen(1))
+		Expect(create.Response.Result).To(Equal(string(reconciler.ApplyResultAwaitingVerification)))
+		Expect(create.Response.Handle.ID).To(Equal("op-1"))
+		Expect(create.Started.IsZero()).To(BeFalse())
+
+		Expect(interactions[1].Request.Handle.ID).To(Equal("op-1"))
+		Expect(interactions[3].Response.Status).To(Equal(map[string]interface{}{"endpoint": "https://example.com"}))
+		Expect(interactions[4].Error).To(Equal("updates aren't supported"))
+	})
+
+	It("implements the optional interfaces of the ResourceManager it records", func() {
+		for _, resourceManager := range []reconciler.ResourceManager{&backend{}, &deletingBackend{}, &plainBackend{}} {
+			recorder, err := CreateRecorder(resourceManager, path, ctrl.Log)
+			Expect(err).NotTo(HaveOccurred())
+			_, polls := resourceManager.(reconciler.OperationPoller)
+			_, deletes := resourceManager.(reconciler.OperationDeleter)
+			Expect(recorder).To(WithTransform(func(rm reconciler.ResourceManager) bool {
+				_, ok := rm.(reconciler.OperationPoller)
+				return ok
+			}, Equal(polls)))
+			Expect(recorder).To(WithTransform(func(rm reconciler.ResourceManager) bool {
+				_, ok := rm.(reconciler.OperationDeleter)
+				return ok
+			}, Equal(deletes)))
+			Expect(recorder.(reconciler.ResourceManagerWrapper).Unwrap()).To(BeIdenticalTo(resourceManager))
+			Expect(recorder.(io.Closer).Close()).To(Succeed())
+		}
+	})
+
+	It("records and replays deletes with operations", func() {
+		recorder, err := CreateRecorder(&deletingBackend{}, path, ctrl.Log)
+		Expect(err).NotTo(HaveOccurred())
+		s := resourceSpec(1)
+		_, _ = recorder.(reconciler.OperationDeleter).DeleteWithOperation(ctx, s)
+		Expect(recorder.(io.Closer).Close()).To(Succeed())
+
+		interactions, err := LoadRecording(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(interactions).To(HaveLen(1))
+		Expect(interactions[0].Operation).To(Equal(OperationDelete))
+		Expect(interactions[0].Response.Handle.ID).To(Equal("op-2"))
+
+		r, err := CreateReplayer(path, ctrl.Log)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.DeleteWithOperation(ctx, s)).To(Equal(reconciler.DeleteResponse{
+			Result:    reconciler.DeleteAwaitingVerification,
+			Operation: &reconciler.OperationHandle{ID: "op-2"},
+		}))
+		Expect(r.Report()).To(Succeed())
+	})
+
+	It("replays the recorded responses", func() {
+		r := createReplayer()
+		s := resourceSpec(1)
+
+		applyResponse, err := r.Create(ctx, s)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applyResponse.Result).To(Equal(reconciler.ApplyResultAwaitingVerification))
+		Expect(applyResponse.Operation.ID).To(Equal("op-1"))
+
+		Expect(r.PollOperation(ctx, s, *applyResponse.Operation)).To(Equal(reconciler.OperationResponse{Result: reconciler.OperationResultSucceeded, Message: "done op-1"}))
+		Expect(r.Verify(ctx, s)).To(Equal(reconciler.VerifyInProgress))
+		verifyResponse, err := r.Verify(ctx, s)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(verifyResponse.Status).To(Equal(map[string]interface{}{"endpoint": "https://example.com"}))
+
+		// once the recorded calls are used up, the last one is repeated
+		Expect(r.Verify(ctx, s)).To(Equal(verifyResponse))
+
+		_, err = r.Update(ctx, s)
+		Expect(err).To(MatchError("updates aren't supported"))
+		Expect(r.Delete(ctx, s)).To(Equal(reconciler.DeleteSucceeded))
+
+		Expect(r.Mismatches()).To(BeEmpty())
+		Expect(r.Report()).To(Succeed())
+	})
+
+	It("reports requests that differ from the recording", func() {
+		r := createReplayer()
+
+		// the response is still served
+		Expect(r.Verify(ctx, resourceSpec(2))).To(Equal(reconciler.VerifyInProgress))
+		Expect(r.Mismatches()).To(Equal([]Mismatch{{
+			Operation:   OperationVerify,
+			Namespace:   "default",
+			Name:        "a",
+			Differences: []string{"spec.intData: recorded 1, got 2"},
+		}}))
+
+		r.Strict = true
+		_, err := r.Verify(ctx, resourceSpec(2))
+		Expect(err).To(MatchError(ContainSubstring("spec.intData: recorded 1, got 2")))
+	})
+
+	It("compares large integers in the spec with their recorded values", func() {
+		recorder, err := CreateRecorder(&backend{}, path, ctrl.Log)
+		Expect(err).NotTo(HaveOccurred())
+		_, _ = recorder.Verify(ctx, resourceSpec(1000000))
+		Expect(recorder.(io.Closer).Close()).To(Succeed())
+
+		r, err := CreateReplayer(path, ctrl.Log)
+		Expect(err).NotTo(HaveOccurred())
+		_, _ = r.Verify(ctx, resourceSpec(1000000))
+		Expect(r.Mismatches()).To(BeEmpty())
+
+		_, _ = r.Verify(ctx, resourceSpec(1000001))
+		Expect(r.Mismatches()).To(HaveLen(1))
+		Expect(r.Mismatches()[0].Differences).To(Equal([]string{"spec.intData: recorded 1000000, got 1000001"}))
+	})
+
+	It("reports calls that weren't recorded, and recorded calls that weren't replayed", func() {
+		r := createReplayer()
+		s := resourceSpec(1)
+		s.Instance.(*api.ATest).Name = "other"
+
+		_, err := r.Delete(ctx, s)
+		Expect(err).To(MatchError("no recorded Delete of default/other"))
+		Expect(r.Unreplayed()).To(HaveLen(6))
+
+		err = r.Report()
+		Expect(err).To(MatchError(ContainSubstring("Delete of default/other was not recorded")))
+		Expect(err).To(MatchError(ContainSubstring("Create of default/a was recorded but not replayed")))
+	})
+})
+
+func randomToken() string {
+	b := make([]byte, 8)
+	for i := range b {
+		b[i] = "abcdefghijklmnopqrstuvwxyz"[rand.Intn(26)]
+	}
+	return string(b)
+}
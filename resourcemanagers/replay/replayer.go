@@ -0,0 +1,311 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/operatify/operatify/reconciler"
+)
+
+// Replayer is a reconciler.ResourceManager that serves the responses of a recording.
+// The calls of each operation of a resource are replayed in the order they were recorded, and once they're used up the last one is repeated,
+// as the reconciler may verify a resource more or fewer times than it did while recording.
+// Calls whose requests differ from the recorded ones are reported as Mismatches.
+// It implements reconciler.OperationPoller and reconciler.OperationDeleter, serving the operations in the recording
+type Replayer struct {
+	Logger logr.Logger
+	// If set, calls whose requests differ from the recorded ones fail instead of being served the recorded responses
+	Strict bool
+	// If set, calls take as long as the recorded calls did
+	ReplayTiming bool
+	lock         sync.Mutex
+	interactions map[string][]Interaction
+	// the number of interactions of each resource and operation that have been replayed
+	replayed   map[string]int
+	mismatches []Mismatch
+}
+
+// Mismatch is a call that didn't match the recording
+type Mismatch struct {
+	Operation string
+	Namespace string
+	Name      string
+	// The fields of the request that differ from those of the recorded call, such as "spec.intData: recorded 1, got 2".
+	// This is empty if there is no recorded call of the operation for the resource
+	Differences []string
+}
+
+func (m Mismatch) String() string {
+	if len(m.Differences) == 0 {
+		return fmt.Sprintf("%s of %s/%s was not recorded", m.Operation, m.Namespace, m.Name)
+	}
+	return fmt.Sprintf("%s of %s/%s differs from the recording: %s", m.Operation, m.Namespace, m.Name, strings.Join(m.Differences, "; "))
+}
+
+// CreateReplayer creates a Replayer of the recording at the path
+func CreateReplayer(path string, logger logr.Logger) (*Replayer, error) {
+	interactions, err := LoadRecording(path)
+	if err != nil {
+		return nil, err
+	}
+	return CreateReplayerOf(interactions, logger), nil
+}
+
+// CreateReplayerOf creates a Replayer of the interactions
+func CreateReplayerOf(interactions []Interaction, logger logr.Logger) *Replayer {
+	r := &Replayer{
+		Logger:       logger,
+		interactions: map[string][]Interaction{},
+		replayed:     map[string]int{},
+	}
+	for _, interaction := range interactions {
+		key := replayKey(interaction.Operation, interaction.Request)
+		r.interactions[key] = append(r.interactions[key], interaction)
+	}
+	return r
+}
+
+func (r *Replayer) Create(ctx context.Context, s reconciler.ResourceSpec) (reconciler.ApplyResponse, error) {
+	response, err := r.replay(ctx, OperationCreate, s, nil)
+	return reconciler.ApplyResponse{
+		Result:    reconciler.ApplyResult(response.Result),
+		Status:    response.Status,
+		Operation: response.Handle,
+	}, err
+}
+
+func (r *Replayer) Update(ctx context.Context, s reconciler.ResourceSpec) (reconciler.ApplyResponse, error) {
+	response, err := r.replay(ctx, OperationUpdate, s, nil)
+	return reconciler.ApplyResponse{
+		Result:    reconciler.ApplyResult(response.Result),
+		Status:    response.Status,
+		Operation: response.Handle,
+	}, err
+}
+
+func (r *Replayer) Verify(ctx context.Context, s reconciler.ResourceSpec) (reconciler.VerifyResponse, error) {
+	response, err := r.replay(ctx, OperationVerify, s, nil)
+	return reconciler.VerifyResponse{
+		Result: reconciler.VerifyResult(response.Result),
+		Status: response.Status,
+	}, err
+}
+
+func (r *Replayer) Delete(ctx context.Context, s reconciler.ResourceSpec) (reconciler.DeleteResult, error) {
+	response, err := r.replay(ctx, OperationDelete, s, nil)
+	return reconciler.DeleteResult(response.Result), err
+}
+
+// DeleteWithOperation replays the recorded deletes, whether the recorded ResourceManager implemented reconciler.OperationDeleter or not
+func (r *Replayer) DeleteWithOperation(ctx context.Context, s reconciler.ResourceSpec) (reconciler.DeleteResponse, error) {
+	response, err := r.replay(ctx, OperationDelete, s, nil)
+	return reconciler.DeleteResponse{
+		Result:    reconciler.DeleteResult(response.Result),
+		Operation: response.Handle,
+	}, err
+}
+
+func (r *Replayer) PollOperation(ctx context.Context, s reconciler.ResourceSpec, handle reconciler.OperationHandle) (reconciler.OperationResponse, error) {
+	response, err := r.replay(ctx, OperationPoll, s, &handle)
+	return reconciler.OperationResponse{
+		Result:  reconciler.OperationResult(response.Result),
+		Message: response.Message,
+	}, err
+}
+
+// Mismatches returns the calls that didn't match the recording so far
+func (r *Replayer) Mismatches() []Mismatch {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]Mismatch{}, r.mismatches...)
+}
+
+// Unreplayed returns the recorded calls that haven't been replayed
+func (r *Replayer) Unreplayed() []Interaction {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var unreplayed []Interaction
+	for key, interactions := range r.interactions {
+		unreplayed = append(unreplayed, interactions[r.replayed[key]:]...)
+	}
+	sort.Slice(unreplayed, func(i, j int) bool {
+		return unreplayed[i].Started.Before(unreplayed[j].Started)
+	})
+	return unreplayed
+}
+
+// Report returns an error listing the mismatches and the recorded calls that haven't been replayed, or nil if there are none
+func (r *Replayer) Report() error {
+	var problems []string
+	for _, mismatch := range r.Mismatches() {
+		problems = append(problems, mismatch.String())
+	}
+	for _, interaction := range r.Unreplayed() {
+		problems = append(problems, fmt.Sprintf("%s of %s was recorded but not replayed", interaction.Operation, interaction.Request.key()))
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("replay didn't match the recording:\n%s", strings.Join(problems, "\n"))
+}
+
+func (r *Replayer) replay(ctx context.Context, operation string, s reconciler.ResourceSpec, handle *reconciler.OperationHandle) (Response, error) {
+	failed := Response{Result: string(reconciler.ApplyResultError)}
+	if operation == OperationPoll {
+		failed.Result = string(reconciler.OperationResultFailed)
+	}
+	request, err := encodeRequest(s)
+	if err != nil {
+		return failed, err
+	}
+	request.Handle = handle
+
+	interaction, differences := r.next(operation, request)
+	if interaction == nil {
+		r.Logger.Info(fmt.Sprintf("No recorded %s of %s", operation, request.key()))
+		return failed, fmt.Errorf("no recorded %s of %s", operation, request.key())
+	}
+	if len(differences) > 0 {
+		r.Logger.Info(fmt.Sprintf("%s of %s differs from the recording: %s", operation, request.key(), strings.Join(differences, "; ")))
+		if r.Strict {
+			return failed, fmt.Errorf("%s of %s differs from the recording: %s", operation, request.key(), strings.Join(differences, "; "))
+		}
+	}
+
+	if r.ReplayTiming && interaction.Duration > 0 {
+		timer := time.NewTimer(time.Duration(interaction.Duration) * time.Millisecond)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return failed, ctx.Err()
+		}
+	}
+	if interaction.Error != "" {
+		return interaction.Response, errors.New(interaction.Error)
+	}
+	return interaction.Response, nil
+}
+
+// returns the next recorded interaction of the operation of the resource, and how the request differs from it, recording any mismatch
+func (r *Replayer) next(operation string, request Request) (*Interaction, []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	key := replayKey(operation, request)
+	interactions := r.interactions[key]
+	if len(interactions) == 0 {
+		r.mismatches = append(r.mismatches, Mismatch{Operation: operation, Namespace: request.Namespace, Name: request.Name})
+		return nil, nil
+	}
+	i := r.replayed[key]
+	if i < len(interactions) {
+		r.replayed[key] = i + 1
+	} else {
+		i = len(interactions) - 1
+	}
+	interaction := interactions[i]
+	differences := compareRequests(interaction.Request, request)
+	if len(differences) > 0 {
+		r.mismatches = append(r.mismatches, Mismatch{Operation: operation, Namespace: request.Namespace, Name: request.Name, Differences: differences})
+	}
+	return &interaction, differences
+}
+
+func replayKey(operation string, request Request) string {
+	return operation + " " + request.key()
+}
+
+// compares the parts of the requests that are the same each time the reconciler makes a call:
+// the spec of the instance, the names of its dependencies, its provider config and the operation polled.
+// Metadata such as resource versions and the random operation tokens differ between runs
+func compareRequests(recorded Request, request Request) []string {
+	var differences []string
+	differences = compareValues("spec", recorded.Instance["spec"], request.Instance["spec"], differences)
+	if recordedNames, names := dependencyNames(recorded), dependencyNames(request); recordedNames != names {
+		differences = append(differences, fmt.Sprintf("dependencies: recorded [%s], got [%s]", recordedNames, names))
+	}
+	if !reflect.DeepEqual(recorded.ProviderConfig, request.ProviderConfig) {
+		differences = append(differences, fmt.Sprintf("providerConfig: recorded %s, got %s", describe(recorded.ProviderConfig), describe(request.ProviderConfig)))
+	}
+	if recorded.Handle != nil && request.Handle != nil && recorded.Handle.ID != request.Handle.ID {
+		differences = append(differences, fmt.Sprintf("handle: recorded %s, got %s", recorded.Handle.ID, request.Handle.ID))
+	}
+	return differences
+}
+
+// compares the values, which were decoded from JSON or converted to unstructured, appending the paths of the fields that differ
+func compareValues(path string, recorded interface{}, value interface{}, differences []string) []string {
+	recordedFields, recordedIsMap := recorded.(map[string]interface{})
+	fields, isMap := value.(map[string]interface{})
+	if recordedIsMap && isMap {
+		var names []string
+		for name := range recordedFields {
+			names = append(names, name)
+		}
+		for name := range fields {
+			if _, ok := recordedFields[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			differences = compareValues(path+"."+name, recordedFields[name], fields[name], differences)
+		}
+		return differences
+	}
+	// numbers are float64 once decoded from JSON, but int64 once converted to unstructured,
+	// so values are compared in their JSON form, in which both are written the same way
+	if jsonValue(recorded) != jsonValue(value) {
+		differences = append(differences, fmt.Sprintf("%s: recorded %s, got %s", path, describe(recorded), describe(value)))
+	}
+	return differences
+}
+
+func jsonValue(value interface{}) string {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(bytes)
+}
+
+func dependencyNames(request Request) string {
+	var names []string
+	for _, dep := range request.Dependencies {
+		names = append(names, dep.key())
+	}
+	return strings.Join(names, ", ")
+}
+
+func describe(value interface{}) string {
+	if value == nil || reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil() {
+		return "nothing"
+	}
+	if config, ok := value.(*ProviderConfig); ok {
+		return fmt.Sprintf("%s %s/%s", config.Kind, config.Namespace, config.Name)
+	}
+	return jsonValue(value)
+}
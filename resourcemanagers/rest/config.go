@@ -0,0 +1,124 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rest provides a ResourceManager for external resources exposed by a REST API,
+// configured declaratively rather than written by hand
+package rest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/operatify/operatify/reconciler"
+	"sigs.k8s.io/yaml"
+)
+
+// Config declares how each ResourceManager operation maps onto a call to the REST API
+type Config struct {
+	// Prefixed to URLs that are not absolute
+	BaseURL string `json:"baseURL,omitempty"`
+	// Headers sent with every request, such as Content-Type or Authorization
+	Headers map[string]string `json:"headers,omitempty"`
+	// The time in milliseconds a request may take before it is cancelled. Defaults to 30 seconds
+	Timeout int       `json:"timeout,omitempty"`
+	Create  Operation `json:"create"`
+	Update  Operation `json:"update"`
+	Verify  Operation `json:"verify"`
+	Delete  Operation `json:"delete"`
+	// If set, the URL requested with GET to check the health of the API, which is healthy if the response is 2xx
+	Health string `json:"health,omitempty"`
+}
+
+// Operation declares the request made for an operation, and how the response is interpreted.
+// URL and Body are Go templates executed with the TemplateData of the resource.
+// The values rendered in the URL are escaped, unless they are rendered with raw
+type Operation struct {
+	// Defaults to POST for Create, PUT for Update, GET for Verify and DELETE for Delete
+	Method string `json:"method,omitempty"`
+	URL    string `json:"url"`
+	// No body is sent if this is empty
+	Body string `json:"body,omitempty"`
+	// Evaluated in order, the first matching rule gives the result of the operation.
+	// If none match, the default rules for the operation apply
+	Rules []Rule `json:"rules,omitempty"`
+	// A JSONPath, such as {.properties}, of the part of the response body to pass back as the status payload.
+	// No status payload is passed back if this is empty
+	StatusPath string `json:"statusPath,omitempty"`
+}
+
+// Rule maps a response onto a result. A rule matches if the status code is one of the StatusCodes (or there are none),
+// and the value at the JSONPath of the response body is one of Values (or there is no JSONPath)
+type Rule struct {
+	StatusCodes []int    `json:"statusCodes,omitempty"`
+	JSONPath    string   `json:"jsonPath,omitempty"`
+	Values      []string `json:"values,omitempty"`
+	// The VerifyResult, ApplyResult or DeleteResult of the operation, such as Ready or AwaitingVerification
+	Result string `json:"result"`
+}
+
+// The results rules of each operation may map onto
+var (
+	applyResults = []string{
+		string(reconciler.ApplyResultAwaitingVerification),
+		string(reconciler.ApplyResultSucceeded),
+		string(reconciler.ApplyResultError),
+	}
+	verifyResults = []string{
+		string(reconciler.VerifyResultMissing),
+		string(reconciler.VerifyResultRecreateRequired),
+		string(reconciler.VerifyResultUpdateRequired),
+		string(reconciler.VerifyResultInProgress),
+		string(reconciler.VerifyResultDeleting),
+		string(reconciler.VerifyResultReady),
+		string(reconciler.VerifyResultError),
+	}
+	deleteResults = []string{
+		string(reconciler.DeleteAlreadyDeleted),
+		string(reconciler.DeleteSucceeded),
+		string(reconciler.DeleteAwaitingVerification),
+		string(reconciler.DeleteError),
+	}
+)
+
+// The rules applied if none of the configured rules match. Any other response is an error
+var (
+	defaultApplyRules = []Rule{
+		{StatusCodes: successCodes, Result: string(reconciler.ApplyResultAwaitingVerification)},
+	}
+	defaultVerifyRules = []Rule{
+		{StatusCodes: []int{http.StatusNotFound}, Result: string(reconciler.VerifyResultMissing)},
+		{StatusCodes: successCodes, Result: string(reconciler.VerifyResultReady)},
+	}
+	defaultDeleteRules = []Rule{
+		{StatusCodes: []int{http.StatusNotFound, http.StatusGone}, Result: string(reconciler.DeleteAlreadyDeleted)},
+		{StatusCodes: []int{http.StatusAccepted}, Result: string(reconciler.DeleteAwaitingVerification)},
+		{StatusCodes: successCodes, Result: string(reconciler.DeleteSucceeded)},
+	}
+	successCodes = []int{http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNoContent}
+)
+
+// LoadConfig reads a Config from a YAML or JSON file
+func LoadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(b, config); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", path, err)
+	}
+	return config, nil
+}
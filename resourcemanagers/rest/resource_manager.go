@@ -0,0 +1,513 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/operatify/operatify/reconciler"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// ResourceManager is a reconciler.ResourceManager that calls a REST API as declared by a Config
+type ResourceManager struct {
+	Config Config
+	Client *http.Client
+	Logger logr.Logger
+	create *operation
+	update *operation
+	verify *operation
+	delete *operation
+}
+
+// the Error results of each operation share a name
+const errorResult = string(reconciler.ApplyResultError)
+
+// TemplateData is passed to the URL and Body templates of an Operation
+type TemplateData struct {
+	Name      string
+	Namespace string
+	// The spec, metadata and status of the resource, as in its JSON representation
+	Spec     map[string]interface{}
+	Metadata map[string]interface{}
+	Status   map[string]interface{}
+	// The JSON representations of the dependencies of the resource, keyed by name
+	Dependencies map[string]map[string]interface{}
+	// See reconciler.ResourceSpec
+	OperationToken string
+}
+
+// the parsed form of an Operation
+type operation struct {
+	name       string
+	method     string
+	url        *template.Template
+	body       *template.Template
+	rules      []rule
+	statusPath jsonPath
+}
+
+type rule struct {
+	Rule
+	path jsonPath
+}
+
+// the response of a call to the REST API
+type response struct {
+	statusCode int
+	body       interface{}
+	raw        []byte
+}
+
+var templateFuncs = template.FuncMap{
+	// renders the value as JSON, for use in request bodies
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	// escapes the value for use as a segment of a URL path, so that values such as namespace/name stay in one segment.
+	// Values in URLs are escaped with this unless they are in the query
+	"pathEscape": url.PathEscape,
+	// escapes the value for use in a URL query. Values in the query of URLs are escaped with this
+	"queryEscape": url.QueryEscape,
+	// renders the value in a URL without escaping it, such as a path of several segments
+	"raw": func(v interface{}) string {
+		return fmt.Sprint(v)
+	},
+}
+
+// the template functions that escape a value for a URL, or opt out of escaping it
+var urlEscapers = []string{"pathEscape", "queryEscape", "raw"}
+
+// the time in milliseconds a request may take, if the Config sets no Timeout
+const defaultTimeout = 30000
+
+// a validated JSONPath expression. A jsonpath.JSONPath keeps state while it finds results,
+// so one is parsed for each use rather than shared between concurrent reconciles
+type jsonPath string
+
+// CreateResourceManager parses the templates and JSONPaths of the Config, returning an error if any are invalid.
+// If client is nil, http.DefaultClient is used
+func CreateResourceManager(config Config, client *http.Client, logger logr.Logger) (*ResourceManager, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	r := &ResourceManager{
+		Config: config,
+		Client: client,
+		Logger: logger,
+	}
+	var err error
+	if r.create, err = parseOperation("create", config.Create, http.MethodPost, applyResults, defaultApplyRules); err != nil {
+		return nil, err
+	}
+	if r.update, err = parseOperation("update", config.Update, http.MethodPut, applyResults, defaultApplyRules); err != nil {
+		return nil, err
+	}
+	if r.verify, err = parseOperation("verify", config.Verify, http.MethodGet, verifyResults, defaultVerifyRules); err != nil {
+		return nil, err
+	}
+	if r.delete, err = parseOperation("delete", config.Delete, http.MethodDelete, deleteResults, defaultDeleteRules); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *ResourceManager) Create(ctx context.Context, s reconciler.ResourceSpec) (reconciler.ApplyResponse, error) {
+	result, status, err := r.execute(ctx, r.create, s)
+	return reconciler.ApplyResponse{
+		Result: reconciler.ApplyResult(result),
+		Status: status,
+	}, err
+}
+
+func (r *ResourceManager) Update(ctx context.Context, s reconciler.ResourceSpec) (reconciler.ApplyResponse, error) {
+	result, status, err := r.execute(ctx, r.update, s)
+	return reconciler.ApplyResponse{
+		Result: reconciler.ApplyResult(result),
+		Status: status,
+	}, err
+}
+
+func (r *ResourceManager) Verify(ctx context.Context, s reconciler.ResourceSpec) (reconciler.VerifyResponse, error) {
+	result, status, err := r.execute(ctx, r.verify, s)
+	return reconciler.VerifyResponse{
+		Result: reconciler.VerifyResult(result),
+		Status: status,
+	}, err
+}
+
+func (r *ResourceManager) Delete(ctx context.Context, s reconciler.ResourceSpec) (reconciler.DeleteResult, error) {
+	result, _, err := r.execute(ctx, r.delete, s)
+	return reconciler.DeleteResult(result), err
+}
+
+// CheckHealth requests the Health URL of the Config, if there is one
+func (r *ResourceManager) CheckHealth(ctx context.Context) error {
+	if r.Config.Health == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.getTimeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.resolveURL(r.Config.Health), nil)
+	if err != nil {
+		return err
+	}
+	for name, value := range r.Config.Headers {
+		req.Header.Set(name, value)
+	}
+	httpResp, err := r.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode < 200 || httpResp.StatusCode > 299 {
+		return fmt.Errorf("health check of %s returned %d", req.URL, httpResp.StatusCode)
+	}
+	return nil
+}
+
+// calls the REST API for the operation, returning the result and status payload from the response
+func (r *ResourceManager) execute(ctx context.Context, op *operation, s reconciler.ResourceSpec) (string, interface{}, error) {
+	data, err := createTemplateData(s)
+	if err != nil {
+		return errorResult, nil, err
+	}
+	resp, err := r.call(ctx, op, data)
+	if err != nil {
+		return errorResult, nil, err
+	}
+
+	result, matched := op.evaluate(resp)
+	if !matched {
+		return errorResult, nil, fmt.Errorf("unexpected response to %s of %s: %d %s", op.name, data.Name, resp.statusCode, string(resp.raw))
+	}
+	if result == errorResult {
+		return result, nil, fmt.Errorf("%s of %s failed: %d %s", op.name, data.Name, resp.statusCode, string(resp.raw))
+	}
+	return result, op.extractStatus(resp), nil
+}
+
+func (r *ResourceManager) call(ctx context.Context, op *operation, data *TemplateData) (*response, error) {
+	url, err := render(op.url, data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to render URL for %s: %v", op.name, err)
+	}
+	url = r.resolveURL(url)
+	var body io.Reader
+	if op.body != nil {
+		b, err := render(op.body, data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to render body for %s: %v", op.name, err)
+		}
+		body = strings.NewReader(b)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.getTimeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, op.method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range r.Config.Headers {
+		req.Header.Set(name, value)
+	}
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	r.Logger.Info(fmt.Sprintf("Calling %s %s for %s of %s", op.method, url, op.name, data.Name))
+	httpResp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	raw, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &response{statusCode: httpResp.StatusCode, raw: raw}
+	if len(bytes.TrimSpace(raw)) > 0 {
+		if err := json.Unmarshal(raw, &resp.body); err != nil {
+			// not JSON, so JSONPath rules cannot match
+			resp.body = nil
+		}
+	}
+	return resp, nil
+}
+
+func (r *ResourceManager) getTimeout() time.Duration {
+	millis := r.Config.Timeout
+	if millis == 0 {
+		millis = defaultTimeout
+	}
+	return time.Duration(millis) * time.Millisecond
+}
+
+// prefixes the BaseURL to the URL unless it is absolute
+func (r *ResourceManager) resolveURL(url string) string {
+	if strings.Contains(url, "://") {
+		return url
+	}
+	return strings.TrimSuffix(r.Config.BaseURL, "/") + "/" + strings.TrimPrefix(url, "/")
+}
+
+func parseOperation(name string, config Operation, defaultMethod string, results []string, defaultRules []Rule) (*operation, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("no url defined for %s", name)
+	}
+	op := &operation{name: name, method: strings.ToUpper(config.Method)}
+	if op.method == "" {
+		op.method = defaultMethod
+	}
+	var err error
+	if op.url, err = template.New(name + "-url").Funcs(templateFuncs).Option("missingkey=error").Parse(config.URL); err != nil {
+		return nil, fmt.Errorf("invalid url template for %s: %v", name, err)
+	}
+	escapeURLTemplate(op.url.Tree)
+	if config.Body != "" {
+		if op.body, err = template.New(name + "-body").Funcs(templateFuncs).Option("missingkey=error").Parse(config.Body); err != nil {
+			return nil, fmt.Errorf("invalid body template for %s: %v", name, err)
+		}
+	}
+	for _, r := range append(append([]Rule{}, config.Rules...), defaultRules...) {
+		if !contains(results, r.Result) {
+			return nil, fmt.Errorf("invalid result '%s' in rule for %s, must be one of %s", r.Result, name, strings.Join(results, ", "))
+		}
+		parsed := rule{Rule: r}
+		if r.JSONPath != "" {
+			if parsed.path, err = parseJSONPath(r.JSONPath); err != nil {
+				return nil, fmt.Errorf("invalid jsonPath in rule for %s: %v", name, err)
+			}
+		}
+		op.rules = append(op.rules, parsed)
+	}
+	if config.StatusPath != "" {
+		if op.statusPath, err = parseJSONPath(config.StatusPath); err != nil {
+			return nil, fmt.Errorf("invalid statusPath for %s: %v", name, err)
+		}
+	}
+	return op, nil
+}
+
+// returns the result of the first rule matching the response
+func (op *operation) evaluate(resp *response) (string, bool) {
+	for _, r := range op.rules {
+		if r.matches(resp) {
+			return r.Result, true
+		}
+	}
+	return "", false
+}
+
+func (r *rule) matches(resp *response) bool {
+	if len(r.StatusCodes) > 0 && !containsInt(r.StatusCodes, resp.statusCode) {
+		return false
+	}
+	if r.path == "" {
+		return true
+	}
+	values := findValues(r.path, resp.body)
+	if len(r.Values) == 0 {
+		return len(values) > 0
+	}
+	for _, v := range values {
+		if contains(r.Values, fmt.Sprint(v)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (op *operation) extractStatus(resp *response) interface{} {
+	if op.statusPath == "" {
+		return nil
+	}
+	values := findValues(op.statusPath, resp.body)
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+func createTemplateData(s reconciler.ResourceSpec) (*TemplateData, error) {
+	object, err := toMap(s.Instance)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := apimeta.Accessor(s.Instance)
+	if err != nil {
+		return nil, err
+	}
+	data := &TemplateData{
+		Name:           meta.GetName(),
+		Namespace:      meta.GetNamespace(),
+		Spec:           asMap(object["spec"]),
+		Metadata:       asMap(object["metadata"]),
+		Status:         asMap(object["status"]),
+		Dependencies:   map[string]map[string]interface{}{},
+		OperationToken: s.OperationToken,
+	}
+	for name, dep := range s.Dependencies {
+		depObject, err := toMap(dep)
+		if err != nil {
+			return nil, err
+		}
+		data.Dependencies[name.Name] = depObject
+	}
+	return data, nil
+}
+
+func toMap(object runtime.Object) (map[string]interface{}, error) {
+	if object == nil || reflect.ValueOf(object).IsNil() {
+		return map[string]interface{}{}, nil
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	if m, ok := v.(map[string]interface{}); ok {
+		return m
+	}
+	return map[string]interface{}{}
+}
+
+// escapes the values rendered by the actions of a URL template, with pathEscape before the query and queryEscape in it.
+// actions that already end with one of the urlEscapers are left as they are
+func escapeURLTemplate(tree *parse.Tree) {
+	inQuery := false
+	var escape func(list *parse.ListNode)
+	escape = func(list *parse.ListNode) {
+		if list == nil {
+			return
+		}
+		for _, node := range list.Nodes {
+			switch node := node.(type) {
+			case *parse.TextNode:
+				inQuery = inQuery || bytes.ContainsRune(node.Text, '?')
+			case *parse.ActionNode:
+				escapeAction(tree, node.Pipe, inQuery)
+			case *parse.IfNode:
+				escape(node.List)
+				escape(node.ElseList)
+			case *parse.RangeNode:
+				escape(node.List)
+				escape(node.ElseList)
+			case *parse.WithNode:
+				escape(node.List)
+				escape(node.ElseList)
+			}
+		}
+	}
+	escape(tree.Root)
+}
+
+func escapeAction(tree *parse.Tree, pipe *parse.PipeNode, inQuery bool) {
+	// an action declaring or assigning a variable renders nothing
+	if len(pipe.Decl) > 0 || len(pipe.Cmds) == 0 {
+		return
+	}
+	last := pipe.Cmds[len(pipe.Cmds)-1]
+	if identifier, ok := last.Args[0].(*parse.IdentifierNode); ok && contains(urlEscapers, identifier.Ident) {
+		return
+	}
+	escaper := "pathEscape"
+	if inQuery {
+		escaper = "queryEscape"
+	}
+	command := last.Copy().(*parse.CommandNode)
+	command.Args = []parse.Node{parse.NewIdentifier(escaper).SetTree(tree).SetPos(last.Pos)}
+	pipe.Cmds = append(pipe.Cmds, command)
+}
+
+func render(t *template.Template, data *TemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func parseJSONPath(path string) (jsonPath, error) {
+	if _, err := jsonPath(path).compile(); err != nil {
+		return "", err
+	}
+	return jsonPath(path), nil
+}
+
+func (path jsonPath) compile() (*jsonpath.JSONPath, error) {
+	j := jsonpath.New("").AllowMissingKeys(true)
+	if err := j.Parse(string(path)); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func findValues(path jsonPath, body interface{}) []interface{} {
+	if body == nil {
+		return nil
+	}
+	j, err := path.compile()
+	if err != nil {
+		return nil
+	}
+	results, err := j.FindResults(body)
+	if err != nil {
+		return nil
+	}
+	var values []interface{}
+	for _, result := range results {
+		for _, v := range result {
+			if v.IsValid() && v.CanInterface() {
+				values = append(values, v.Interface())
+			}
+		}
+	}
+	return values
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
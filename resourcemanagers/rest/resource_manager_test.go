@@ -0,0 +1,268 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	api "github.com/operatify/operatify/api/v1alpha1"
+	"github.com/operatify/operatify/reconciler"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// a minimal REST API storing JSON objects by path, which reports objects as Provisioning until they have been read once
+type fakeAPI struct {
+	mutex    sync.Mutex
+	objects  map[string]map[string]interface{}
+	requests []string
+	bodies   []map[string]interface{}
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	path := req.URL.EscapedPath()
+	request := req.Method + " " + path
+	if req.URL.RawQuery != "" {
+		request += "?" + req.URL.RawQuery
+	}
+	f.requests = append(f.requests, request)
+	var body map[string]interface{}
+	if b, _ := ioutil.ReadAll(req.Body); len(b) > 0 {
+		_ = json.Unmarshal(b, &body)
+		f.bodies = append(f.bodies, body)
+	}
+	object, exists := f.objects[path]
+	switch req.Method {
+	case http.MethodPost, http.MethodPut:
+		object = map[string]interface{}{"provisioningState": "Provisioning"}
+		for k, v := range body {
+			object[k] = v
+		}
+		f.objects[path] = object
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(object)
+	case http.MethodGet:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(object)
+		object["provisioningState"] = "Succeeded"
+	case http.MethodDelete:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+var _ = Describe("REST ResourceManager", func() {
+
+	var restAPI *fakeAPI
+	var server *httptest.Server
+	var resourceManager *ResourceManager
+	ctx := context.Background()
+
+	config := Config{
+		Headers: map[string]string{"Authorization": "Bearer token"},
+		Create: Operation{
+			URL:  "/things/{{.Spec.id}}",
+			Body: `{"name": {{json .Name}}, "token": {{json .OperationToken}}}`,
+		},
+		Update: Operation{
+			URL:  "/things/{{.Spec.id}}",
+			Body: `{"name": {{json .Name}}}`,
+		},
+		Verify: Operation{
+			URL: "/things/{{.Spec.id}}",
+			Rules: []Rule{
+				{StatusCodes: []int{http.StatusOK}, JSONPath: "{.provisioningState}", Values: []string{"Provisioning"}, Result: "InProgress"},
+				{StatusCodes: []int{http.StatusOK}, JSONPath: "{.provisioningState}", Values: []string{"Failed"}, Result: "Error"},
+			},
+			StatusPath: "{.name}",
+		},
+		Delete: Operation{
+			URL: "/things/{{.Spec.id}}",
+		},
+	}
+
+	resourceSpec := func(id string) reconciler.ResourceSpec {
+		return reconciler.ResourceSpec{
+			Instance: &api.ATest{
+				ObjectMeta: v1.ObjectMeta{Name: "a-" + id, Namespace: "default"},
+				Spec:       api.ASpec{Spec: api.Spec{Id: id}},
+			},
+			Dependencies:   map[types.NamespacedName]runtime.Object{},
+			OperationToken: "token-" + id,
+		}
+	}
+
+	BeforeEach(func() {
+		restAPI = &fakeAPI{objects: map[string]map[string]interface{}{}}
+		server = httptest.NewServer(restAPI)
+		c := config
+		c.BaseURL = server.URL
+		var err error
+		resourceManager, err = CreateResourceManager(c, server.Client(), ctrl.Log)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("maps the lifecycle of a resource onto requests", func() {
+		s := resourceSpec("1")
+
+		verifyResponse, err := resourceManager.Verify(ctx, s)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(verifyResponse.Result).To(Equal(reconciler.VerifyResultMissing))
+
+		applyResponse, err := resourceManager.Create(ctx, s)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(applyResponse.Result).To(Equal(reconciler.ApplyResultAwaitingVerification))
+		Expect(restAPI.bodies[0]).To(Equal(map[string]interface{}{"name": "a-1", "token": "token-1"}))
+
+		verifyResponse, err = resourceManager.Verify(ctx, s)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(verifyResponse.Result).To(Equal(reconciler.VerifyResultInProgress))
+
+		verifyResponse, err = resourceManager.Verify(ctx, s)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(verifyResponse.Result).To(Equal(reconciler.VerifyResultReady))
+		Expect(verifyResponse.Status).To(Equal("a-1"))
+
+		deleteResult, err := resourceManager.Delete(ctx, s)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deleteResult).To(Equal(reconciler.DeleteSucceeded))
+
+		deleteResult, err = resourceManager.Delete(ctx, s)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deleteResult).To(Equal(reconciler.DeleteAlreadyDeleted))
+
+		Expect(restAPI.requests).To(Equal([]string{
+			"GET /things/1", "POST /things/1", "GET /things/1", "GET /things/1", "DELETE /things/1", "DELETE /things/1",
+		}))
+	})
+
+	It("escapes values in URLs", func() {
+		_, err := resourceManager.Create(ctx, resourceSpec("default/4"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restAPI.requests).To(Equal([]string{"POST /things/default%2F4"}))
+	})
+
+	It("escapes values in the query of URLs", func() {
+		c := config
+		c.BaseURL = server.URL
+		c.Verify.URL = "/things?name={{.Name}}&id={{.Spec.id}}"
+		querying, err := CreateResourceManager(c, server.Client(), ctrl.Log)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = querying.Verify(ctx, resourceSpec("b c&d"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restAPI.requests).To(Equal([]string{"GET /things?name=a-b+c%26d&id=b+c%26d"}))
+	})
+
+	It("doesn't escape values rendered with raw, or already escaped", func() {
+		c := config
+		c.BaseURL = server.URL
+		c.Create.URL = "/{{.Spec.id | raw}}"
+		c.Delete.URL = "/things/{{.Spec.id | queryEscape}}"
+		raw, err := CreateResourceManager(c, server.Client(), ctrl.Log)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = raw.Create(ctx, resourceSpec("things/6"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = raw.Delete(ctx, resourceSpec("a b"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(restAPI.requests).To(Equal([]string{"POST /things/6", "DELETE /things/a+b"}))
+	})
+
+	It("times out requests", func() {
+		server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			select {
+			case <-req.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		})
+		c := config
+		c.BaseURL = server.URL
+		c.Timeout = 50
+		timing, err := CreateResourceManager(c, server.Client(), ctrl.Log)
+		Expect(err).NotTo(HaveOccurred())
+
+		verifyResponse, err := timing.Verify(ctx, resourceSpec("7"))
+		Expect(err).To(MatchError(ContainSubstring("context deadline exceeded")))
+		Expect(verifyResponse.Result).To(Equal(reconciler.VerifyResultError))
+	})
+
+	It("can match responses concurrently", func() {
+		restAPI.objects["/things/5"] = map[string]interface{}{"provisioningState": "Succeeded", "name": "a-5"}
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				verifyResponse, err := resourceManager.Verify(ctx, resourceSpec("5"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(verifyResponse.Result).To(Equal(reconciler.VerifyResultReady))
+				Expect(verifyResponse.Status).To(Equal("a-5"))
+			}()
+		}
+		wg.Wait()
+	})
+
+	It("returns an error for a response matched by an Error rule", func() {
+		restAPI.objects["/things/2"] = map[string]interface{}{"provisioningState": "Failed"}
+
+		verifyResponse, err := resourceManager.Verify(ctx, resourceSpec("2"))
+		Expect(err).To(HaveOccurred())
+		Expect(verifyResponse.Result).To(Equal(reconciler.VerifyResultError))
+	})
+
+	It("returns an error for a response matched by no rule", func() {
+		server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+
+		applyResponse, err := resourceManager.Update(ctx, resourceSpec("3"))
+		Expect(err).To(HaveOccurred())
+		Expect(applyResponse.Result).To(Equal(reconciler.ApplyResultError))
+	})
+
+	It("checks the health of the API", func() {
+		Expect(resourceManager.CheckHealth(ctx)).To(Succeed())
+
+		c := config
+		c.BaseURL = server.URL
+		c.Health = "/health"
+		checked, err := CreateResourceManager(c, server.Client(), ctrl.Log)
+		Expect(err).NotTo(HaveOccurred())
+		// the fake API has no objects at /health
+		Expect(checked.CheckHealth(ctx)).NotTo(Succeed())
+
+		restAPI.objects["/health"] = map[string]interface{}{"status": "ok"}
+		Expect(checked.CheckHealth(ctx)).To(Succeed())
+		Expect(restAPI.requests).To(Equal([]string{"GET /health", "GET /health"}))
+	})
+
+	It("rejects rules with results the operation cannot return", func() {
+		c := config
+		c.Delete.Rules = []Rule{{Result: "Ready"}}
+		_, err := CreateResourceManager(c, nil, ctrl.Log)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,13 @@
+package rest
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestREST(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "REST ResourceManager Suite")
+}
@@ -0,0 +1,222 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package scaffold generates the code for a new operatify kind in a project laid out like this one:
+// API types in api/<version>, a package per kind in controllers, and the AnnotationBaseName in controllers/shared
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const (
+	importsMarker = "// +kubebuilder:scaffold:imports"
+	builderMarker = "// +kubebuilder:scaffold:builder"
+)
+
+// KindOptions describes the kind to generate
+type KindOptions struct {
+	// The root directory of the project, containing go.mod and main.go
+	Root string
+	// The name of the kind, e.g. CTest
+	Kind string
+	// The API version, e.g. v1alpha1
+	Version string
+	// The kinds in the same API version that resources of this kind depend on
+	Dependencies []string
+}
+
+// the values used in the templates
+type kindData struct {
+	KindOptions
+	Module         string
+	Group          string
+	Package        string
+	Plural         string
+	Boilerplate    string
+	DependencyRefs []dependencyData
+}
+
+type dependencyData struct {
+	Kind  string
+	Field string
+	Json  string
+}
+
+var kindPattern = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+
+// NewKind generates the files for a new kind and registers its controller in main.go, returning the paths written.
+// Nothing is written if any of the files already exists, or the controller can't be registered in main.go
+func NewKind(options KindOptions) ([]string, error) {
+	data, err := createKindData(options)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]*template.Template{
+		filepath.Join("api", data.Version, data.Package+"_types.go"):        apiTemplate,
+		filepath.Join("controllers", data.Package, "definition_manager.go"): definitionManagerTemplate,
+		filepath.Join("controllers", data.Package, "controller_factory.go"): controllerFactoryTemplate,
+		filepath.Join("controllers", data.Package, "resource_manager.go"):   resourceManagerTemplate,
+		filepath.Join("controllers", data.Package+"_helpers_test.go"):       helpersTemplate,
+	}
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	// everything is generated and checked before anything is written, so a failure leaves the project as it was
+	sources := map[string][]byte{}
+	for _, path := range paths {
+		fullPath := filepath.Join(options.Root, path)
+		if _, err := os.Stat(fullPath); err == nil {
+			return nil, fmt.Errorf("%s already exists", fullPath)
+		}
+		source, err := render(files[path], data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to generate %s: %v", path, err)
+		}
+		sources[fullPath] = source
+	}
+	mainPath := filepath.Join(options.Root, "main.go")
+	mainSource, err := registerInMain(mainPath, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var written []string
+	for _, path := range paths {
+		fullPath := filepath.Join(options.Root, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return written, err
+		}
+		if err := ioutil.WriteFile(fullPath, sources[fullPath], 0644); err != nil {
+			return written, err
+		}
+		written = append(written, fullPath)
+	}
+	if err := ioutil.WriteFile(mainPath, mainSource, 0644); err != nil {
+		return written, err
+	}
+	return append(written, mainPath), nil
+}
+
+func createKindData(options KindOptions) (*kindData, error) {
+	if !kindPattern.MatchString(options.Kind) {
+		return nil, fmt.Errorf("kind '%s' must be a CamelCase identifier", options.Kind)
+	}
+	if options.Version == "" {
+		options.Version = "v1alpha1"
+	}
+	module, err := readModule(options.Root)
+	if err != nil {
+		return nil, err
+	}
+	group, err := readGroup(filepath.Join(options.Root, "api", options.Version, "groupversion_info.go"))
+	if err != nil {
+		return nil, err
+	}
+	boilerplate, _ := ioutil.ReadFile(filepath.Join(options.Root, "hack", "boilerplate.go.txt"))
+
+	data := &kindData{
+		KindOptions: options,
+		Module:      module,
+		Group:       group,
+		Package:     strings.ToLower(options.Kind),
+		Plural:      strings.ToLower(options.Kind) + "s",
+		Boilerplate: strings.TrimSpace(string(boilerplate)),
+	}
+	for _, dep := range options.Dependencies {
+		if !kindPattern.MatchString(dep) {
+			return nil, fmt.Errorf("dependency '%s' must be a CamelCase identifier", dep)
+		}
+		data.DependencyRefs = append(data.DependencyRefs, dependencyData{
+			Kind:  dep,
+			Field: dep + "Ref",
+			Json:  strings.ToLower(dep[:1]) + dep[1:] + "Ref",
+		})
+	}
+	return data, nil
+}
+
+func readModule(root string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return "", err
+	}
+	match := regexp.MustCompile(`(?m)^module\s+(\S+)`).FindSubmatch(b)
+	if match == nil {
+		return "", fmt.Errorf("no module declared in go.mod")
+	}
+	return string(match[1]), nil
+}
+
+func readGroup(groupVersionInfo string) (string, error) {
+	b, err := ioutil.ReadFile(groupVersionInfo)
+	if err != nil {
+		return "", err
+	}
+	match := regexp.MustCompile(`\+groupName=(\S+)`).FindSubmatch(b)
+	if match == nil {
+		return "", fmt.Errorf("no +groupName marker in %s", groupVersionInfo)
+	}
+	return string(match[1]), nil
+}
+
+func render(tmpl *template.Template, data *kindData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
+
+// returns main.go with the import of the kind's controller package and its registration added before the kubebuilder scaffold markers
+func registerInMain(mainPath string, data *kindData) ([]byte, error) {
+	b, err := ioutil.ReadFile(mainPath)
+	if err != nil {
+		return nil, err
+	}
+	source := string(b)
+	if !strings.Contains(source, importsMarker) || !strings.Contains(source, builderMarker) {
+		return nil, fmt.Errorf("%s does not contain the kubebuilder scaffold markers", mainPath)
+	}
+	importPath := fmt.Sprintf("\"%s/controllers/%s\"", data.Module, data.Package)
+	if strings.Contains(source, importPath) {
+		return nil, fmt.Errorf("%s already imports %s", mainPath, importPath)
+	}
+	var registration bytes.Buffer
+	if err := registrationTemplate.Execute(&registration, data); err != nil {
+		return nil, err
+	}
+	source = strings.Replace(source, importsMarker, importPath+"\n\t"+importsMarker, 1)
+	source = strings.Replace(source, builderMarker,
+		strings.TrimSpace(registration.String())+"\n\t"+builderMarker, 1)
+	formatted, err := format.Source([]byte(source))
+	if err != nil {
+		return nil, fmt.Errorf("unable to register %s in %s: %v", data.Kind, mainPath, err)
+	}
+	return formatted, nil
+}
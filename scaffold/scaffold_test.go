@@ -0,0 +1,99 @@
+package scaffold
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewKind", func() {
+
+	var root string
+
+	// copies the files of this project that NewKind reads into the root
+	copyProject := func(paths ...string) {
+		for _, path := range paths {
+			b, err := ioutil.ReadFile(filepath.Join("..", path))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.MkdirAll(filepath.Dir(filepath.Join(root, path)), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(root, path), b, 0644)).To(Succeed())
+		}
+	}
+
+	read := func(path string) string {
+		b, err := ioutil.ReadFile(filepath.Join(root, path))
+		Expect(err).NotTo(HaveOccurred())
+		return string(b)
+	}
+
+	BeforeEach(func() {
+		var err error
+		root, err = ioutil.TempDir("", "scaffold")
+		Expect(err).NotTo(HaveOccurred())
+		copyProject("go.mod", "main.go", "hack/boilerplate.go.txt", "api/v1alpha1/groupversion_info.go")
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(root)
+	})
+
+	It("generates the files of the kind and registers its controller", func() {
+		written, err := NewKind(KindOptions{Root: root, Kind: "CTest", Dependencies: []string{"ATest"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(written).To(ConsistOf(
+			filepath.Join(root, "api", "v1alpha1", "ctest_types.go"),
+			filepath.Join(root, "controllers", "ctest", "definition_manager.go"),
+			filepath.Join(root, "controllers", "ctest", "controller_factory.go"),
+			filepath.Join(root, "controllers", "ctest", "resource_manager.go"),
+			filepath.Join(root, "controllers", "ctest_helpers_test.go"),
+			filepath.Join(root, "main.go"),
+		))
+
+		types := read("api/v1alpha1/ctest_types.go")
+		Expect(types).To(ContainSubstring("Licensed under the Apache License"))
+		Expect(types).To(ContainSubstring(`ATestRef string `))
+
+		factory := read("controllers/ctest/controller_factory.go")
+		Expect(factory).To(ContainSubstring("resources=ctests,"))
+		Expect(factory).To(ContainSubstring("generic.ProviderConfigKinds = shared.ProviderConfigKinds()"))
+		Expect(factory).To(ContainSubstring("generic.DependencyKinds = []runtime.Object{&api.ATest{}}"))
+
+		main := read("main.go")
+		Expect(main).To(ContainSubstring(`"github.com/operatify/operatify/controllers/ctest"`))
+		Expect(main).To(ContainSubstring("ResourceManagerCreator: ctest.CreateResourceManager,"))
+		Expect(main).To(ContainSubstring("EnableWebhooks:         enableWebhooks,"))
+		Expect(main).To(ContainSubstring("ShardManager:           shardManager,"))
+		Expect(main).To(ContainSubstring("HealthChecks:           healthChecks,"))
+	})
+
+	It("writes nothing if a file of the kind already exists", func() {
+		existing := filepath.Join(root, "controllers", "ctest", "resource_manager.go")
+		Expect(os.MkdirAll(filepath.Dir(existing), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(existing, []byte("package ctest\n"), 0644)).To(Succeed())
+		main := read("main.go")
+
+		written, err := NewKind(KindOptions{Root: root, Kind: "CTest"})
+		Expect(err).To(MatchError(ContainSubstring("already exists")))
+		Expect(written).To(BeEmpty())
+		Expect(filepath.Join(root, "api", "v1alpha1", "ctest_types.go")).NotTo(BeAnExistingFile())
+		Expect(filepath.Join(root, "controllers", "ctest", "controller_factory.go")).NotTo(BeAnExistingFile())
+		Expect(read("controllers/ctest/resource_manager.go")).To(Equal("package ctest\n"))
+		Expect(read("main.go")).To(Equal(main))
+	})
+
+	It("writes nothing if the controller can't be registered in main.go", func() {
+		Expect(ioutil.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0644)).To(Succeed())
+
+		_, err := NewKind(KindOptions{Root: root, Kind: "CTest"})
+		Expect(err).To(MatchError(ContainSubstring("does not contain the kubebuilder scaffold markers")))
+		Expect(filepath.Join(root, "controllers", "ctest")).NotTo(BeAnExistingFile())
+	})
+
+	It("rejects kinds that aren't CamelCase identifiers", func() {
+		_, err := NewKind(KindOptions{Root: root, Kind: "c-test"})
+		Expect(err).To(MatchError(ContainSubstring("must be a CamelCase identifier")))
+	})
+})
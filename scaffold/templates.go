@@ -0,0 +1,291 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scaffold
+
+import "text/template"
+
+var apiTemplate = template.Must(template.New("api").Parse(`{{.Boilerplate}}
+
+package {{.Version}}
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// {{.Kind}}Spec defines the desired state of {{.Kind}}
+type {{.Kind}}Spec struct {
+	Spec ` + "`" + `json:",inline"` + "`" + `
+{{- range .DependencyRefs}}
+	// the name of the {{.Kind}} this depends on
+	{{.Field}} string ` + "`" + `json:"{{.Json}},omitempty"` + "`" + `
+{{- end}}
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="State",type=string,JSONPath=` + "`" + `.status.state` + "`" + `
+// +kubebuilder:printcolumn:name="Message",type=string,JSONPath=` + "`" + `.status.message` + "`" + `
+
+// {{.Kind}} is the Schema for the {{.Plural}} API
+type {{.Kind}} struct {
+	metav1.TypeMeta   ` + "`" + `json:",inline"` + "`" + `
+	metav1.ObjectMeta ` + "`" + `json:"metadata,omitempty"` + "`" + `
+
+	Spec   {{.Kind}}Spec ` + "`" + `json:"spec,omitempty"` + "`" + `
+	Status Status ` + "`" + `json:"status,omitempty"` + "`" + `
+}
+
+// +kubebuilder:object:root=true
+
+// {{.Kind}}List contains a list of {{.Kind}}
+type {{.Kind}}List struct {
+	metav1.TypeMeta ` + "`" + `json:",inline"` + "`" + `
+	metav1.ListMeta ` + "`" + `json:"metadata,omitempty"` + "`" + `
+	Items           []{{.Kind}} ` + "`" + `json:"items"` + "`" + `
+}
+
+func init() {
+	SchemeBuilder.Register(&{{.Kind}}{}, &{{.Kind}}List{})
+}
+`))
+
+var definitionManagerTemplate = template.Must(template.New("definitionManager").Parse(`{{.Boilerplate}}
+
+package {{.Package}}
+
+import (
+	"context"
+{{- if .DependencyRefs}}
+	"fmt"
+{{- end}}
+
+	api "{{.Module}}/api/{{.Version}}"
+	"github.com/operatify/operatify/reconciler"
+	"k8s.io/apimachinery/pkg/runtime"
+{{- if .DependencyRefs}}
+	"k8s.io/apimachinery/pkg/types"
+{{- end}}
+)
+
+// Definition builds the ResourceDefinition for {{.Kind}}
+var Definition = reconciler.MustCreateDefinitionBuilder(&api.{{.Kind}}{})
+{{range .DependencyRefs}}
+var {{.Field}}Definition = reconciler.MustCreateDefinitionBuilder(&api.{{.Kind}}{})
+{{- end}}
+
+type definitionManager struct {
+	*reconciler.DefinitionBuilder
+}
+
+func (dm *definitionManager) GetDependencies(ctx context.Context, thisInstance runtime.Object) (*reconciler.DependencyDefinitions, error) {
+{{- if .DependencyRefs}}
+	x, ok := thisInstance.(*api.{{.Kind}})
+	if !ok {
+		return nil, fmt.Errorf("failed type assertion on kind: %s", ResourceKind)
+	}
+
+	var deps []*reconciler.Dependency
+{{- range .DependencyRefs}}
+	if x.Spec.{{.Field}} != "" {
+		deps = append(deps, {{.Field}}Definition.GetDependency(types.NamespacedName{
+			Namespace: x.Namespace,
+			Name:      x.Spec.{{.Field}},
+		}))
+	}
+{{- end}}
+
+	return &reconciler.DependencyDefinitions{
+		Dependencies: deps,
+	}, nil
+{{- else}}
+	return &reconciler.NoDependencies, nil
+{{- end}}
+}
+`))
+
+var controllerFactoryTemplate = template.Must(template.New("controllerFactory").Parse(`{{.Boilerplate}}
+
+package {{.Package}}
+
+import (
+	api "{{.Module}}/api/{{.Version}}"
+	"{{.Module}}/controllers/shared"
+	"github.com/operatify/operatify/reconciler"
+{{- if .DependencyRefs}}
+	"k8s.io/apimachinery/pkg/runtime"
+{{- end}}
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/go-logr/logr"
+)
+
+// ControllerFactory creates the controller for {{.Kind}}, with the ResourceManagerCreator and other settings of the reconciler.ControllerFactory.
+// The kind, its DefinitionManager, finalizer, annotations and provider config kinds are set by SetupWithManager
+type ControllerFactory struct {
+	reconciler.ControllerFactory
+}
+
+// +kubebuilder:rbac:groups={{.Group}},resources={{.Plural}},verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups={{.Group}},resources={{.Plural}}/status,verbs=get;update;patch
+
+const ResourceKind = "{{.Kind}}"
+const FinalizerName = "{{.Package}}.finalizers.com"
+
+func (factory *ControllerFactory) SetupWithManager(mgr ctrl.Manager, parameters reconciler.ReconcileParameters, log *logr.Logger) (*reconciler.GenericController, error) {
+	generic := factory.ControllerFactory
+	generic.Prototype = &api.{{.Kind}}{}
+	generic.ResourceKind = ResourceKind
+	generic.DefinitionManager = &definitionManager{Definition}
+	generic.FinalizerName = FinalizerName
+	generic.AnnotationBaseName = shared.AnnotationBaseName
+	generic.ProviderConfigKinds = shared.ProviderConfigKinds()
+{{- if .DependencyRefs}}
+	generic.DependencyKinds = []runtime.Object{ {{- range $i, $d := .DependencyRefs}}{{if $i}}, {{end}}&api.{{$d.Kind}}{}{{end -}} }
+{{- end}}
+	return generic.SetupWithManager(mgr, parameters, log)
+}
+`))
+
+var resourceManagerTemplate = template.Must(template.New("resourceManager").Parse(`{{.Boilerplate}}
+
+package {{.Package}}
+
+import (
+	"context"
+	"fmt"
+
+	api "{{.Module}}/api/{{.Version}}"
+	"github.com/go-logr/logr"
+	"github.com/operatify/operatify/reconciler"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// ResourceManager manages the external resources for {{.Kind}}
+type ResourceManager struct {
+	Logger   logr.Logger
+	Recorder record.EventRecorder
+}
+
+func CreateResourceManager(logger logr.Logger, recorder record.EventRecorder) reconciler.ResourceManager {
+	return &ResourceManager{
+		Logger:   logger,
+		Recorder: recorder,
+	}
+}
+
+func (r *ResourceManager) Create(ctx context.Context, s reconciler.ResourceSpec) (reconciler.ApplyResponse, error) {
+	// TODO: create the external resource
+	return reconciler.ApplyError, fmt.Errorf("Create is not implemented for %s", ResourceKind)
+}
+
+func (r *ResourceManager) Update(ctx context.Context, s reconciler.ResourceSpec) (reconciler.ApplyResponse, error) {
+	// TODO: update the external resource
+	return reconciler.ApplyError, fmt.Errorf("Update is not implemented for %s", ResourceKind)
+}
+
+func (r *ResourceManager) Verify(ctx context.Context, s reconciler.ResourceSpec) (reconciler.VerifyResponse, error) {
+	// TODO: verify the state of the external resource
+	return reconciler.VerifyError, fmt.Errorf("Verify is not implemented for %s", ResourceKind)
+}
+
+func (r *ResourceManager) Delete(ctx context.Context, s reconciler.ResourceSpec) (reconciler.DeleteResult, error) {
+	// TODO: delete the external resource
+	return reconciler.DeleteError, fmt.Errorf("Delete is not implemented for %s", ResourceKind)
+}
+
+func getSpec(object runtime.Object) (*api.{{.Kind}}Spec, error) {
+	spec, err := Definition.GetSpec(object)
+	if err != nil {
+		return nil, err
+	}
+	return spec.(*api.{{.Kind}}Spec), nil
+}
+`))
+
+var helpersTemplate = template.Must(template.New("helpers").Parse(`package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/gomega"
+	apiv1 "{{.Module}}/api/{{.Version}}"
+	"github.com/operatify/operatify/reconciler"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func deleteObject{{.Kind}}(key types.NamespacedName) error {
+	f, err := getObject{{.Kind}}(key)
+	if err != nil {
+		return err
+	}
+	return k8sClient.Delete(context.Background(), f)
+}
+
+func getObject{{.Kind}}(key types.NamespacedName) (*apiv1.{{.Kind}}, error) {
+	f := &apiv1.{{.Kind}}{}
+	err := k8sClient.Get(context.Background(), key, f)
+	return f, err
+}
+
+func nameAndSpec{{.Kind}}(id string, annotations map[string]string) (types.NamespacedName, *apiv1.{{.Kind}}) {
+	key := types.NamespacedName{
+		Name:      id,
+		Namespace: "default",
+	}
+	spec := &apiv1.{{.Kind}}{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        key.Name,
+			Namespace:   key.Namespace,
+			Annotations: annotations,
+		},
+		Spec: apiv1.{{.Kind}}Spec{
+			Spec: apiv1.Spec{
+				Id: id,
+			},
+		},
+	}
+
+	return key, spec
+}
+
+func waitUntilReconcileState{{.Kind}}(key types.NamespacedName, state reconciler.ReconcileState) {
+	Eventually(func() reconciler.ReconcileState {
+		f, _ := getObject{{.Kind}}(key)
+		return reconciler.ReconcileState(f.Status.State)
+	}, timeout, interval).Should(Equal(state))
+}
+
+func waitUntilObjectMissing{{.Kind}}(key types.NamespacedName) {
+	Eventually(func() error {
+		_, err := getObject{{.Kind}}(key)
+		return err
+	}, timeout, interval).ShouldNot(Succeed())
+}
+`))
+
+var registrationTemplate = template.Must(template.New("registration").Parse(`
+	if _, err = (&{{.Package}}.ControllerFactory{ControllerFactory: reconciler.ControllerFactory{
+		ResourceManagerCreator: {{.Package}}.CreateResourceManager,
+		EnableWebhooks:         enableWebhooks,
+		ShardManager:           shardManager,
+		HealthChecks:           healthChecks,
+	}}).SetupWithManager(mgr, controllerParams, nil); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "{{.Kind}}")
+		os.Exit(1)
+	}
+`))